@@ -18,6 +18,16 @@ func (a Address) addOffset(o DataOffset) Address {
 	return a + Address(o)
 }
 
+// addWords returns the address a+n words, or false if the result would
+// be before the start of a segment or past maxSize.
+func (a Address) addWords(n int64) (Address, bool) {
+	b := int64(a) + n*int64(wordSize)
+	if b < 0 || b > int64(maxSize) {
+		return 0, false
+	}
+	return Address(b), true
+}
+
 // A Size is a size (in bytes).
 type Size uint32
 
@@ -25,3 +25,26 @@ func TestAddressElement(t *testing.T) {
 		}
 	}
 }
+
+func TestAddressAddWords(t *testing.T) {
+	tests := []struct {
+		a   Address
+		n   int64
+		out Address
+		ok  bool
+	}{
+		{0, 0, 0, true},
+		{0, 1, 8, true},
+		{16, -2, 0, true},
+		{16, -3, 0, false},
+		{Address(maxSize) - 7, 1, 0, false},
+		{Address(maxSize) - 15, 1, Address(maxSize) - 7, true},
+		{0, 1 << 29, 0, false},
+		{8, -(1 << 29), 0, false},
+	}
+	for _, test := range tests {
+		if out, ok := test.a.addWords(test.n); out != test.out || ok != test.ok {
+			t.Errorf("%#v.addWords(%d) = %#v, %t; want %#v, %t", test.a, test.n, out, ok, test.out, test.ok)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package capnp
+
+import (
+	"bytes"
+	"hash"
+)
+
+// Canonicalize returns the canonical form of the message rooted at
+// root, as defined by the Cap'n Proto encoding spec: the words of a
+// single segment, without stream framing, holding the root pointer and
+// then every object reachable from it in preorder.  Each struct's
+// trailing zero data words and null pointers are trimmed, and the
+// elements of a composite list are trimmed to the size of its largest
+// element.  The result is len(Canonicalize(root)) == CanonicalSize(root)
+// bytes long, and Canonicalize returns an error wherever CanonicalSize
+// does.
+func Canonicalize(root Struct) ([]byte, error) {
+	n, err := CanonicalSize(root)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(maxSize) {
+		return nil, errOverlarge
+	}
+	msg, seg, err := NewMessage(SingleSegment(make([]byte, 0, n)))
+	if err != nil {
+		return nil, err
+	}
+	if root.seg == nil {
+		return seg.Data(), nil
+	}
+	cc := &canonicalCopier{seg: seg, left: n - uint64(wordSize)}
+	c, err := cc.structCopy(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.SetRoot(c); err != nil {
+		return nil, err
+	}
+	return seg.Data(), nil
+}
+
+// Hash writes the canonical form of the message rooted at root, as
+// returned by Canonicalize, to h.  Structs with the same content write
+// the same bytes no matter how their messages are laid out, so the
+// resulting sum can key a content-addressed store.  Like Canonicalize,
+// Hash returns an error if the message contains interface pointers.
+func Hash(root Struct, h hash.Hash) error {
+	b, err := Canonicalize(root)
+	if err != nil {
+		return err
+	}
+	_, err = h.Write(b)
+	return err
+}
+
+// IsCanonical reports whether data is a single segment in the
+// canonical form that Canonicalize produces.  A segment whose root is
+// not a struct, or that reaches a capability or a pointer cycle, is
+// not canonical.  IsCanonical returns an error if data cannot be read.
+func IsCanonical(data []byte) (bool, error) {
+	msg := &Message{Arena: SingleSegment(data)}
+	root, err := msg.Root()
+	if err != nil {
+		return false, err
+	}
+	if IsValid(root) {
+		if _, ok := root.underlying().(Struct); !ok {
+			return false, nil
+		}
+	}
+	canon, err := Canonicalize(ToStruct(root))
+	if err == errCanonicalCap || err == errPointerCycle {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(canon, data), nil
+}
+
+// A canonicalCopier copies objects into a segment in canonical form.
+type canonicalCopier struct {
+	seg *Segment
+	// left is the number of bytes the copy may still allocate.  The
+	// copy visits each shared object once per pointer to it, so left
+	// bounds its work even if the message was not sized beforehand.
+	left uint64
+}
+
+// charge subtracts n bytes from the copy's budget, returning
+// errOverlarge if the budget is spent.
+func (cc *canonicalCopier) charge(n uint64) error {
+	if n > cc.left {
+		cc.left = 0
+		return errOverlarge
+	}
+	cc.left -= n
+	return nil
+}
+
+// structCopy copies s and the objects reachable from it in canonical
+// form.
+func (cc *canonicalCopier) structCopy(s Struct) (Struct, error) {
+	dw, pc := canonicalStructSize(s)
+	sz := ObjectSize{DataSize: wordSize.times(dw), PointerCount: pc}
+	if err := cc.charge(uint64(sz.totalSize())); err != nil {
+		return Struct{}, err
+	}
+	c, err := NewStruct(cc.seg, sz)
+	if err != nil {
+		return Struct{}, err
+	}
+	if dw > 0 {
+		copy(c.seg.slice(c.off, c.size.DataSize), s.seg.slice(s.off, c.size.DataSize))
+	}
+	return c, cc.pointers(c, s)
+}
+
+// pointers fills in dst's pointers with canonical copies of the objects
+// that src's pointers reference.
+func (cc *canonicalCopier) pointers(dst, src Struct) error {
+	for i := uint16(0); i < dst.size.PointerCount; i++ {
+		p, err := src.Pointer(i)
+		if err != nil {
+			return err
+		}
+		if !IsValid(p) {
+			continue
+		}
+		var c Pointer
+		switch p := p.underlying().(type) {
+		case Struct:
+			c, err = cc.structCopy(p)
+		case List:
+			c, err = cc.list(p)
+		default:
+			return errCanonicalCap
+		}
+		if err != nil {
+			return err
+		}
+		if err := dst.SetPointer(i, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// list copies l and the objects reachable from it in canonical form.
+func (cc *canonicalCopier) list(l List) (List, error) {
+	n := int32(l.Len())
+	switch {
+	case l.flags&isBitList != 0:
+		sz := wordSize.times((n + 63) / 64)
+		if err := cc.charge(uint64(sz)); err != nil {
+			return List{}, err
+		}
+		seg, addr, err := alloc(cc.seg, sz)
+		if err != nil {
+			return List{}, err
+		}
+		c := List{seg: seg, off: addr, length: n, flags: isBitList}
+		b := c.seg.slice(c.off, Size((n+7)/8))
+		copy(b, l.seg.slice(l.off, Size((n+7)/8)))
+		if n%8 != 0 {
+			// Bits past the end of the list must be zero.
+			b[len(b)-1] &= 1<<uint(n%8) - 1
+		}
+		return c, nil
+	case l.flags&isCompositeList != 0:
+		var dw int32
+		var pc uint16
+		for i := 0; i < int(n); i++ {
+			edw, epc := canonicalStructSize(l.Struct(i))
+			if edw > dw {
+				dw = edw
+			}
+			if epc > pc {
+				pc = epc
+			}
+		}
+		esz := ObjectSize{DataSize: wordSize.times(dw), PointerCount: pc}
+		if err := cc.charge(uint64(wordSize) + uint64(esz.totalSize())*uint64(n)); err != nil {
+			return List{}, err
+		}
+		c, err := NewCompositeList(cc.seg, esz, n)
+		if err != nil {
+			return List{}, err
+		}
+		for i := 0; i < int(n); i++ {
+			if dw > 0 {
+				e, ce := l.Struct(i), c.Struct(i)
+				copy(ce.seg.slice(ce.off, ce.size.DataSize), e.seg.slice(e.off, ce.size.DataSize))
+			}
+		}
+		for i := 0; i < int(n); i++ {
+			if err := cc.pointers(c.Struct(i), l.Struct(i)); err != nil {
+				return List{}, err
+			}
+		}
+		return c, nil
+	case l.size.PointerCount > 0:
+		if err := cc.charge(uint64(wordSize) * uint64(n)); err != nil {
+			return List{}, err
+		}
+		c, err := NewPointerList(cc.seg, n)
+		if err != nil {
+			return List{}, err
+		}
+		for i := 0; i < int(n); i++ {
+			if err := cc.pointers(c.Struct(i), l.Struct(i)); err != nil {
+				return List{}, err
+			}
+		}
+		return c.List, nil
+	default:
+		sz := l.size.DataSize.times(n)
+		if err := cc.charge(uint64(sz.padToWord())); err != nil {
+			return List{}, err
+		}
+		c, err := newPrimitiveList(cc.seg, l.size.DataSize, n)
+		if err != nil {
+			return List{}, err
+		}
+		copy(c.seg.slice(c.off, sz), l.seg.slice(l.off, sz))
+		return c, nil
+	}
+}
+
+// CanonicalSize returns the number of bytes in the canonical form of
+// the message rooted at root: a single segment holding the root
+// pointer followed by the objects reachable from it in preorder, with
+// each struct's trailing zero data words and null pointers trimmed.
+// The canonical form has no sharing, so an object referenced by more
+// than one pointer is counted once for each.  CanonicalSize returns an
+// error if root reaches a pointer cycle or an interface pointer, since
+// neither has a canonical form.
+func CanonicalSize(root Pointer) (uint64, error) {
+	c := &canonicalSizer{
+		onPath: make(map[objectKey]struct{}),
+		memo:   make(map[objectKey]uint64),
+	}
+	n, err := c.size(root)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(wordSize) + n, nil
+}
+
+// canonicalStructSize returns the size of s's sections in canonical
+// form, after trimming trailing zero data words and null pointers.
+func canonicalStructSize(s Struct) (dataWords int32, ptrs uint16) {
+	if s.seg == nil {
+		return 0, 0
+	}
+	data := s.seg.slice(s.off, s.size.DataSize)
+	for dataWords = int32(len(data) / int(wordSize)); dataWords > 0; dataWords-- {
+		if s.seg.readUint64(s.off.addSize(wordSize.times(dataWords-1))) != 0 {
+			break
+		}
+	}
+	for ptrs = s.size.PointerCount; ptrs > 0; ptrs-- {
+		if s.HasPointer(ptrs - 1) {
+			break
+		}
+	}
+	return dataWords, ptrs
+}
+
+// A canonicalSizer computes the canonical size of objects.
+type canonicalSizer struct {
+	// onPath holds the objects between the root and the current one.
+	onPath map[objectKey]struct{}
+	// memo holds the size of objects that have been fully walked.
+	memo map[objectKey]uint64
+}
+
+// size returns the canonical size of the object p points to and every
+// object reachable from it, excluding the pointer to p itself.
+func (c *canonicalSizer) size(p Pointer) (uint64, error) {
+	if !IsValid(p) {
+		return 0, nil
+	}
+	switch p := p.underlying().(type) {
+	case Struct:
+		if p.size.PointerCount == 0 {
+			dw, _ := canonicalStructSize(p)
+			return uint64(wordSize.times(dw)), nil
+		}
+		return c.object(objectKey{p.seg.id, p.off, false}, func() (uint64, error) {
+			dw, pc := canonicalStructSize(p)
+			n := uint64(wordSize.times(dw)) + uint64(wordSize.times(int32(pc)))
+			sub, err := c.pointers(p, pc)
+			if err != nil {
+				return 0, err
+			}
+			return sumSize(n, sub)
+		})
+	case List:
+		n := int32(p.Len())
+		switch {
+		case p.flags&isBitList != 0:
+			return uint64(n+63) / 64 * uint64(wordSize), nil
+		case p.flags&isCompositeList != 0:
+			return c.object(objectKey{p.seg.id, p.off, true}, func() (uint64, error) {
+				var dw int32
+				var pc uint16
+				for i := 0; i < int(n); i++ {
+					edw, epc := canonicalStructSize(p.Struct(i))
+					if edw > dw {
+						dw = edw
+					}
+					if epc > pc {
+						pc = epc
+					}
+				}
+				total, err := sumSize(uint64(wordSize), uint64(wordSize.times(dw+int32(pc)))*uint64(n))
+				if err != nil {
+					return 0, err
+				}
+				for i := 0; i < int(n); i++ {
+					sub, err := c.pointers(p.Struct(i), pc)
+					if err != nil {
+						return 0, err
+					}
+					if total, err = sumSize(total, sub); err != nil {
+						return 0, err
+					}
+				}
+				return total, nil
+			})
+		case p.size.PointerCount > 0:
+			return c.object(objectKey{p.seg.id, p.off, true}, func() (uint64, error) {
+				total := uint64(wordSize.times(n))
+				for i := 0; i < int(n); i++ {
+					sub, err := c.pointers(p.Struct(i), 1)
+					if err != nil {
+						return 0, err
+					}
+					if total, err = sumSize(total, sub); err != nil {
+						return 0, err
+					}
+				}
+				return total, nil
+			})
+		default:
+			return uint64(p.size.DataSize.times(n).padToWord()), nil
+		}
+	default:
+		return 0, errCanonicalCap
+	}
+}
+
+// pointers returns the total canonical size of the objects referenced
+// by the first n pointers of s.
+func (c *canonicalSizer) pointers(s Struct, n uint16) (uint64, error) {
+	var total uint64
+	for i := uint16(0); i < n && i < s.size.PointerCount; i++ {
+		p, err := s.Pointer(i)
+		if err != nil {
+			return 0, err
+		}
+		sub, err := c.size(p)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = sumSize(total, sub); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// object returns the size of the object k, computing it with f if k
+// has not been walked yet.
+func (c *canonicalSizer) object(k objectKey, f func() (uint64, error)) (uint64, error) {
+	if n, ok := c.memo[k]; ok {
+		return n, nil
+	}
+	if _, ok := c.onPath[k]; ok {
+		return 0, errPointerCycle
+	}
+	c.onPath[k] = struct{}{}
+	n, err := f()
+	delete(c.onPath, k)
+	if err != nil {
+		return 0, err
+	}
+	c.memo[k] = n
+	return n, nil
+}
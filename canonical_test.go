@@ -0,0 +1,345 @@
+package capnp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestCanonicalSize(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Trailing zero data words and null pointers are trimmed.
+	trimmed, err := NewStruct(seg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trimmed.SetUint64(0, 1)
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trimmed.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shared objects are duplicated.
+	leaf, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf.SetUint64(0, 7)
+	shared, err := NewStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.SetPointer(0, leaf); err != nil {
+		t.Fatal(err)
+	}
+	if err := shared.SetPointer(1, leaf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Composite list elements are trimmed to the largest element.
+	list, err := NewCompositeList(seg, ObjectSize{DataSize: 24, PointerCount: 2}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list.Struct(0).SetUint64(0, 1)
+	list.Struct(1).SetUint64(8, 1)
+	a, err := NewText(seg, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Struct(1).SetPointer(0, a); err != nil {
+		t.Fatal(err)
+	}
+
+	bits, err := NewBitList(seg, 65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u16, err := NewUInt16List(seg, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		p    Pointer
+		size uint64
+	}{
+		{"null", nil, 8},
+		{"empty struct", Struct{seg: seg, off: trimmed.off, size: ObjectSize{}}, 8},
+		{"trimmed struct", trimmed, 8 + 8 + 8 + 8},
+		{"shared leaf", shared, 8 + 16 + 8 + 8},
+		{"composite list", list, 8 + 8 + 2*(16+8) + 8},
+		{"bit list", bits, 8 + 16},
+		{"uint16 list", u16, 8 + 16},
+	}
+	for _, test := range tests {
+		n, err := CanonicalSize(test.p)
+		if err != nil {
+			t.Errorf("CanonicalSize(%s): %v", test.name, err)
+			continue
+		}
+		if n != test.size {
+			t.Errorf("CanonicalSize(%s) = %d; want %d", test.name, n, test.size)
+		}
+	}
+
+	withCap, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := withCap.SetPointer(0, NewInterface(seg, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CanonicalSize(withCap); err == nil {
+		t.Error("CanonicalSize of struct with capability succeeded")
+	}
+
+	// A struct whose only pointer points back at itself.
+	cyclic := &Message{Arena: SingleSegment([]byte{
+		0, 0, 0, 0, 0, 0, 1, 0,
+		0xfc, 0xff, 0xff, 0xff, 0, 0, 1, 0,
+	})}
+	root, err := cyclic.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CanonicalSize(root); err == nil {
+		t.Error("CanonicalSize of cyclic message succeeded")
+	}
+
+	// Each level's two pointers share the level below, so the canonical
+	// size doubles with each level of a message that grows linearly.
+	if n, err := CanonicalSize(sharedTree(t, 64)); err != errOverlarge {
+		t.Errorf("CanonicalSize(shared tree) = %d, %v; want %v", n, err, errOverlarge)
+	}
+	if n, err := CanonicalSize(sharedTree(t, 4)); err != nil || n != 8+15*16+16*8 {
+		t.Errorf("CanonicalSize(small shared tree) = %d, %v; want %d, <nil>", n, err, 8+15*16+16*8)
+	}
+}
+
+func TestCanonicalizeSharedTree(t *testing.T) {
+	root := sharedTree(t, 64)
+	if _, err := Canonicalize(root); err != errOverlarge {
+		t.Errorf("Canonicalize(shared tree) error = %v; want %v", err, errOverlarge)
+	}
+	if err := Hash(root, sha256.New()); err != errOverlarge {
+		t.Errorf("Hash(shared tree) error = %v; want %v", err, errOverlarge)
+	}
+	if err := root.Segment().Message().SetRoot(root); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := IsCanonical(root.Segment().Data()); ok || err == nil {
+		t.Errorf("IsCanonical(shared tree) = %t, %v; want false, error", ok, err)
+	}
+
+	// The copy stops once it has allocated its budget, without relying
+	// on CanonicalSize.
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := &canonicalCopier{seg: seg, left: 1024}
+	if _, err := cc.structCopy(root); err != errOverlarge {
+		t.Errorf("structCopy(shared tree) with 1024 byte budget error = %v; want %v", err, errOverlarge)
+	}
+}
+
+// sharedTree returns a struct whose two pointers both point to the
+// same struct, which does the same, levels deep, ending in a struct
+// with one nonzero data word.
+func sharedTree(t *testing.T, levels int) Struct {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 1)
+	return shareStruct(t, s, levels)
+}
+
+// shareStruct returns a struct whose two pointers both point to s,
+// wrapping it the same way levels times.  Walking every path of the
+// result visits s 2**levels times.
+func shareStruct(t *testing.T, s Struct, levels int) Struct {
+	for i := 0; i < levels; i++ {
+		parent, err := NewStruct(s.Segment(), ObjectSize{PointerCount: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parent.SetPointer(0, s); err != nil {
+			t.Fatal(err)
+		}
+		if err := parent.SetPointer(1, s); err != nil {
+			t.Fatal(err)
+		}
+		s = parent
+	}
+	return s
+}
+
+func TestCanonicalize(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 1)
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{
+		0, 0, 0, 0, 1, 0, 1, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0x1a, 0, 0, 0,
+		'h', 'i', 0, 0, 0, 0, 0, 0,
+	}
+	out, err := Canonicalize(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Errorf("Canonicalize(root) = % 02x; want % 02x", out, want)
+	}
+	if ok, err := IsCanonical(want); err != nil || !ok {
+		t.Errorf("IsCanonical(canonical) = %t, %v; want true, <nil>", ok, err)
+	}
+	if ok, err := IsCanonical(seg.Data()); err != nil || ok {
+		t.Errorf("IsCanonical(untrimmed) = %t, %v; want false, <nil>", ok, err)
+	}
+
+	// Shared objects, lists, and a far pointer to the root.
+	_, seg, err = NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg = root.Segment()
+	leaf, err := NewStruct(seg, ObjectSize{DataSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf.SetUint64(0, 7)
+	list, err := NewCompositeList(seg, ObjectSize{DataSize: 24, PointerCount: 2}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list.Struct(0).SetUint64(8, 3)
+	if err := list.Struct(1).SetPointer(0, leaf); err != nil {
+		t.Fatal(err)
+	}
+	bits, err := NewBitList(seg, 11)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(10, true)
+	ptrs, err := NewPointerList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ptrs.Set(1, leaf); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := NewStruct(seg, ObjectSize{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range []Pointer{leaf, list, bits, ptrs, empty} {
+		if err := root.SetPointer(uint16(i), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err = Canonicalize(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := CanonicalSize(root); err != nil || n != uint64(len(out)) {
+		t.Errorf("CanonicalSize(root) = %d, %v; want %d, <nil>", n, err, len(out))
+	}
+	if ok, err := IsCanonical(out); err != nil || !ok {
+		t.Errorf("IsCanonical(Canonicalize(root)) = %t, %v; want true, <nil>", ok, err)
+	}
+	canon, err := (&Message{Arena: SingleSegment(out)}).Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := root.Equal(ToStruct(canon)); err != nil || !eq {
+		t.Errorf("canonical root Equal(root) = %t, %v; want true, <nil>", eq, err)
+	}
+	again, err := Canonicalize(ToStruct(canon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, out) {
+		t.Errorf("Canonicalize(canonical) = % 02x; want % 02x", again, out)
+	}
+
+	if out, err := Canonicalize(Struct{}); err != nil || !bytes.Equal(out, make([]byte, 8)) {
+		t.Errorf("Canonicalize(Struct{}) = % 02x, %v; want 00 * 8, <nil>", out, err)
+	}
+	if ok, err := IsCanonical([]byte{
+		0, 0, 0, 0, 0, 0, 1, 0,
+		0xfc, 0xff, 0xff, 0xff, 0, 0, 1, 0,
+	}); err != nil || ok {
+		t.Errorf("IsCanonical(cyclic) = %t, %v; want false, <nil>", ok, err)
+	}
+}
+
+func TestHash(t *testing.T) {
+	build := func(arena Arena, sz ObjectSize, v uint64, text string) Struct {
+		_, seg, err := NewMessage(arena)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewRootStruct(seg, sz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetUint64(0, v)
+		txt, err := NewText(seg, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	sum := func(s Struct) []byte {
+		h := sha256.New()
+		if err := Hash(s, h); err != nil {
+			t.Fatal("Hash:", err)
+		}
+		return h.Sum(nil)
+	}
+
+	a := sum(build(SingleSegment(nil), ObjectSize{DataSize: 8, PointerCount: 1}, 1, "hi"))
+	// Extra zero data and null pointers don't change the content.
+	b := sum(build(MultiSegment(nil), ObjectSize{DataSize: 16, PointerCount: 2}, 1, "hi"))
+	if !bytes.Equal(a, b) {
+		t.Errorf("hashes of equal content differ: %x vs %x", a, b)
+	}
+	if c := sum(build(SingleSegment(nil), ObjectSize{DataSize: 8, PointerCount: 1}, 1, "ho")); bytes.Equal(a, c) {
+		t.Error("hashes of different content are equal")
+	}
+}
@@ -35,6 +35,33 @@ func ToInterface(p Pointer) Interface {
 	return i
 }
 
+// StripCapabilities sets every interface pointer reachable from root to
+// null and empties the capability table of root's message, leaving
+// plain data that is safe to persist.  The clients in the table are
+// not closed; the caller is responsible for them.  The whole message is
+// walked before any pointer is cleared, so if StripCapabilities returns
+// an error, the message is unchanged.
+func StripCapabilities(root Pointer) error {
+	if !IsValid(root) {
+		return nil
+	}
+	if IsValid(ToInterface(root)) {
+		return errStripRoot
+	}
+	w := newCapWalker()
+	if err := w.walk(root, 0); err != nil {
+		return err
+	}
+	if len(w.slots) > 0 && root.Segment().readOnly {
+		return errReadOnly
+	}
+	for _, slot := range w.slots {
+		slot.seg.writeRawPointer(slot.addr, 0)
+	}
+	root.Segment().msg.CapTable = nil
+	return nil
+}
+
 // Segment returns the segment this pointer came from.
 func (i Interface) Segment() *Segment {
 	return i.seg
@@ -151,15 +178,15 @@ func (call *Call) Copy(s *Segment) (*Call, error) {
 }
 
 // PlaceParams returns the parameters struct, allocating it inside
-// segment s as necessary.  If s is nil, a new single-segment message
-// is allocated.
+// segment s as necessary.  If s is nil, a new message is allocated
+// with NewDefaultMessage.
 func (call *Call) PlaceParams(s *Segment) (Struct, error) {
 	if call.ParamsFunc == nil {
 		return call.Params, nil
 	}
 	if s == nil {
 		var err error
-		_, s, err = NewMessage(SingleSegment(nil))
+		_, s, err = NewDefaultMessage()
 		if err != nil {
 			return Struct{}, err
 		}
@@ -278,3 +278,137 @@ func deepPointerEqual(a, b Pointer) bool {
 	bbytes, _ := msgB.Marshal()
 	return bytes.Equal(abytes, bbytes)
 }
+
+func TestStripCapabilities(t *testing.T) {
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg = root.Segment()
+	root.SetUint64(0, 42)
+	msg.AddCap(ErrorClient(errors.New("a")))
+	msg.AddCap(ErrorClient(errors.New("b")))
+	if err := root.SetPointer(0, NewInterface(seg, 0)); err != nil {
+		t.Fatal(err)
+	}
+	inner, err := NewStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "kept")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.SetPointer(1, NewInterface(seg, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, inner); err != nil {
+		t.Fatal(err)
+	}
+	caps, err := NewPointerList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := caps.Set(0, NewInterface(seg, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(2, caps); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := StripCapabilities(p); err != nil {
+		t.Fatal("StripCapabilities:", err)
+	}
+	if ids, err := msg.Capabilities(); err != nil || len(ids) != 0 {
+		t.Errorf("after StripCapabilities, Capabilities() = %v, %v; want [], <nil>", ids, err)
+	}
+	if len(msg.CapTable) != 0 {
+		t.Errorf("after StripCapabilities, len(CapTable) = %d; want 0", len(msg.CapTable))
+	}
+	if root.Uint64(0) != 42 {
+		t.Errorf("root.Uint64(0) = %d; want 42", root.Uint64(0))
+	}
+	if p, err := inner.Pointer(0); err != nil || ToText(p) != "kept" {
+		t.Errorf("inner text = %q, %v; want \"kept\", <nil>", ToText(p), err)
+	}
+
+	if err := StripCapabilities(NewInterface(seg, 0)); err == nil {
+		t.Error("StripCapabilities on an interface root succeeded")
+	}
+
+	// A capability next to a chain too deep to walk is left alone.
+	msg, seg, err = NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.AddCap(ErrorClient(errors.New("c")))
+	root, err = NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, NewInterface(seg, 0)); err != nil {
+		t.Fatal(err)
+	}
+	deep, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, deep); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxSizeDepth; i++ {
+		next, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := deep.SetPointer(0, next); err != nil {
+			t.Fatal(err)
+		}
+		deep = next
+	}
+	if err := StripCapabilities(root); err != errCapsDepth {
+		t.Errorf("StripCapabilities(deep) error = %v; want %v", err, errCapsDepth)
+	}
+	if p, err := root.Pointer(0); err != nil || !IsValid(ToInterface(p)) {
+		t.Error("StripCapabilities(deep) cleared a capability before failing")
+	}
+	if len(msg.CapTable) != 1 {
+		t.Errorf("after failed StripCapabilities, len(CapTable) = %d; want 1", len(msg.CapTable))
+	}
+}
+
+func TestStripCapabilitiesShared(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.AddCap(ErrorClient(errors.New("c")))
+	leaf, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.SetPointer(0, NewInterface(seg, 0)); err != nil {
+		t.Fatal(err)
+	}
+	root := shareStruct(t, leaf, 60)
+	if err := StripCapabilities(root); err != nil {
+		t.Fatal("StripCapabilities:", err)
+	}
+	if leaf.HasPointer(0) {
+		t.Error("after StripCapabilities, shared leaf still has a capability")
+	}
+	if len(msg.CapTable) != 0 {
+		t.Errorf("after StripCapabilities, len(CapTable) = %d; want 0", len(msg.CapTable))
+	}
+}
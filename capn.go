@@ -17,6 +17,10 @@ type Segment struct {
 	msg  *Message
 	id   SegmentID
 	data []byte
+
+	// readOnly is set for segments of a message whose arena must not
+	// be written to, like one that aliases a memory-mapped file.
+	readOnly bool
 }
 
 // Message returns the message that contains s.
@@ -34,6 +38,19 @@ func (s *Segment) Data() []byte {
 	return s.data
 }
 
+// FreeSpace returns the number of bytes that can be allocated at the
+// end of s without moving to another segment.  It only describes s:
+// the message may still be able to allocate in other segments.
+func (s *Segment) FreeSpace() Size {
+	return Size(cap(s.data) - len(s.data))
+}
+
+// HasCapacity reports whether sz bytes can be allocated at the end of s
+// without moving to another segment.
+func (s *Segment) HasCapacity(sz Size) bool {
+	return hasCapacity(s.data, sz)
+}
+
 func (s *Segment) inBounds(addr Address) bool {
 	return addr < Address(len(s.data))
 }
@@ -50,6 +67,15 @@ func (s *Segment) slice(base Address, sz Size) []byte {
 	return s.data[base:base.addSize(sz)]
 }
 
+// writeSlice is like slice, but for data that is about to be written.
+// It panics if s is read-only.
+func (s *Segment) writeSlice(base Address, sz Size) []byte {
+	if s.readOnly {
+		panic(errReadOnly)
+	}
+	return s.slice(base, sz)
+}
+
 func (s *Segment) readUint8(addr Address) uint8 {
 	return s.slice(addr, 1)[0]
 }
@@ -71,19 +97,19 @@ func (s *Segment) readRawPointer(addr Address) rawPointer {
 }
 
 func (s *Segment) writeUint8(addr Address, val uint8) {
-	s.slice(addr, 1)[0] = val
+	s.writeSlice(addr, 1)[0] = val
 }
 
 func (s *Segment) writeUint16(addr Address, val uint16) {
-	binary.LittleEndian.PutUint16(s.slice(addr, 2), val)
+	binary.LittleEndian.PutUint16(s.writeSlice(addr, 2), val)
 }
 
 func (s *Segment) writeUint32(addr Address, val uint32) {
-	binary.LittleEndian.PutUint32(s.slice(addr, 4), val)
+	binary.LittleEndian.PutUint32(s.writeSlice(addr, 4), val)
 }
 
 func (s *Segment) writeUint64(addr Address, val uint64) {
-	binary.LittleEndian.PutUint64(s.slice(addr, 8), val)
+	binary.LittleEndian.PutUint64(s.writeSlice(addr, 8), val)
 }
 
 func (s *Segment) writeRawPointer(addr Address, val rawPointer) {
@@ -135,6 +161,9 @@ func (s *Segment) readPtr(off Address) (Pointer, error) {
 		if !s.regionInBounds(addr, sz.totalSize()) {
 			return nil, errPointerAddress
 		}
+		if !s.msg.canRead(uint64(sz.totalWordCount())) {
+			return nil, errTraversalLimit
+		}
 		return Struct{
 			seg:  s,
 			off:  addr,
@@ -149,6 +178,13 @@ func (s *Segment) readPtr(off Address) (Pointer, error) {
 		if !s.regionInBounds(addr, lsize) {
 			return nil, errPointerAddress
 		}
+		words := (uint64(lsize) + uint64(wordSize) - 1) / uint64(wordSize)
+		if lt == voidList {
+			words = uint64(val.numListElements())
+		}
+		if !s.msg.canRead(words) {
+			return nil, errTraversalLimit
+		}
 		if lt == compositeList {
 			hdr := s.readRawPointer(addr)
 			addr = addr.addSize(wordSize)
@@ -157,10 +193,18 @@ func (s *Segment) readPtr(off Address) (Pointer, error) {
 			}
 			sz := hdr.structSize()
 			n := int32(hdr.offset())
-			// TODO(light): check that this has the same end address
+			// The elements described by the tag must fit in the words
+			// given by the list pointer, or they could overlap what
+			// comes after the list.
+			if n < 0 || int64(n)*int64(sz.totalWordCount()) > int64(val.numListElements()) {
+				return nil, errBadTag
+			}
 			if !s.regionInBounds(addr, sz.totalSize().times(n)) {
 				return nil, errPointerAddress
 			}
+			if sz.isZero() && !s.msg.canRead(uint64(n)) {
+				return nil, errTraversalLimit
+			}
 			return List{
 				seg:    s,
 				size:   sz,
@@ -259,7 +303,7 @@ func makeOffsetKey(p Pointer) offset {
 			boff: int64(p.off) * 8,
 		}
 		if p.flags&isBitList != 0 {
-			key.bend = int64(p.off)*8 + int64(p.length)
+			key.bend = int64(p.off)*8 + int64((p.length+7)/8)*8
 		} else {
 			key.bend = int64(p.off.addSize(p.size.totalSize().times(p.length))) * 8
 		}
@@ -299,6 +343,9 @@ func needsCopy(dest *Segment, src Pointer) bool {
 }
 
 func (destSeg *Segment) writePtr(cc copyContext, off Address, src Pointer) error {
+	if destSeg.readOnly {
+		return errReadOnly
+	}
 	// handle nulls
 	if !IsValid(src) {
 		destSeg.writeRawPointer(off, 0)
@@ -314,11 +361,26 @@ func (destSeg *Segment) writePtr(cc copyContext, off Address, src Pointer) error
 		destSeg.writeRawPointer(off, src.value(off))
 		return nil
 	}
+	if l, ok := src.underlying().(List); ok && l.flags&isListSlice != 0 {
+		// A slice has no list pointer of its own to refer to.
+		return copyPointer(cc, destSeg, off, src)
+	}
 	if destSeg != srcSeg {
 		// Different segments
 		if needsCopy(destSeg, src) {
 			return copyPointer(cc, destSeg, off, src)
 		}
+		srcAddr := pointerAddress(src)
+		if pad, ok := destSeg.msg.findLandingPad(src); ok {
+			// An earlier far pointer to the same object left a
+			// landing pad that can be shared.
+			if pad.double {
+				destSeg.writeRawPointer(off, rawDoubleFarPointer(pad.seg, pad.addr))
+			} else {
+				destSeg.writeRawPointer(off, rawFarPointer(pad.seg, pad.addr))
+			}
+			return nil
+		}
 		if !hasCapacity(srcSeg.data, wordSize) {
 			// Double far pointer needed.
 			const landingSize = wordSize * 2
@@ -327,16 +389,17 @@ func (destSeg *Segment) writePtr(cc copyContext, off Address, src Pointer) error
 				return err
 			}
 
-			srcAddr := pointerAddress(src)
 			t.writeRawPointer(dstAddr, rawFarPointer(srcSeg.id, srcAddr))
 			t.writeRawPointer(dstAddr.addSize(wordSize), src.value(srcAddr-Address(wordSize)))
 			destSeg.writeRawPointer(off, rawDoubleFarPointer(t.id, dstAddr))
+			destSeg.msg.addLandingPad(srcSeg.id, srcAddr, landingPad{seg: t.id, addr: dstAddr, double: true})
 			return nil
 		}
 		// Have room in the target for a tag
-		_, srcAddr, _ := alloc(srcSeg, wordSize)
-		srcSeg.writeRawPointer(srcAddr, src.value(srcAddr))
-		destSeg.writeRawPointer(off, rawFarPointer(srcSeg.id, srcAddr))
+		_, padAddr, _ := alloc(srcSeg, wordSize)
+		srcSeg.writeRawPointer(padAddr, src.value(padAddr))
+		destSeg.writeRawPointer(off, rawFarPointer(srcSeg.id, padAddr))
+		destSeg.msg.addLandingPad(srcSeg.id, srcAddr, landingPad{seg: srcSeg.id, addr: padAddr})
 		return nil
 	}
 	destSeg.writeRawPointer(off, src.value(off))
@@ -397,18 +460,20 @@ func copyPointer(cc copyContext, dstSeg *Segment, dstAddr Address, src Pointer)
 			off:    newAddr,
 			length: src.length,
 			size:   src.size,
-			flags:  src.flags,
+			flags:  src.flags &^ isListSlice,
 		}
 		if dst.flags&isCompositeList != 0 {
-			// Copy tag word
-			newSeg.writeRawPointer(newAddr, src.seg.readRawPointer(src.off-Address(wordSize)))
+			// Write tag word.  src's tag isn't copied, since a slice's
+			// tag counts the whole list it came from.
+			newSeg.writeRawPointer(newAddr, rawStructPointer(pointerOffset(src.length), src.size))
 			dst.off = dst.off.addSize(wordSize)
 		}
 		key.newval = dst
 		cc.copies.Insert(key)
 		// TODO(light): fast path for copying text/data
 		if dst.flags&isBitList != 0 {
-			copy(newSeg.data[newAddr:], src.seg.data[src.off:src.length+7/8])
+			n := Size((src.length + 7) / 8)
+			copy(newSeg.slice(newAddr, n), src.seg.slice(src.off, n))
 		} else {
 			for i := 0; i < src.Len(); i++ {
 				err := copyStruct(cc, dst.Struct(i), src.Struct(i))
@@ -450,13 +515,31 @@ var (
 	errBadTag         = errors.New("capnp: invalid tag word")
 	errOtherPointer   = errors.New("capnp: unknown pointer type")
 	errObjectSize     = errors.New("capnp: invalid object size")
+	errTraversalLimit = errors.New("capnp: traversal limit exceeded")
 )
 
 var (
-	errOverlarge   = errors.New("capnp: overlarge struct/list")
-	errOutOfBounds = errors.New("capnp: address out of bounds")
-	errCopyDepth   = errors.New("capnp: copy depth too large")
-	errOverlap     = errors.New("capnp: overlapping data on copy")
-	errListSize    = errors.New("capnp: invalid list size")
-	errObjectType  = errors.New("capnp: invalid object type")
+	errOverlarge    = errors.New("capnp: overlarge struct/list")
+	errOutOfBounds  = errors.New("capnp: address out of bounds")
+	errCopyDepth    = errors.New("capnp: copy depth too large")
+	errSizeDepth    = errors.New("capnp: size walk depth too large")
+	errDumpDepth    = errors.New("capnp: dump depth too large")
+	errMaxDepth     = errors.New("capnp: pointer depth too large")
+	errValueKind    = errors.New("capnp: value is of a different kind")
+	errCapsDepth    = errors.New("capnp: capability walk depth too large")
+	errEqualDepth   = errors.New("capnp: equality depth too large")
+	errPointerCycle = errors.New("capnp: pointer cycle")
+	errOverlap      = errors.New("capnp: overlapping data on copy")
+	errListSize     = errors.New("capnp: invalid list size")
+	errObjectType   = errors.New("capnp: invalid object type")
+
+	errReinterpretSize   = errors.New("capnp: reinterpreted struct is larger than its allocation")
+	errUpgradeListMember = errors.New("capnp: can't upgrade a list element")
+	errUpgradeParent     = errors.New("capnp: upgraded struct's parent pointer doesn't refer to it")
+	errDataTooLong       = errors.New("capnp: reader has more data than declared size")
+	errCanonicalCap      = errors.New("capnp: canonical form cannot contain capabilities")
+	errStripRoot         = errors.New("capnp: cannot strip capabilities from an interface root")
+	errCopyInvalid       = errors.New("capnp: copy into invalid struct")
+	errCopyPointers      = errors.New("capnp: copy source has more pointers than destination")
+	errReadOnly          = errors.New("capnp: write to read-only arena")
 )
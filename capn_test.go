@@ -64,6 +64,33 @@ func TestSegmentRegionInBounds(t *testing.T) {
 	}
 }
 
+func TestSegmentFreeSpace(t *testing.T) {
+	tests := []struct {
+		len, cap int
+		sz       Size
+		free     Size
+		ok       bool
+	}{
+		{0, 0, 0, 0, true},
+		{0, 0, 8, 0, false},
+		{0, 16, 8, 16, true},
+		{0, 16, 16, 16, true},
+		{0, 16, 24, 16, false},
+		{8, 16, 8, 8, true},
+		{8, 16, 16, 8, false},
+		{16, 16, 0, 0, true},
+	}
+	for _, test := range tests {
+		seg := &Segment{data: make([]byte, test.len, test.cap)}
+		if free := seg.FreeSpace(); free != test.free {
+			t.Errorf("&Segment{data: make([]byte, %d, %d)}.FreeSpace() = %d; want %d", test.len, test.cap, free, test.free)
+		}
+		if ok := seg.HasCapacity(test.sz); ok != test.ok {
+			t.Errorf("&Segment{data: make([]byte, %d, %d)}.HasCapacity(%d) = %t; want %t", test.len, test.cap, test.sz, ok, test.ok)
+		}
+	}
+}
+
 func TestSegmentReadUint8(t *testing.T) {
 	tests := []struct {
 		data   []byte
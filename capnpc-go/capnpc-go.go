@@ -19,6 +19,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -28,6 +29,7 @@ import (
 
 var (
 	genPromises = flag.Bool("promises", true, "generate code for promises")
+	genEqual    = flag.Bool("equal", false, "generate Equal methods for structs")
 )
 
 const (
@@ -240,6 +242,7 @@ type annotations struct {
 	TagType   int
 	CustomTag string
 	Name      string
+	Optional  bool
 }
 
 func parseAnnotations(list Annotation_List) *annotations {
@@ -262,6 +265,8 @@ func parseAnnotations(list Annotation_List) *annotations {
 			ann.TagType = noTag
 		case capnp.Name:
 			ann.Name = text
+		case capnp.Optional:
+			ann.Optional = true
 		}
 	}
 	return ann
@@ -497,6 +502,11 @@ func (n *node) defineAnnotation(w io.Writer) {
 
 func constIsVar(n *node) bool {
 	t, _ := n.Const().Type()
+	return typeIsVar(t)
+}
+
+// typeIsVar reports whether values of t cannot be Go constants.
+func typeIsVar(t Type) bool {
 	switch t.Which() {
 	case Type_Which_bool, Type_Which_int8, Type_Which_uint8, Type_Which_int16,
 		Type_Which_uint16, Type_Which_int32, Type_Which_uint32, Type_Which_int64,
@@ -561,9 +571,14 @@ func (n *node) defineField(w io.Writer, f field) {
 		Annotations: ann,
 		FieldType:   n.fieldType(t, ann),
 	}
+	var defref staticDataRef
 	switch t.Which() {
 	case Type_Which_void:
-		templates.ExecuteTemplate(w, "structVoidField", params)
+		vp := structVoidFieldParams{structFieldParams: params}
+		if f.DiscriminantValue() != Field_noDiscriminant {
+			vp.ClearData, vp.ClearPointers = n.unionStorage(f)
+		}
+		templates.ExecuteTemplate(w, "structVoidField", vp)
 	case Type_Which_bool:
 		assert(def.Which() == Value_Which_void || def.Which() == Value_Which_bool, "expected bool default")
 		templates.ExecuteTemplate(w, "structBoolField", structBoolFieldParams{
@@ -650,7 +665,6 @@ func (n *node) defineField(w io.Writer, f field) {
 
 	case Type_Which_structGroup:
 		assert(def.Which() == Value_Which_void || def.Which() == Value_Which_structField, "expected struct default")
-		var defref staticDataRef
 		if def.Which() == Value_Which_structField {
 			if sf, _ := def.StructField(); capnp.HasData(sf) {
 				defref = copyData(sf)
@@ -664,7 +678,6 @@ func (n *node) defineField(w io.Writer, f field) {
 
 	case Type_Which_anyPointer:
 		assert(def.Which() == Value_Which_void || def.Which() == Value_Which_anyPointer, "expected object default")
-		var defref staticDataRef
 		if def.Which() == Value_Which_anyPointer {
 			if p, _ := def.AnyPointer(); capnp.HasData(p) {
 				defref = copyData(p)
@@ -677,7 +690,6 @@ func (n *node) defineField(w io.Writer, f field) {
 
 	case Type_Which_list:
 		assert(def.Which() == Value_Which_void || def.Which() == Value_Which_list, "expected list default")
-		var defref staticDataRef
 		if def.Which() == Value_Which_list {
 			if l, _ := def.List(); capnp.HasData(l) {
 				defref = copyData(l)
@@ -691,6 +703,64 @@ func (n *node) defineField(w io.Writer, f field) {
 	case Type_Which_interface:
 		templates.ExecuteTemplate(w, "structInterfaceField", params)
 	}
+
+	if f.Slot().HadExplicitDefault() {
+		n.defineFieldDefault(w, f, t, def, defref)
+	}
+	if ann.Optional {
+		n.defineLookupField(w, params, t)
+	}
+}
+
+// defineFieldDefault writes a Foo_bar_Default declaration holding the
+// schema default of a field.  Pointer defaults are written as the
+// encoded blob the getter already uses, since they have no Go literal.
+func (n *node) defineFieldDefault(w io.Writer, f field, t Type, def Value, defref staticDataRef) {
+	name := n.Name + "_" + f.Name + "_Default"
+	switch t.Which() {
+	case Type_Which_void, Type_Which_interface:
+		return
+	case Type_Which_structGroup, Type_Which_list, Type_Which_anyPointer:
+		if !defref.IsValid() {
+			return
+		}
+		fmt.Fprintf(w, "// %s is the encoded default value of %s.%s.\n", name, n.Name, f.Name)
+		fmt.Fprintf(w, "var %s = %v\n\n", name, defref)
+		return
+	}
+	fmt.Fprintf(w, "// %s is the default value of %s.%s.\n", name, n.Name, f.Name)
+	if typeIsVar(t) {
+		fmt.Fprintf(w, "var %s = ", name)
+	} else {
+		fmt.Fprintf(w, "const %s = ", name)
+	}
+	n.writeValue(w, t, def)
+	fmt.Fprintf(w, "\n\n")
+}
+
+// defineLookupField writes the Lookup accessor for a field with the
+// optional annotation.  Only pointer and union fields can be absent, so
+// other fields get no accessor.  Neither does a field whose accessor
+// would collide with another field's.
+func (n *node) defineLookupField(w io.Writer, params structFieldParams, t Type) {
+	if n.hasFieldAccessor("Lookup" + strings.Title(params.Field.Name)) {
+		return
+	}
+	lp := structLookupFieldParams{structFieldParams: params}
+	switch t.Which() {
+	case Type_Which_void:
+		return
+	case Type_Which_text, Type_Which_data, Type_Which_structGroup, Type_Which_anyPointer, Type_Which_list:
+		lp.IsPointer = true
+	case Type_Which_interface:
+		lp.IsPointer = true
+		lp.IsInterface = true
+	default:
+		if params.Field.DiscriminantValue() == Field_noDiscriminant {
+			return
+		}
+	}
+	templates.ExecuteTemplate(w, "structLookupField", lp)
 }
 
 func (n *node) fieldType(t Type, ann *annotations) string {
@@ -825,6 +895,116 @@ func uintValue(t Type, v Value) uint64 {
 	panic("unreachable")
 }
 
+// unionStorage returns the data and pointer sections of n that are
+// used by the members of n's union other than f.  Selecting f, a Void
+// member, zeroes this storage so that stale values of the previously
+// selected member are not left behind.
+func (n *node) unionStorage(f field) ([]dataClear, []uint16) {
+	var bits bitRanges
+	var ptrs []uint16
+	for _, m := range n.codeOrderFields() {
+		if m.DiscriminantValue() == Field_noDiscriminant || m.CodeOrder() == f.CodeOrder() {
+			continue
+		}
+		bits, ptrs = fieldStorage(m.Field, bits, ptrs)
+	}
+	return bits.clears(), uniquePointers(ptrs)
+}
+
+// fieldStorage appends the data bit ranges and pointer indices that f
+// occupies.  Groups occupy the storage of all their fields.
+func fieldStorage(f Field, bits bitRanges, ptrs []uint16) (bitRanges, []uint16) {
+	if f.Which() == Field_Which_group {
+		g := findNode(f.Group().TypeId())
+		if g.StructGroup().DiscriminantCount() > 0 {
+			off := g.StructGroup().DiscriminantOffset() * 16
+			bits = append(bits, bitRange{off, off + 16})
+		}
+		fields, _ := g.StructGroup().Fields()
+		for i := 0; i < fields.Len(); i++ {
+			bits, ptrs = fieldStorage(fields.At(i), bits, ptrs)
+		}
+		return bits, ptrs
+	}
+	t, _ := f.Slot().Type()
+	off := f.Slot().Offset()
+	switch t.Which() {
+	case Type_Which_void:
+	case Type_Which_bool:
+		bits = append(bits, bitRange{off, off + 1})
+	case Type_Which_uint8, Type_Which_uint16, Type_Which_uint32, Type_Which_uint64,
+		Type_Which_int8, Type_Which_int16, Type_Which_int32, Type_Which_int64:
+		sz := uint32(intbits(t.Which()))
+		bits = append(bits, bitRange{off * sz, (off + 1) * sz})
+	case Type_Which_enum:
+		bits = append(bits, bitRange{off * 16, (off + 1) * 16})
+	case Type_Which_float32:
+		bits = append(bits, bitRange{off * 32, (off + 1) * 32})
+	case Type_Which_float64:
+		bits = append(bits, bitRange{off * 64, (off + 1) * 64})
+	default:
+		ptrs = append(ptrs, uint16(off))
+	}
+	return bits, ptrs
+}
+
+// uniquePointers sorts ptrs and removes duplicates.
+func uniquePointers(ptrs []uint16) []uint16 {
+	for i := 1; i < len(ptrs); i++ {
+		for j := i; j > 0 && ptrs[j] < ptrs[j-1]; j-- {
+			ptrs[j], ptrs[j-1] = ptrs[j-1], ptrs[j]
+		}
+	}
+	out := ptrs[:0]
+	for i, p := range ptrs {
+		if i == 0 || p != ptrs[i-1] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// A bitRange is a half-open range of bits in a struct's data section.
+type bitRange struct {
+	start, end uint32
+}
+
+type bitRanges []bitRange
+
+func (br bitRanges) Len() int           { return len(br) }
+func (br bitRanges) Less(i, j int) bool { return br[i].start < br[j].start }
+func (br bitRanges) Swap(i, j int)      { br[i], br[j] = br[j], br[i] }
+
+// clears returns the fewest aligned writes that zero exactly the bits
+// in br.
+func (br bitRanges) clears() []dataClear {
+	sort.Sort(br)
+	var out []dataClear
+	for i := 0; i < len(br); {
+		// Merge overlapping and adjacent ranges.
+		r := br[i]
+		for i++; i < len(br) && br[i].start <= r.end; i++ {
+			if br[i].end > r.end {
+				r.end = br[i].end
+			}
+		}
+		for pos := r.start; pos < r.end; {
+			w := uint32(64)
+			for w > 8 && (pos%w != 0 || pos+w > r.end) {
+				w /= 2
+			}
+			if pos%w != 0 || pos+w > r.end {
+				out = append(out, dataClear{Bits: 1, Offset: pos})
+				pos++
+				continue
+			}
+			out = append(out, dataClear{Bits: int(w), Offset: pos / 8})
+			pos += w
+		}
+	}
+	return out
+}
+
 func intbits(t Type_Which) int {
 	switch t {
 	case Type_Which_uint8, Type_Which_int8:
@@ -853,6 +1033,19 @@ func (n *node) codeOrderFields() []field {
 	return mbrs
 }
 
+// hasFieldAccessor reports whether one of n's fields has an accessor
+// called name.  Helper methods that the schema doesn't name directly,
+// like CopyFrom or HasFoo, are left out when they would collide with a
+// field, since the field's accessor is the one the schema asked for.
+func (n *node) hasFieldAccessor(name string) bool {
+	for _, f := range n.codeOrderFields() {
+		if strings.Title(f.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (n *node) defineStructTypes(w io.Writer, baseNode *node) {
 	assert(n.Which() == Node_Which_structGroup, "invalid struct node")
 
@@ -940,6 +1133,28 @@ func (n *node) defineStructList(w io.Writer) {
 	})
 }
 
+func (n *node) defineStructCopyFrom(w io.Writer) {
+	assert(n.Which() == Node_Which_structGroup, "invalid struct node")
+	if n.hasFieldAccessor("CopyFrom") {
+		return
+	}
+
+	templates.ExecuteTemplate(w, "structCopyFrom", structCopyFromParams{
+		Node: n,
+	})
+}
+
+func (n *node) defineStructEqual(w io.Writer) {
+	assert(n.Which() == Node_Which_structGroup, "invalid struct node")
+	if n.hasFieldAccessor("Equal") {
+		return
+	}
+
+	templates.ExecuteTemplate(w, "structEqual", structEqualParams{
+		Node: n,
+	})
+}
+
 func (n *node) defineStructPromise(w io.Writer) {
 	templates.ExecuteTemplate(w, "promise", promiseTemplateParams{
 		Node:   n,
@@ -1100,6 +1315,10 @@ func generateFile(reqf CodeGeneratorRequest_RequestedFile) (generr error) {
 				n.defineNewStructFunc(&buf)
 				n.defineStructFuncs(&buf)
 				n.defineStructList(&buf)
+				n.defineStructCopyFrom(&buf)
+				if *genEqual {
+					n.defineStructEqual(&buf)
+				}
 				if *genPromises {
 					n.defineStructPromise(&buf)
 				}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+)
+
+// newStructNode returns a struct node called name with a slot field
+// for each of fieldNames.
+func newStructNode(t *testing.T, name string, fieldNames ...string) *node {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := NewRootNode(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.SetStructGroup()
+	fields, err := NewField_List(seg, int32(len(fieldNames)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, fname := range fieldNames {
+		f := fields.At(i)
+		if err := f.SetName(fname); err != nil {
+			t.Fatal(err)
+		}
+		f.SetCodeOrder(uint16(i))
+		f.SetDiscriminantValue(Field_noDiscriminant)
+		f.SetSlot()
+	}
+	if err := n.StructGroup().SetFields(fields); err != nil {
+		t.Fatal(err)
+	}
+	return &node{Node: n, Name: name}
+}
+
+func TestDefineStructEqual(t *testing.T) {
+	g_imports.init()
+	var buf bytes.Buffer
+	newStructNode(t, "Foo", "num", "bar").defineStructEqual(&buf)
+	const want = "\n" +
+		"// Equal reports whether s and o hold equal data and pointers, following\n" +
+		"// pointers into nested structs and lists.  See capnp.Struct.Equal.\n" +
+		"func (s Foo) Equal(o Foo) (bool, error) { return s.Struct.Equal(o.Struct) }\n"
+	if got := buf.String(); got != want {
+		t.Errorf("defineStructEqual output:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	newStructNode(t, "Foo", "num", "equal").defineStructEqual(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("defineStructEqual with an equal field output:\n%s\nwant nothing", buf.String())
+	}
+}
+
+func TestHasFieldTemplate(t *testing.T) {
+	g_imports.init()
+	var buf bytes.Buffer
+	n := newStructNode(t, "Foo", "foo", "bar")
+	err := templates.ExecuteTemplate(&buf, "hasfield", structFieldParams{Node: n, Field: n.codeOrderFields()[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "\n" +
+		"func (s Foo) HasFoo() bool {\n" +
+		"\treturn s.Struct.HasPointer(0)\n" +
+		"}\n"
+	if got := buf.String(); got != want {
+		t.Errorf("hasfield output:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	n = newStructNode(t, "Foo", "foo", "hasFoo")
+	err = templates.ExecuteTemplate(&buf, "hasfield", structFieldParams{Node: n, Field: n.codeOrderFields()[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("hasfield with a hasFoo field output:\n%s\nwant nothing", buf.String())
+	}
+}
+
+func TestTextFieldTemplateBytes(t *testing.T) {
+	g_imports.init()
+	var buf bytes.Buffer
+	n := newStructNode(t, "Foo", "text")
+	params := structTextFieldParams{structFieldParams: structFieldParams{Node: n, Field: n.codeOrderFields()[0]}}
+	if err := templates.ExecuteTemplate(&buf, "structTextField", params); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), ") TextBytes() ([]byte, error) {") {
+		t.Errorf("structTextField output has no TextBytes method:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	n = newStructNode(t, "Foo", "text", "textBytes")
+	params = structTextFieldParams{structFieldParams: structFieldParams{Node: n, Field: n.codeOrderFields()[0]}}
+	if err := templates.ExecuteTemplate(&buf, "structTextField", params); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "TextBytes()") {
+		t.Errorf("structTextField with a textBytes field output a TextBytes method:\n%s", buf.String())
+	}
+}
+
+func TestDefineLookupField(t *testing.T) {
+	g_imports.init()
+	lookup := func(fieldNames ...string) string {
+		n := newStructNode(t, "Foo", fieldNames...)
+		f := n.codeOrderFields()[0]
+		typ, err := f.Slot().NewType()
+		if err != nil {
+			t.Fatal(err)
+		}
+		typ.SetText()
+		var buf bytes.Buffer
+		n.defineLookupField(&buf, structFieldParams{Node: n, Field: f, FieldType: "string"}, typ)
+		return buf.String()
+	}
+	if out := lookup("foo"); !strings.Contains(out, ") LookupFoo() (v string, ok bool, err error) {") {
+		t.Errorf("defineLookupField output has no LookupFoo method:\n%s", out)
+	}
+	if out := lookup("foo", "lookupFoo"); out != "" {
+		t.Errorf("defineLookupField with a lookupFoo field output:\n%s\nwant nothing", out)
+	}
+}
+
+func TestStructFuncsTemplate(t *testing.T) {
+	g_imports.init()
+	funcs := func(fieldNames ...string) string {
+		n := newStructNode(t, "Foo", fieldNames...)
+		n.StructGroup().SetDiscriminantCount(2)
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, "structFuncs", structFuncsParams{Node: n}); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+	out := funcs("a", "b")
+	if !strings.Contains(out, "if w >= 2 {\n\t\treturn Foo_Which_Unknown") {
+		t.Errorf("structFuncs output does not check for unknown discriminants:\n%s", out)
+	}
+	if !strings.Contains(out, "func (s Foo) WhichRaw() uint16 {\n\treturn s.Struct.Uint16(0)\n}") {
+		t.Errorf("structFuncs output has no WhichRaw method:\n%s", out)
+	}
+	if out := funcs("a", "whichRaw"); strings.Contains(out, "WhichRaw()") {
+		t.Errorf("structFuncs with a whichRaw field output a WhichRaw method:\n%s", out)
+	}
+}
+
+func TestDefineStructCopyFrom(t *testing.T) {
+	g_imports.init()
+	var buf bytes.Buffer
+	newStructNode(t, "Foo", "num").defineStructCopyFrom(&buf)
+	const want = "\n" +
+		"// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.\n" +
+		"func (s Foo) CopyFrom(src Foo) error { return s.Struct.CopyFrom(src.Struct) }\n"
+	if got := buf.String(); got != want {
+		t.Errorf("defineStructCopyFrom output:\n%s\nwant:\n%s", got, want)
+	}
+
+	buf.Reset()
+	newStructNode(t, "Foo", "copyFrom").defineStructCopyFrom(&buf)
+	if buf.Len() != 0 {
+		t.Errorf("defineStructCopyFrom with a copyFrom field output:\n%s\nwant nothing", buf.String())
+	}
+}
@@ -3,7 +3,6 @@ package main
 // AUTO GENERATED - DO NOT EDIT
 
 import (
-	math "math"
 	strconv "strconv"
 	capnp "zombiezen.com/go/capnproto2"
 )
@@ -27,6 +26,13 @@ const (
 	Node_Which_interface   Node_Which = 3
 	Node_Which_const       Node_Which = 4
 	Node_Which_annotation  Node_Which = 5
+
+	// Node_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Node_Which_Unknown Node_Which = 0xffff
 )
 
 func (w Node_Which) String() string {
@@ -75,7 +81,17 @@ func ReadRootNode(msg *capnp.Message) (Node, error) {
 }
 
 func (s Node) Which() Node_Which {
-	return Node_Which(s.Struct.Uint16(12))
+	w := Node_Which(s.Struct.Uint16(12))
+	if w >= 6 {
+		return Node_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Node_Which_Unknown.
+func (s Node) WhichRaw() uint16 {
+	return s.Struct.Uint16(12)
 }
 
 func (s Node) Id() uint64 {
@@ -97,6 +113,22 @@ func (s Node) DisplayName() (string, error) {
 
 }
 
+// DisplayNameBytes returns the displayName field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Node) DisplayNameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Node) HasDisplayName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Node) SetDisplayName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -135,6 +167,10 @@ func (s Node) Parameters() (Node_Parameter_List, error) {
 	return Node_Parameter_List{List: l}, nil
 }
 
+func (s Node) HasParameters() bool {
+	return s.Struct.HasPointer(5)
+}
+
 func (s Node) SetParameters(v Node_Parameter_List) error {
 
 	return s.Struct.SetPointer(5, v.List)
@@ -160,6 +196,10 @@ func (s Node) NestedNodes() (Node_NestedNode_List, error) {
 	return Node_NestedNode_List{List: l}, nil
 }
 
+func (s Node) HasNestedNodes() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Node) SetNestedNodes(v Node_NestedNode_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -176,6 +216,10 @@ func (s Node) Annotations() (Annotation_List, error) {
 	return Annotation_List{List: l}, nil
 }
 
+func (s Node) HasAnnotations() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s Node) SetAnnotations(v Annotation_List) error {
 
 	return s.Struct.SetPointer(2, v.List)
@@ -183,6 +227,13 @@ func (s Node) SetAnnotations(v Annotation_List) error {
 
 func (s Node) SetFile() {
 	s.Struct.SetUint16(12, 0)
+	s.Struct.SetUint16(14, 0)
+	s.Struct.SetUint32(24, 0)
+	s.Struct.SetBit(224, false)
+	s.Struct.SetUint16(30, 0)
+	s.Struct.SetUint32(32, 0)
+	s.Struct.SetPointer(3, nil)
+	s.Struct.SetPointer(4, nil)
 }
 func (s Node) StructGroup() Node_structGroup { return Node_structGroup(s) }
 
@@ -253,6 +304,10 @@ func (s Node_structGroup) Fields() (Field_List, error) {
 	return Field_List{List: l}, nil
 }
 
+func (s Node_structGroup) HasFields() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Node_structGroup) SetFields(v Field_List) error {
 
 	return s.Struct.SetPointer(3, v.List)
@@ -272,6 +327,10 @@ func (s Node_enum) Enumerants() (Enumerant_List, error) {
 	return Enumerant_List{List: l}, nil
 }
 
+func (s Node_enum) HasEnumerants() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Node_enum) SetEnumerants(v Enumerant_List) error {
 
 	return s.Struct.SetPointer(3, v.List)
@@ -291,6 +350,10 @@ func (s Node_interface) Methods() (Method_List, error) {
 	return Method_List{List: l}, nil
 }
 
+func (s Node_interface) HasMethods() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Node_interface) SetMethods(v Method_List) error {
 
 	return s.Struct.SetPointer(3, v.List)
@@ -307,6 +370,10 @@ func (s Node_interface) Superclasses() (Superclass_List, error) {
 	return Superclass_List{List: l}, nil
 }
 
+func (s Node_interface) HasSuperclasses() bool {
+	return s.Struct.HasPointer(4)
+}
+
 func (s Node_interface) SetSuperclasses(v Superclass_List) error {
 
 	return s.Struct.SetPointer(4, v.List)
@@ -326,6 +393,10 @@ func (s Node_const) Type() (Type, error) {
 	return Type{Struct: ss}, nil
 }
 
+func (s Node_const) HasType() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Node_const) SetType(v Type) error {
 
 	return s.Struct.SetPointer(3, v.Struct)
@@ -354,6 +425,10 @@ func (s Node_const) Value() (Value, error) {
 	return Value{Struct: ss}, nil
 }
 
+func (s Node_const) HasValue() bool {
+	return s.Struct.HasPointer(4)
+}
+
 func (s Node_const) SetValue(v Value) error {
 
 	return s.Struct.SetPointer(4, v.Struct)
@@ -385,6 +460,10 @@ func (s Node_annotation) Type() (Type, error) {
 	return Type{Struct: ss}, nil
 }
 
+func (s Node_annotation) HasType() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Node_annotation) SetType(v Type) error {
 
 	return s.Struct.SetPointer(3, v.Struct)
@@ -525,6 +604,9 @@ func NewNode_List(s *capnp.Segment, sz int32) (Node_List, error) {
 func (s Node_List) At(i int) Node           { return Node{s.List.Struct(i)} }
 func (s Node_List) Set(i int, v Node) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Node) CopyFrom(src Node) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Node_Parameter struct{ capnp.Struct }
 
 func NewNode_Parameter(s *capnp.Segment) (Node_Parameter, error) {
@@ -562,6 +644,22 @@ func (s Node_Parameter) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Node_Parameter) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Node_Parameter) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Node_Parameter) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -586,6 +684,9 @@ func NewNode_Parameter_List(s *capnp.Segment, sz int32) (Node_Parameter_List, er
 func (s Node_Parameter_List) At(i int) Node_Parameter           { return Node_Parameter{s.List.Struct(i)} }
 func (s Node_Parameter_List) Set(i int, v Node_Parameter) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Node_Parameter) CopyFrom(src Node_Parameter) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Node_NestedNode struct{ capnp.Struct }
 
 func NewNode_NestedNode(s *capnp.Segment) (Node_NestedNode, error) {
@@ -623,6 +724,22 @@ func (s Node_NestedNode) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Node_NestedNode) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Node_NestedNode) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Node_NestedNode) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -658,6 +775,9 @@ func (s Node_NestedNode_List) Set(i int, v Node_NestedNode) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Node_NestedNode) CopyFrom(src Node_NestedNode) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Field struct{ capnp.Struct }
 type Field_slot Field
 type Field_group Field
@@ -667,6 +787,13 @@ type Field_Which uint16
 const (
 	Field_Which_slot  Field_Which = 0
 	Field_Which_group Field_Which = 1
+
+	// Field_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Field_Which_Unknown Field_Which = 0xffff
 )
 
 func (w Field_Which) String() string {
@@ -686,6 +813,13 @@ type Field_ordinal_Which uint16
 const (
 	Field_ordinal_Which_implicit Field_ordinal_Which = 0
 	Field_ordinal_Which_explicit Field_ordinal_Which = 1
+
+	// Field_ordinal_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Field_ordinal_Which_Unknown Field_ordinal_Which = 0xffff
 )
 
 func (w Field_ordinal_Which) String() string {
@@ -726,7 +860,17 @@ func ReadRootField(msg *capnp.Message) (Field, error) {
 }
 
 func (s Field) Which() Field_Which {
-	return Field_Which(s.Struct.Uint16(8))
+	w := Field_Which(s.Struct.Uint16(8))
+	if w >= 2 {
+		return Field_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Field_Which_Unknown.
+func (s Field) WhichRaw() uint16 {
+	return s.Struct.Uint16(8)
 }
 
 func (s Field) Name() (string, error) {
@@ -739,6 +883,22 @@ func (s Field) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Field) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Field) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Field) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -768,6 +928,10 @@ func (s Field) Annotations() (Annotation_List, error) {
 	return Annotation_List{List: l}, nil
 }
 
+func (s Field) HasAnnotations() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Field) SetAnnotations(v Annotation_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -781,6 +945,10 @@ func (s Field) SetDiscriminantValue(v uint16) {
 
 	s.Struct.SetUint16(2, v^65535)
 }
+
+// Field_discriminantValue_Default is the default value of Field.discriminantValue.
+const Field_discriminantValue_Default = uint16(65535)
+
 func (s Field) Slot() Field_slot { return Field_slot(s) }
 
 func (s Field) SetSlot() { s.Struct.SetUint16(8, 0) }
@@ -805,6 +973,10 @@ func (s Field_slot) Type() (Type, error) {
 	return Type{Struct: ss}, nil
 }
 
+func (s Field_slot) HasType() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s Field_slot) SetType(v Type) error {
 
 	return s.Struct.SetPointer(2, v.Struct)
@@ -833,6 +1005,10 @@ func (s Field_slot) DefaultValue() (Value, error) {
 	return Value{Struct: ss}, nil
 }
 
+func (s Field_slot) HasDefaultValue() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Field_slot) SetDefaultValue(v Value) error {
 
 	return s.Struct.SetPointer(3, v.Struct)
@@ -873,11 +1049,22 @@ func (s Field_group) SetTypeId(v uint64) {
 func (s Field) Ordinal() Field_ordinal { return Field_ordinal(s) }
 
 func (s Field_ordinal) Which() Field_ordinal_Which {
-	return Field_ordinal_Which(s.Struct.Uint16(10))
+	w := Field_ordinal_Which(s.Struct.Uint16(10))
+	if w >= 2 {
+		return Field_ordinal_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Field_ordinal_Which_Unknown.
+func (s Field_ordinal) WhichRaw() uint16 {
+	return s.Struct.Uint16(10)
 }
 
 func (s Field_ordinal) SetImplicit() {
 	s.Struct.SetUint16(10, 0)
+	s.Struct.SetUint16(12, 0)
 }
 
 func (s Field_ordinal) Explicit() uint16 {
@@ -904,6 +1091,9 @@ func NewField_List(s *capnp.Segment, sz int32) (Field_List, error) {
 func (s Field_List) At(i int) Field           { return Field{s.List.Struct(i)} }
 func (s Field_List) Set(i int, v Field) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Field) CopyFrom(src Field) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Enumerant struct{ capnp.Struct }
 
 func NewEnumerant(s *capnp.Segment) (Enumerant, error) {
@@ -941,6 +1131,22 @@ func (s Enumerant) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Enumerant) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Enumerant) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Enumerant) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -970,6 +1176,10 @@ func (s Enumerant) Annotations() (Annotation_List, error) {
 	return Annotation_List{List: l}, nil
 }
 
+func (s Enumerant) HasAnnotations() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Enumerant) SetAnnotations(v Annotation_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -990,6 +1200,9 @@ func NewEnumerant_List(s *capnp.Segment, sz int32) (Enumerant_List, error) {
 func (s Enumerant_List) At(i int) Enumerant           { return Enumerant{s.List.Struct(i)} }
 func (s Enumerant_List) Set(i int, v Enumerant) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Enumerant) CopyFrom(src Enumerant) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Superclass struct{ capnp.Struct }
 
 func NewSuperclass(s *capnp.Segment) (Superclass, error) {
@@ -1037,6 +1250,10 @@ func (s Superclass) Brand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Superclass) HasBrand() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Superclass) SetBrand(v Brand) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1069,6 +1286,9 @@ func NewSuperclass_List(s *capnp.Segment, sz int32) (Superclass_List, error) {
 func (s Superclass_List) At(i int) Superclass           { return Superclass{s.List.Struct(i)} }
 func (s Superclass_List) Set(i int, v Superclass) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Superclass) CopyFrom(src Superclass) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Method struct{ capnp.Struct }
 
 func NewMethod(s *capnp.Segment) (Method, error) {
@@ -1106,6 +1326,22 @@ func (s Method) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Method) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Method) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Method) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -1135,6 +1371,10 @@ func (s Method) ImplicitParameters() (Node_Parameter_List, error) {
 	return Node_Parameter_List{List: l}, nil
 }
 
+func (s Method) HasImplicitParameters() bool {
+	return s.Struct.HasPointer(4)
+}
+
 func (s Method) SetImplicitParameters(v Node_Parameter_List) error {
 
 	return s.Struct.SetPointer(4, v.List)
@@ -1160,6 +1400,10 @@ func (s Method) ParamBrand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Method) HasParamBrand() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s Method) SetParamBrand(v Brand) error {
 
 	return s.Struct.SetPointer(2, v.Struct)
@@ -1197,6 +1441,10 @@ func (s Method) ResultBrand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Method) HasResultBrand() bool {
+	return s.Struct.HasPointer(3)
+}
+
 func (s Method) SetResultBrand(v Brand) error {
 
 	return s.Struct.SetPointer(3, v.Struct)
@@ -1225,6 +1473,10 @@ func (s Method) Annotations() (Annotation_List, error) {
 	return Annotation_List{List: l}, nil
 }
 
+func (s Method) HasAnnotations() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Method) SetAnnotations(v Annotation_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -1245,6 +1497,9 @@ func NewMethod_List(s *capnp.Segment, sz int32) (Method_List, error) {
 func (s Method_List) At(i int) Method           { return Method{s.List.Struct(i)} }
 func (s Method_List) Set(i int, v Method) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Method) CopyFrom(src Method) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Type struct{ capnp.Struct }
 type Type_list Type
 type Type_enum Type
@@ -1275,6 +1530,13 @@ const (
 	Type_Which_structGroup Type_Which = 16
 	Type_Which_interface   Type_Which = 17
 	Type_Which_anyPointer  Type_Which = 18
+
+	// Type_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Type_Which_Unknown Type_Which = 0xffff
 )
 
 func (w Type_Which) String() string {
@@ -1329,6 +1591,13 @@ const (
 	Type_anyPointer_Which_unconstrained           Type_anyPointer_Which = 0
 	Type_anyPointer_Which_parameter               Type_anyPointer_Which = 1
 	Type_anyPointer_Which_implicitMethodParameter Type_anyPointer_Which = 2
+
+	// Type_anyPointer_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Type_anyPointer_Which_Unknown Type_anyPointer_Which = 0xffff
 )
 
 func (w Type_anyPointer_Which) String() string {
@@ -1371,63 +1640,115 @@ func ReadRootType(msg *capnp.Message) (Type, error) {
 }
 
 func (s Type) Which() Type_Which {
-	return Type_Which(s.Struct.Uint16(0))
+	w := Type_Which(s.Struct.Uint16(0))
+	if w >= 19 {
+		return Type_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Type_Which_Unknown.
+func (s Type) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Type) SetVoid() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetBool() {
 	s.Struct.SetUint16(0, 1)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetInt8() {
 	s.Struct.SetUint16(0, 2)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetInt16() {
 	s.Struct.SetUint16(0, 3)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetInt32() {
 	s.Struct.SetUint16(0, 4)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetInt64() {
 	s.Struct.SetUint16(0, 5)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetUint8() {
 	s.Struct.SetUint16(0, 6)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetUint16() {
 	s.Struct.SetUint16(0, 7)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetUint32() {
 	s.Struct.SetUint16(0, 8)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetUint64() {
 	s.Struct.SetUint16(0, 9)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetFloat32() {
 	s.Struct.SetUint16(0, 10)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetFloat64() {
 	s.Struct.SetUint16(0, 11)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetText() {
 	s.Struct.SetUint16(0, 12)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Type) SetData() {
 	s.Struct.SetUint16(0, 13)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetUint64(16, 0)
+	s.Struct.SetPointer(0, nil)
 }
 func (s Type) List() Type_list { return Type_list(s) }
 
@@ -1444,6 +1765,10 @@ func (s Type_list) ElementType() (Type, error) {
 	return Type{Struct: ss}, nil
 }
 
+func (s Type_list) HasElementType() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Type_list) SetElementType(v Type) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1484,6 +1809,10 @@ func (s Type_enum) Brand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Type_enum) HasBrand() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Type_enum) SetBrand(v Brand) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1524,6 +1853,10 @@ func (s Type_structGroup) Brand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Type_structGroup) HasBrand() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Type_structGroup) SetBrand(v Brand) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1564,6 +1897,10 @@ func (s Type_interface) Brand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Type_interface) HasBrand() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Type_interface) SetBrand(v Brand) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1585,11 +1922,23 @@ func (s Type) AnyPointer() Type_anyPointer { return Type_anyPointer(s) }
 func (s Type) SetAnyPointer() { s.Struct.SetUint16(0, 18) }
 
 func (s Type_anyPointer) Which() Type_anyPointer_Which {
-	return Type_anyPointer_Which(s.Struct.Uint16(8))
+	w := Type_anyPointer_Which(s.Struct.Uint16(8))
+	if w >= 3 {
+		return Type_anyPointer_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Type_anyPointer_Which_Unknown.
+func (s Type_anyPointer) WhichRaw() uint16 {
+	return s.Struct.Uint16(8)
 }
 
 func (s Type_anyPointer) SetUnconstrained() {
 	s.Struct.SetUint16(8, 0)
+	s.Struct.SetUint16(10, 0)
+	s.Struct.SetUint64(16, 0)
 }
 func (s Type_anyPointer) Parameter() Type_anyPointer_parameter { return Type_anyPointer_parameter(s) }
 
@@ -1642,6 +1991,9 @@ func NewType_List(s *capnp.Segment, sz int32) (Type_List, error) {
 func (s Type_List) At(i int) Type           { return Type{s.List.Struct(i)} }
 func (s Type_List) Set(i int, v Type) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Type) CopyFrom(src Type) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Brand struct{ capnp.Struct }
 
 func NewBrand(s *capnp.Segment) (Brand, error) {
@@ -1680,6 +2032,10 @@ func (s Brand) Scopes() (Brand_Scope_List, error) {
 	return Brand_Scope_List{List: l}, nil
 }
 
+func (s Brand) HasScopes() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Brand) SetScopes(v Brand_Scope_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -1700,12 +2056,22 @@ func NewBrand_List(s *capnp.Segment, sz int32) (Brand_List, error) {
 func (s Brand_List) At(i int) Brand           { return Brand{s.List.Struct(i)} }
 func (s Brand_List) Set(i int, v Brand) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Brand) CopyFrom(src Brand) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Brand_Scope struct{ capnp.Struct }
 type Brand_Scope_Which uint16
 
 const (
 	Brand_Scope_Which_bind    Brand_Scope_Which = 0
 	Brand_Scope_Which_inherit Brand_Scope_Which = 1
+
+	// Brand_Scope_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Brand_Scope_Which_Unknown Brand_Scope_Which = 0xffff
 )
 
 func (w Brand_Scope_Which) String() string {
@@ -1746,7 +2112,17 @@ func ReadRootBrand_Scope(msg *capnp.Message) (Brand_Scope, error) {
 }
 
 func (s Brand_Scope) Which() Brand_Scope_Which {
-	return Brand_Scope_Which(s.Struct.Uint16(8))
+	w := Brand_Scope_Which(s.Struct.Uint16(8))
+	if w >= 2 {
+		return Brand_Scope_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Brand_Scope_Which_Unknown.
+func (s Brand_Scope) WhichRaw() uint16 {
+	return s.Struct.Uint16(8)
 }
 
 func (s Brand_Scope) ScopeId() uint64 {
@@ -1769,6 +2145,13 @@ func (s Brand_Scope) Bind() (Brand_Binding_List, error) {
 	return Brand_Binding_List{List: l}, nil
 }
 
+func (s Brand_Scope) HasBind() bool {
+	if s.Which() != Brand_Scope_Which_bind {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Brand_Scope) SetBind(v Brand_Binding_List) error {
 	s.Struct.SetUint16(8, 0)
 	return s.Struct.SetPointer(0, v.List)
@@ -1776,6 +2159,7 @@ func (s Brand_Scope) SetBind(v Brand_Binding_List) error {
 
 func (s Brand_Scope) SetInherit() {
 	s.Struct.SetUint16(8, 1)
+	s.Struct.SetPointer(0, nil)
 }
 
 // Brand_Scope_List is a list of Brand_Scope.
@@ -1793,12 +2177,22 @@ func NewBrand_Scope_List(s *capnp.Segment, sz int32) (Brand_Scope_List, error) {
 func (s Brand_Scope_List) At(i int) Brand_Scope           { return Brand_Scope{s.List.Struct(i)} }
 func (s Brand_Scope_List) Set(i int, v Brand_Scope) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Brand_Scope) CopyFrom(src Brand_Scope) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Brand_Binding struct{ capnp.Struct }
 type Brand_Binding_Which uint16
 
 const (
 	Brand_Binding_Which_unbound Brand_Binding_Which = 0
 	Brand_Binding_Which_type    Brand_Binding_Which = 1
+
+	// Brand_Binding_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Brand_Binding_Which_Unknown Brand_Binding_Which = 0xffff
 )
 
 func (w Brand_Binding_Which) String() string {
@@ -1839,11 +2233,22 @@ func ReadRootBrand_Binding(msg *capnp.Message) (Brand_Binding, error) {
 }
 
 func (s Brand_Binding) Which() Brand_Binding_Which {
-	return Brand_Binding_Which(s.Struct.Uint16(0))
+	w := Brand_Binding_Which(s.Struct.Uint16(0))
+	if w >= 2 {
+		return Brand_Binding_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Brand_Binding_Which_Unknown.
+func (s Brand_Binding) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Brand_Binding) SetUnbound() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Brand_Binding) Type() (Type, error) {
@@ -1857,6 +2262,13 @@ func (s Brand_Binding) Type() (Type, error) {
 	return Type{Struct: ss}, nil
 }
 
+func (s Brand_Binding) HasType() bool {
+	if s.Which() != Brand_Binding_Which_type {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Brand_Binding) SetType(v Type) error {
 	s.Struct.SetUint16(0, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1889,6 +2301,9 @@ func NewBrand_Binding_List(s *capnp.Segment, sz int32) (Brand_Binding_List, erro
 func (s Brand_Binding_List) At(i int) Brand_Binding           { return Brand_Binding{s.List.Struct(i)} }
 func (s Brand_Binding_List) Set(i int, v Brand_Binding) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Brand_Binding) CopyFrom(src Brand_Binding) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Value struct{ capnp.Struct }
 type Value_Which uint16
 
@@ -1912,6 +2327,13 @@ const (
 	Value_Which_structField Value_Which = 16
 	Value_Which_interface   Value_Which = 17
 	Value_Which_anyPointer  Value_Which = 18
+
+	// Value_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Value_Which_Unknown Value_Which = 0xffff
 )
 
 func (w Value_Which) String() string {
@@ -1986,11 +2408,25 @@ func ReadRootValue(msg *capnp.Message) (Value, error) {
 }
 
 func (s Value) Which() Value_Which {
-	return Value_Which(s.Struct.Uint16(0))
+	w := Value_Which(s.Struct.Uint16(0))
+	if w >= 19 {
+		return Value_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Value_Which_Unknown.
+func (s Value) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Value) SetVoid() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetUint16(2, 0)
+	s.Struct.SetUint32(4, 0)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Value) Bool() bool {
@@ -2075,21 +2511,21 @@ func (s Value) SetUint64(v uint64) {
 }
 
 func (s Value) Float32() float32 {
-	return math.Float32frombits(s.Struct.Uint32(4))
+	return s.Struct.Float32(4)
 }
 
 func (s Value) SetFloat32(v float32) {
 	s.Struct.SetUint16(0, 10)
-	s.Struct.SetUint32(4, math.Float32bits(v))
+	s.Struct.SetFloat32(4, v)
 }
 
 func (s Value) Float64() float64 {
-	return math.Float64frombits(s.Struct.Uint64(8))
+	return s.Struct.Float64(8)
 }
 
 func (s Value) SetFloat64(v float64) {
 	s.Struct.SetUint16(0, 11)
-	s.Struct.SetUint64(8, math.Float64bits(v))
+	s.Struct.SetFloat64(8, v)
 }
 
 func (s Value) Text() (string, error) {
@@ -2102,6 +2538,25 @@ func (s Value) Text() (string, error) {
 
 }
 
+// TextBytes returns the text field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Value) TextBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Value) HasText() bool {
+	if s.Which() != Value_Which_text {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Value) SetText(v string) error {
 	s.Struct.SetUint16(0, 12)
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -2121,6 +2576,13 @@ func (s Value) Data() ([]byte, error) {
 
 }
 
+func (s Value) HasData() bool {
+	if s.Which() != Value_Which_data {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Value) SetData(v []byte) error {
 	s.Struct.SetUint16(0, 13)
 	d, err := capnp.NewData(s.Struct.Segment(), []byte(v))
@@ -2136,6 +2598,13 @@ func (s Value) List() (capnp.Pointer, error) {
 
 }
 
+func (s Value) HasList() bool {
+	if s.Which() != Value_Which_list {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Value) SetList(v capnp.Pointer) error {
 	s.Struct.SetUint16(0, 14)
 	return s.Struct.SetPointer(0, v)
@@ -2156,6 +2625,13 @@ func (s Value) StructField() (capnp.Pointer, error) {
 
 }
 
+func (s Value) HasStructField() bool {
+	if s.Which() != Value_Which_structField {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Value) SetStructField(v capnp.Pointer) error {
 	s.Struct.SetUint16(0, 16)
 	return s.Struct.SetPointer(0, v)
@@ -2163,6 +2639,10 @@ func (s Value) SetStructField(v capnp.Pointer) error {
 
 func (s Value) SetInterface() {
 	s.Struct.SetUint16(0, 17)
+	s.Struct.SetUint16(2, 0)
+	s.Struct.SetUint32(4, 0)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Value) AnyPointer() (capnp.Pointer, error) {
@@ -2171,6 +2651,13 @@ func (s Value) AnyPointer() (capnp.Pointer, error) {
 
 }
 
+func (s Value) HasAnyPointer() bool {
+	if s.Which() != Value_Which_anyPointer {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Value) SetAnyPointer(v capnp.Pointer) error {
 	s.Struct.SetUint16(0, 18)
 	return s.Struct.SetPointer(0, v)
@@ -2191,6 +2678,9 @@ func NewValue_List(s *capnp.Segment, sz int32) (Value_List, error) {
 func (s Value_List) At(i int) Value           { return Value{s.List.Struct(i)} }
 func (s Value_List) Set(i int, v Value) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Value) CopyFrom(src Value) error { return s.Struct.CopyFrom(src.Struct) }
+
 type Annotation struct{ capnp.Struct }
 
 func NewAnnotation(s *capnp.Segment) (Annotation, error) {
@@ -2238,6 +2728,10 @@ func (s Annotation) Brand() (Brand, error) {
 	return Brand{Struct: ss}, nil
 }
 
+func (s Annotation) HasBrand() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Annotation) SetBrand(v Brand) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -2266,6 +2760,10 @@ func (s Annotation) Value() (Value, error) {
 	return Value{Struct: ss}, nil
 }
 
+func (s Annotation) HasValue() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Annotation) SetValue(v Value) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2298,6 +2796,9 @@ func NewAnnotation_List(s *capnp.Segment, sz int32) (Annotation_List, error) {
 func (s Annotation_List) At(i int) Annotation           { return Annotation{s.List.Struct(i)} }
 func (s Annotation_List) Set(i int, v Annotation) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Annotation) CopyFrom(src Annotation) error { return s.Struct.CopyFrom(src.Struct) }
+
 type ElementSize uint16
 
 // Values of ElementSize.
@@ -2421,6 +2922,10 @@ func (s CodeGeneratorRequest) Nodes() (Node_List, error) {
 	return Node_List{List: l}, nil
 }
 
+func (s CodeGeneratorRequest) HasNodes() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s CodeGeneratorRequest) SetNodes(v Node_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2437,6 +2942,10 @@ func (s CodeGeneratorRequest) RequestedFiles() (CodeGeneratorRequest_RequestedFi
 	return CodeGeneratorRequest_RequestedFile_List{List: l}, nil
 }
 
+func (s CodeGeneratorRequest) HasRequestedFiles() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s CodeGeneratorRequest) SetRequestedFiles(v CodeGeneratorRequest_RequestedFile_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -2461,6 +2970,11 @@ func (s CodeGeneratorRequest_List) Set(i int, v CodeGeneratorRequest) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CodeGeneratorRequest) CopyFrom(src CodeGeneratorRequest) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 type CodeGeneratorRequest_RequestedFile struct{ capnp.Struct }
 
 func NewCodeGeneratorRequest_RequestedFile(s *capnp.Segment) (CodeGeneratorRequest_RequestedFile, error) {
@@ -2507,6 +3021,22 @@ func (s CodeGeneratorRequest_RequestedFile) Filename() (string, error) {
 
 }
 
+// FilenameBytes returns the filename field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s CodeGeneratorRequest_RequestedFile) FilenameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s CodeGeneratorRequest_RequestedFile) HasFilename() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s CodeGeneratorRequest_RequestedFile) SetFilename(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -2527,6 +3057,10 @@ func (s CodeGeneratorRequest_RequestedFile) Imports() (CodeGeneratorRequest_Requ
 	return CodeGeneratorRequest_RequestedFile_Import_List{List: l}, nil
 }
 
+func (s CodeGeneratorRequest_RequestedFile) HasImports() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s CodeGeneratorRequest_RequestedFile) SetImports(v CodeGeneratorRequest_RequestedFile_Import_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -2551,6 +3085,11 @@ func (s CodeGeneratorRequest_RequestedFile_List) Set(i int, v CodeGeneratorReque
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CodeGeneratorRequest_RequestedFile) CopyFrom(src CodeGeneratorRequest_RequestedFile) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 type CodeGeneratorRequest_RequestedFile_Import struct{ capnp.Struct }
 
 func NewCodeGeneratorRequest_RequestedFile_Import(s *capnp.Segment) (CodeGeneratorRequest_RequestedFile_Import, error) {
@@ -2597,6 +3136,22 @@ func (s CodeGeneratorRequest_RequestedFile_Import) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s CodeGeneratorRequest_RequestedFile_Import) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s CodeGeneratorRequest_RequestedFile_Import) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s CodeGeneratorRequest_RequestedFile_Import) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -2624,3 +3179,8 @@ func (s CodeGeneratorRequest_RequestedFile_Import_List) At(i int) CodeGeneratorR
 func (s CodeGeneratorRequest_RequestedFile_Import_List) Set(i int, v CodeGeneratorRequest_RequestedFile_Import) error {
 	return s.List.SetStruct(i, v.Struct)
 }
+
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CodeGeneratorRequest_RequestedFile_Import) CopyFrom(src CodeGeneratorRequest_RequestedFile_Import) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
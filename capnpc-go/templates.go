@@ -19,6 +19,9 @@ var templates = template.Must(template.New("").Funcs(template.FuncMap{
 	"discriminantOffset": func(n *node) uint32 {
 		return n.StructGroup().DiscriminantOffset() * 2
 	},
+	"hasFieldAccessor": func(n *node, name string) bool {
+		return n.hasFieldAccessor(name)
+	},
 }).Parse(`
 {{define "enum"}}{{with .Annotations.Doc}}// {{.}}
 {{end}}type {{.Node.Name}} uint16
@@ -108,15 +111,35 @@ func ReadRoot{{.Node.Name}}(msg *{{capnp}}.Message) ({{.Node.Name}}, error) {
 {{define "structFuncs"}}
 {{if gt .Node.StructGroup.DiscriminantCount 0}}
 func (s {{.Node.Name}}) Which() {{.Node.Name}}_Which {
-	return {{.Node.Name}}_Which(s.Struct.Uint16({{discriminantOffset .Node}}))
+	w := {{.Node.Name}}_Which(s.Struct.Uint16({{discriminantOffset .Node}}))
+	if w >= {{.Node.StructGroup.DiscriminantCount}} {
+		return {{.Node.Name}}_Which_Unknown
+	}
+	return w
 }
-{{end}}
+{{if not (hasFieldAccessor .Node "WhichRaw")}}
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as {{.Node.Name}}_Which_Unknown.
+func (s {{.Node.Name}}) WhichRaw() uint16 {
+	return s.Struct.Uint16({{discriminantOffset .Node}})
+}
+{{end}}{{end}}
 {{end}}
 
 
 {{define "settag"}}{{if hasDiscriminant .Field}}s.Struct.SetUint16({{discriminantOffset .Node}}, {{.Field.DiscriminantValue}}){{end}}{{end}}
 
 
+{{define "hasfield"}}{{if not (hasFieldAccessor .Node (printf "Has%s" (title .Field.Name)))}}
+func (s {{.Node.Name}}) Has{{.Field.Name|title}}() bool {
+	{{if hasDiscriminant .Field}}if s.Which() != {{.Node.Name}}_Which_{{.Field.Name}} {
+		return false
+	}
+	{{end}}return s.Struct.HasPointer({{.Field.Slot.Offset}})
+}
+{{end}}{{end}}
+
+
 {{define "structGroup"}}func (s {{.Node.Name}}) {{.Field.Name|title}}() {{.Group.Name}} { return {{.Group.Name}}(s) }
 {{if hasDiscriminant .Field}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}() { {{template "settag" .}} }
@@ -126,10 +149,26 @@ func (s {{.Node.Name}}) Set{{.Field.Name|title}}() { {{template "settag" .}} }
 {{define "structVoidField"}}{{if hasDiscriminant .Field}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}() {
 	{{template "settag" .}}
-}
+{{range .ClearData}}{{if eq .Bits 1}}	s.Struct.SetBit({{.Offset}}, false)
+{{else}}	s.Struct.SetUint{{.Bits}}({{.Offset}}, 0)
+{{end}}{{end}}{{range .ClearPointers}}	s.Struct.SetPointer({{.}}, nil)
+{{end}}}
 {{end}}{{end}}
 
 
+{{define "structLookupField"}}
+func (s {{.Node.Name}}) Lookup{{.Field.Name|title}}() (v {{.FieldType}}, ok bool{{if .IsPointer}}, err error{{end}}) {
+	if {{if hasDiscriminant .Field}}s.Which() != {{.Node.Name}}_Which_{{.Field.Name}}{{if .IsPointer}} || {{end}}{{end}}{{if .IsPointer}}!s.Struct.HasPointer({{.Field.Slot.Offset}}){{end}} {
+		return
+	}
+{{if .IsInterface}}	return s.{{.Field.Name|title}}(), true, nil
+{{else}}{{if .IsPointer}}	v, err = s.{{.Field.Name|title}}()
+	return v, true, err
+{{else}}	return s.{{.Field.Name|title}}(), true
+{{end}}{{end}}}
+{{end}}
+
+
 {{define "structBoolField"}}
 func (s {{.Node.Name}}) {{.Field.Name|title}}() bool {
 	return {{if .Default}}!{{end}}s.Struct.Bit({{.Field.Slot.Offset}})
@@ -168,12 +207,12 @@ func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{.ReturnType}}) {
 
 {{define "structFloatField"}}
 func (s {{.Node.Name}}) {{.Field.Name|title}}() float{{.Bits}} {
-	return {{math}}.Float{{.Bits}}frombits(s.Struct.Uint{{.Bits}}({{.Offset}}){{with .Default}} ^ {{printf "%#x" .}}{{end}})
+	{{if .Default}}return {{math}}.Float{{.Bits}}frombits(s.Struct.Uint{{.Bits}}({{.Offset}}) ^ {{printf "%#x" .Default}}){{else}}return s.Struct.Float{{.Bits}}({{.Offset}}){{end}}
 }
 
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v float{{.Bits}}) {
 	{{template "settag" .}}
-	s.Struct.SetUint{{.Bits}}({{.Offset}}, {{math}}.Float{{.Bits}}bits(v){{with .Default}}^{{printf "%#x" .}}{{end}})
+	{{if .Default}}s.Struct.SetUint{{.Bits}}({{.Offset}}, {{math}}.Float{{.Bits}}bits(v)^{{printf "%#x" .Default}}){{else}}s.Struct.SetFloat{{.Bits}}({{.Offset}}, v){{end}}
 }
 {{end}}
 
@@ -185,12 +224,26 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() (string, error) {
 		return "", err
 	}
 	{{with .Default}}
-	return {{capnp}}.ToTextDefault(p, {{printf "%q" .}})
+	return {{capnp}}.ToTextDefault(p, {{printf "%q" .}}), nil
 	{{else}}
 	return {{capnp}}.ToText(p), nil
 	{{end}}
 }
-
+{{if not (hasFieldAccessor .Node (printf "%sBytes" (title .Field.Name)))}}
+// {{.Field.Name|title}}Bytes returns the {{.Field.Name}} field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s {{.Node.Name}}) {{.Field.Name|title}}Bytes() ([]byte, error) {
+	p, err := s.Struct.Pointer({{.Field.Slot.Offset}})
+	if err != nil {
+		return nil, err
+	}
+	{{with .Default}}
+	return {{capnp}}.ToTextBytesDefault(p, {{printf "%q" .}}), nil
+	{{else}}
+	return {{capnp}}.ToTextBytes(p), nil
+	{{end}}
+}
+{{end}}{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v string) error {
 	{{template "settag" .}}
 	t, err := {{capnp}}.NewText(s.Struct.Segment(), v)
@@ -215,7 +268,7 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() ({{.FieldType}}, error) {
 	return {{.FieldType}}({{capnp}}.ToData(p)), nil
 	{{end}}
 }
-
+{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{.FieldType}}) error {
 	{{template "settag" .}}
 	d, err := {{capnp}}.NewData(s.Struct.Segment(), []byte(v))
@@ -243,7 +296,7 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() ({{.FieldType}}, error) {
 	{{end}}
 	return {{.FieldType}}{Struct: ss}, nil
 }
-
+{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{.FieldType}}) error {
 	{{template "settag" .}}
 	return s.Struct.SetPointer({{.Field.Slot.Offset}}, v.Struct)
@@ -275,7 +328,7 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() ({{capnp}}.Pointer, error) {
 	return s.Struct.Pointer({{.Field.Slot.Offset}})
 	{{end}}
 }
-
+{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{capnp}}.Pointer) error {
 	{{template "settag" .}}
 	return s.Struct.SetPointer({{.Field.Slot.Offset}}, v)
@@ -299,7 +352,7 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() ({{.FieldType}}, error) {
 	{{end}}
 	return {{.FieldType}}{List: l}, nil
 }
-
+{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{.FieldType}}) error {
 	{{template "settag" .}}
 	return s.Struct.SetPointer({{.Field.Slot.Offset}}, v.List)
@@ -317,7 +370,7 @@ func (s {{.Node.Name}}) {{.Field.Name|title}}() {{.FieldType}} {
 	c := {{capnp}}.ToInterface(p).Client()
 	return {{.FieldType}}{Client: c}
 }
-
+{{template "hasfield" .}}
 func (s {{.Node.Name}}) Set{{.Field.Name|title}}(v {{.FieldType}}) error {
 	{{template "settag" .}}
 	seg := s.Segment()
@@ -348,11 +401,29 @@ func (s {{.Node.Name}}_List) Set(i int, v {{.Node.Name}}) error { return s.List.
 {{end}}
 
 
+{{define "structCopyFrom"}}
+// CopyFrom makes s a deep copy of src.  See {{capnp}}.Struct.CopyFrom.
+func (s {{.Node.Name}}) CopyFrom(src {{.Node.Name}}) error { return s.Struct.CopyFrom(src.Struct) }
+{{end}}
+
+{{define "structEqual"}}
+// Equal reports whether s and o hold equal data and pointers, following
+// pointers into nested structs and lists.  See {{capnp}}.Struct.Equal.
+func (s {{.Node.Name}}) Equal(o {{.Node.Name}}) (bool, error) { return s.Struct.Equal(o.Struct) }
+{{end}}
+
+
 {{define "structEnums"}}type {{.Node.Name}}_Which uint16
 
 const (
 {{range .Fields}}	{{$.Node.Name}}_Which_{{.Name}} {{$.Node.Name}}_Which = {{.DiscriminantValue}}
 {{end}}
+	// {{.Node.Name}}_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	{{.Node.Name}}_Which_Unknown {{.Node.Name}}_Which = 0xffff
 )
 
 func (w {{.Node.Name}}_Which) String() string {
@@ -519,6 +590,26 @@ type structFieldParams struct {
 	FieldType   string
 }
 
+type structVoidFieldParams struct {
+	structFieldParams
+	ClearData     []dataClear
+	ClearPointers []uint16
+}
+
+// dataClear is a write that zeroes part of a struct's data section.
+// If Bits is 1, then Offset is a bit offset; otherwise it is a byte
+// offset.
+type dataClear struct {
+	Bits   int
+	Offset uint32
+}
+
+type structLookupFieldParams struct {
+	structFieldParams
+	IsPointer   bool
+	IsInterface bool
+}
+
 type structBoolFieldParams struct {
 	structFieldParams
 	Default bool
@@ -566,6 +657,14 @@ type structListParams struct {
 	Node *node
 }
 
+type structCopyFromParams struct {
+	Node *node
+}
+
+type structEqualParams struct {
+	Node *node
+}
+
 type structEnumsParams struct {
 	Node       *node
 	Fields     []field
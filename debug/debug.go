@@ -0,0 +1,256 @@
+// Package debug provides tools for inspecting the wire format of
+// Cap'n Proto messages.
+package debug
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+
+	"zombiezen.com/go/capnproto2"
+)
+
+const wordSize = 8
+
+// maxDepth limits how deeply Dump follows pointers, so that malformed
+// messages with pointer cycles still produce output.
+const maxDepth = 64
+
+// Dump writes an annotated hex dump of msg's serialized form to w.
+// The stream header is printed first, followed by each segment's data
+// in rows of one word, each prefixed with its byte offset in the
+// segment.
+//
+// Dump has no access to the message's schema, so it finds pointers by
+// following them from the root pointer.  Each pointer word that is
+// reachable this way is labeled with its kind (struct, list, far or
+// capability) and decoded fields, as are list tags and far pointer
+// landing pads.  Data words are left unlabeled, and words that aren't
+// reachable from the root, such as orphaned objects, are not labeled.
+// The output only depends on the message's contents, so it is suitable
+// for golden tests.
+func Dump(msg *capnp.Message, w io.Writer) error {
+	plan, err := msg.SegmentsForOutput()
+	if err != nil {
+		return err
+	}
+	segs := make([][]byte, len(plan.SegmentSizes))
+	for i := range segs {
+		seg, err := msg.Segment(capnp.SegmentID(i))
+		if err != nil {
+			return err
+		}
+		segs[i] = seg.Data()
+	}
+	a := newAnnotator(segs)
+	a.pointer(0, 0, "root ", 0)
+
+	d := &dumper{w: w}
+	d.printf("header (%s): %s\n", plural(int(plan.HeaderSize), "byte"), plural(len(segs), "segment"))
+	d.words(plan.AppendHeader(nil), nil)
+	for i, data := range segs {
+		d.printf("segment %d (%s, %s)\n", i, plural(len(data), "byte"), plural(len(data)/wordSize, "word"))
+		d.words(data, a.notes[i])
+	}
+	return d.err
+}
+
+// An annotator labels the words of a message's segments by following
+// pointers.
+type annotator struct {
+	segs  [][]byte
+	notes []map[int][]string
+	seen  map[[2]int]bool
+}
+
+func newAnnotator(segs [][]byte) *annotator {
+	return &annotator{
+		segs:  segs,
+		notes: make([]map[int][]string, len(segs)),
+		seen:  make(map[[2]int]bool),
+	}
+}
+
+func (a *annotator) note(seg, word int, format string, args ...interface{}) {
+	if a.notes[seg] == nil {
+		a.notes[seg] = make(map[int][]string)
+	}
+	a.notes[seg][word] = append(a.notes[seg][word], fmt.Sprintf(format, args...))
+}
+
+func (a *annotator) word(seg, word int) (uint64, bool) {
+	if seg < 0 || seg >= len(a.segs) || word < 0 || word >= len(a.segs[seg])/wordSize {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(a.segs[seg][word*wordSize:]), true
+}
+
+// wordsLeft returns the number of words in seg from word to the end of
+// the segment.
+func (a *annotator) wordsLeft(seg, word int) int {
+	if seg < 0 || seg >= len(a.segs) || word < 0 || word >= len(a.segs[seg])/wordSize {
+		return 0
+	}
+	return len(a.segs[seg])/wordSize - word
+}
+
+// pointer labels the pointer at the given word and follows it.
+func (a *annotator) pointer(seg, word int, prefix string, depth int) {
+	if depth > maxDepth || a.seen[[2]int{seg, word}] {
+		return
+	}
+	raw, ok := a.word(seg, word)
+	if !ok {
+		return
+	}
+	a.seen[[2]int{seg, word}] = true
+	if raw == 0 {
+		a.note(seg, word, "%snull pointer", prefix)
+		return
+	}
+	switch raw & 3 {
+	case 0, 1:
+		a.note(seg, word, "%s%s", prefix, describe(raw))
+		a.object(seg, word, seg, word+1+offset(raw), raw, depth)
+	case 2:
+		padSeg, pad := int(raw>>32), int(uint32(raw)>>3)
+		if raw&4 == 0 {
+			a.note(seg, word, "%sfar pointer: landing pad at segment %d, word %d", prefix, padSeg, pad)
+			a.pointer(padSeg, pad, "landing pad: ", depth+1)
+			return
+		}
+		a.note(seg, word, "%sdouble-far pointer: landing pad at segment %d, word %d", prefix, padSeg, pad)
+		a.farPad(padSeg, pad, depth+1)
+	default:
+		if uint32(raw) == 3 {
+			a.note(seg, word, "%scapability pointer: index %d", prefix, raw>>32)
+		} else {
+			a.note(seg, word, "%sunknown pointer", prefix)
+		}
+	}
+}
+
+// farPad labels a double-far landing pad and follows it to its object.
+func (a *annotator) farPad(seg, word int, depth int) {
+	pad, ok := a.word(seg, word)
+	if !ok {
+		return
+	}
+	tag, ok := a.word(seg, word+1)
+	if !ok {
+		return
+	}
+	contentSeg, content := int(pad>>32), int(uint32(pad)>>3)
+	a.note(seg, word, "landing pad: far pointer to segment %d, word %d", contentSeg, content)
+	a.note(seg, word+1, "landing pad tag: %s", describe(tag))
+	a.object(seg, word+1, contentSeg, content, tag, depth)
+}
+
+// object follows the pointers inside the object that starts at the
+// given word and is described by the struct or list pointer raw.  The
+// pointer is at word at of segment atSeg, where notes about the object
+// as a whole are placed.
+func (a *annotator) object(atSeg, at, seg, start int, raw uint64, depth int) {
+	if raw&3 == 0 {
+		dataWords, ptrs := int(uint16(raw>>32)), int(uint16(raw>>48))
+		for i := 0; i < ptrs; i++ {
+			a.pointer(seg, start+dataWords+i, "", depth+1)
+		}
+		return
+	}
+	count := int(raw >> 35)
+	switch (raw >> 32) & 7 {
+	case 6:
+		if left := a.wordsLeft(seg, start); count > left {
+			a.note(atSeg, at, "%s out of bounds", plural(count-left, "element"))
+			count = left
+		}
+		for i := 0; i < count; i++ {
+			a.pointer(seg, start+i, "", depth+1)
+		}
+	case 7:
+		tag, ok := a.word(seg, start)
+		if !ok {
+			return
+		}
+		n, dataWords, ptrs := int(uint32(tag)>>2), int(uint16(tag>>32)), int(uint16(tag>>48))
+		a.note(seg, start, "list tag: %s, %s, %s", plural(n, "element"), plural(dataWords, "data word"), plural(ptrs, "pointer"))
+		if left := a.wordsLeft(seg, start+1); count > left {
+			a.note(atSeg, at, "%s out of bounds", plural(count-left, "word"))
+			count = left
+		}
+		size := dataWords + ptrs
+		for i := 0; i < n && size > 0 && (i+1)*size <= count; i++ {
+			for j := 0; j < ptrs; j++ {
+				a.pointer(seg, start+1+i*size+dataWords+j, "", depth+1)
+			}
+		}
+	}
+}
+
+// offset returns the signed word offset of a struct or list pointer.
+func offset(raw uint64) int {
+	return int(int32(uint32(raw)) >> 2)
+}
+
+var elementSizes = [...]string{
+	"void",
+	"1-bit",
+	"1-byte",
+	"2-byte",
+	"4-byte",
+	"8-byte",
+	"pointer",
+	"composite",
+}
+
+// describe formats a struct or list pointer.
+func describe(raw uint64) string {
+	if raw&3 == 0 {
+		return fmt.Sprintf("struct pointer: offset %d, %s, %s",
+			offset(raw), plural(int(uint16(raw>>32)), "data word"), plural(int(uint16(raw>>48)), "pointer"))
+	}
+	sz := (raw >> 32) & 7
+	if sz == 7 {
+		return fmt.Sprintf("list pointer: offset %d, composite, %s", offset(raw), plural(int(raw>>35), "word"))
+	}
+	return fmt.Sprintf("list pointer: offset %d, %s, %s", offset(raw), elementSizes[sz], plural(int(raw>>35), "element"))
+}
+
+// plural formats n followed by noun, adding an "s" unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// A dumper writes formatted output, stopping at the first error.
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, args...)
+}
+
+// words prints b in rows of one word.  notes maps word indices to
+// annotations printed at the end of the row.
+func (d *dumper) words(b []byte, notes map[int][]string) {
+	for i := 0; i < len(b); i += wordSize {
+		end := i + wordSize
+		if end > len(b) {
+			end = len(b)
+		}
+		d.printf("  %06x: % 02x", i, b[i:end])
+		if n := notes[i/wordSize]; len(n) > 0 {
+			d.printf("  ; %s", strings.Join(n, "; "))
+		}
+		d.printf("\n")
+	}
+}
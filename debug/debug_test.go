@@ -0,0 +1,139 @@
+package debug
+
+import (
+	"bytes"
+	"testing"
+
+	"zombiezen.com/go/capnproto2"
+)
+
+func TestDump(t *testing.T) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0x0102030405060708)
+
+	var buf bytes.Buffer
+	if err := Dump(msg, &buf); err != nil {
+		t.Fatal("Dump:", err)
+	}
+	const want = "header (8 bytes): 1 segment\n" +
+		"  000000: 00 00 00 00 02 00 00 00\n" +
+		"segment 0 (16 bytes, 2 words)\n" +
+		"  000000: 00 00 00 00 01 00 00 00  ; root struct pointer: offset 0, 1 data word, 0 pointers\n" +
+		"  000008: 08 07 06 05 04 03 02 01\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Dump output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpPointers(t *testing.T) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{PointerCount: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := capnp.NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	l, err := capnp.NewCompositeList(seg, capnp.ObjectSize{DataSize: 8}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(1, l); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(2, capnp.NewInterface(seg, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Dump(msg, &buf); err != nil {
+		t.Fatal("Dump:", err)
+	}
+	const want = "header (8 bytes): 1 segment\n" +
+		"  000000: 00 00 00 00 09 00 00 00\n" +
+		"segment 0 (72 bytes, 9 words)\n" +
+		"  000000: 00 00 00 00 00 00 04 00  ; root struct pointer: offset 0, 0 data words, 4 pointers\n" +
+		"  000008: 0d 00 00 00 1a 00 00 00  ; list pointer: offset 3, 1-byte, 3 elements\n" +
+		"  000010: 0d 00 00 00 17 00 00 00  ; list pointer: offset 3, composite, 2 words\n" +
+		"  000018: 03 00 00 00 05 00 00 00  ; capability pointer: index 5\n" +
+		"  000020: 00 00 00 00 00 00 00 00  ; null pointer\n" +
+		"  000028: 68 69 00 00 00 00 00 00\n" +
+		"  000030: 08 00 00 00 01 00 00 00  ; list tag: 2 elements, 1 data word, 0 pointers\n" +
+		"  000038: 00 00 00 00 00 00 00 00\n" +
+		"  000040: 00 00 00 00 00 00 00 00\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Dump output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpFarPointer(t *testing.T) {
+	// The first segment only has room for the root pointer, so the root
+	// struct is placed in a second segment.
+	msg, seg, err := capnp.NewMessage(capnp.MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := capnp.NewRootStruct(seg, capnp.ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 42)
+
+	var buf bytes.Buffer
+	if err := Dump(msg, &buf); err != nil {
+		t.Fatal("Dump:", err)
+	}
+	const want = "header (16 bytes): 2 segments\n" +
+		"  000000: 01 00 00 00 01 00 00 00\n" +
+		"  000008: 02 00 00 00 00 00 00 00\n" +
+		"segment 0 (8 bytes, 1 word)\n" +
+		"  000000: 0a 00 00 00 01 00 00 00  ; root far pointer: landing pad at segment 1, word 1\n" +
+		"segment 1 (16 bytes, 2 words)\n" +
+		"  000000: 2a 00 00 00 00 00 00 00\n" +
+		"  000008: f8 ff ff ff 01 00 00 00  ; landing pad: struct pointer: offset -2, 1 data word, 0 pointers\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Dump output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestDumpEmpty(t *testing.T) {
+	msg := &capnp.Message{Arena: capnp.MultiSegment(nil)}
+	if err := Dump(msg, new(bytes.Buffer)); err == nil {
+		t.Error("Dump of empty message succeeded; want error")
+	}
+}
+
+func TestDumpListOutOfBounds(t *testing.T) {
+	// The root pointer is a pointer list that claims 2^29-1 elements,
+	// but only one word follows it.
+	msg := &capnp.Message{Arena: capnp.SingleSegment([]byte{
+		0x01, 0x00, 0x00, 0x00, 0xfe, 0xff, 0xff, 0xff,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	})}
+	var buf bytes.Buffer
+	if err := Dump(msg, &buf); err != nil {
+		t.Fatal("Dump:", err)
+	}
+	const want = "header (8 bytes): 1 segment\n" +
+		"  000000: 00 00 00 00 02 00 00 00\n" +
+		"segment 0 (16 bytes, 2 words)\n" +
+		"  000000: 01 00 00 00 fe ff ff ff  ; root list pointer: offset 0, pointer, 536870911 elements; 536870910 elements out of bounds\n" +
+		"  000008: 00 00 00 00 00 00 00 00  ; null pointer\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Dump output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -139,6 +139,9 @@ capnpc-go will generate:
 	// Bar returns a promise for that bar field.
 	func (p Foo_Promise) Bar() Foo_Promise
 
+capnpc-go also generates a CopyFrom method for each struct, and an
+Equal method if it is run with the -equal flag.  Either method is left
+out if one of the struct's fields has an accessor with the same name.
 
 Groups
 
@@ -341,6 +344,13 @@ capnpc-go also generates code to implement the interface:
 	// slice is too small, a new slice is returned.
 	func Calculator_Methods(methods []server.Method, s Calculator_Server) []server.Method
 
+Contexts flow through both sides of a call.  The ctx passed to a client
+method is used for the call: canceling it cancels the call, and over an
+RPC connection the cancellation is sent to the remote vat.  A server
+method receives its context in the Ctx field of its Call argument.  Ctx
+is canceled when the caller cancels the call or its connection closes,
+so server code should pass Ctx to any blocking work it starts.
+
 Since a single capability may want to implement many interfaces, you can
 use multiple *_Methods functions to build a single slice to send to
 NewServer.
@@ -0,0 +1,128 @@
+package capnp
+
+import (
+	"bytes"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Dump returns a human-readable rendering of root and every object
+// reachable from it, for debugging.  Without a schema, fields are named
+// by position: a struct is written as (d0 = ..., p0 = ...), listing its
+// data words in hex followed by its pointers, and a list is written in
+// brackets.  Byte lists that hold NUL-terminated UTF-8, as Text does,
+// are written as quoted strings.  Interface pointers are written as
+// their capability.
+func Dump(root Struct) (string, error) {
+	var buf bytes.Buffer
+	if err := dumpPointer(&buf, root, 0); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func dumpPointer(buf *bytes.Buffer, p Pointer, depth int) error {
+	if !IsValid(p) {
+		buf.WriteString("null")
+		return nil
+	}
+	if depth >= maxSizeDepth {
+		return errDumpDepth
+	}
+	switch p := p.underlying().(type) {
+	case Interface:
+		buf.WriteString("<capability ")
+		buf.WriteString(strconv.FormatUint(uint64(p.cap), 10))
+		buf.WriteByte('>')
+		return nil
+	case Struct:
+		return dumpStruct(buf, p, depth)
+	case List:
+		return dumpList(buf, p, depth)
+	default:
+		panic("unreachable")
+	}
+}
+
+func dumpStruct(buf *bytes.Buffer, s Struct, depth int) error {
+	buf.WriteByte('(')
+	data := s.seg.slice(s.off, s.size.DataSize)
+	n := 0
+	for i := 0; i < len(data); i += int(wordSize) {
+		if n > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("d")
+		buf.WriteString(strconv.Itoa(n))
+		buf.WriteString(" = 0x")
+		var w uint64
+		for j := i; j < len(data) && j < i+int(wordSize); j++ {
+			w |= uint64(data[j]) << (8 * uint(j-i))
+		}
+		buf.WriteString(strconv.FormatUint(w, 16))
+		n++
+	}
+	for i := uint16(0); i < s.size.PointerCount; i++ {
+		if n > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("p")
+		buf.WriteString(strconv.Itoa(int(i)))
+		buf.WriteString(" = ")
+		p, err := s.Pointer(i)
+		if err != nil {
+			return err
+		}
+		if err := dumpPointer(buf, p, depth+1); err != nil {
+			return err
+		}
+		n++
+	}
+	buf.WriteByte(')')
+	return nil
+}
+
+func dumpList(buf *bytes.Buffer, l List, depth int) error {
+	kind := l.Kind()
+	if kind == ByteListKind {
+		b := l.seg.slice(l.off, Size(l.length))
+		if n := len(b); n > 0 && b[n-1] == 0 && bytes.IndexByte(b[:n-1], 0) == -1 && utf8.Valid(b[:n-1]) {
+			buf.WriteString(strconv.Quote(string(b[:n-1])))
+			return nil
+		}
+	}
+	buf.WriteByte('[')
+	for i := 0; i < l.Len(); i++ {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		switch kind {
+		case VoidListKind:
+			buf.WriteString("void")
+		case BitListKind:
+			buf.WriteString(strconv.FormatBool(BitList{l}.At(i)))
+		case ByteListKind:
+			buf.WriteString(strconv.FormatUint(uint64(UInt8List{l}.At(i)), 10))
+		case TwoByteListKind:
+			buf.WriteString(strconv.FormatUint(uint64(UInt16List{l}.At(i)), 10))
+		case FourByteListKind:
+			buf.WriteString(strconv.FormatUint(uint64(UInt32List{l}.At(i)), 10))
+		case EightByteListKind:
+			buf.WriteString(strconv.FormatUint(UInt64List{l}.At(i), 10))
+		case PointerListKind:
+			p, err := PointerList{l}.At(i)
+			if err != nil {
+				return err
+			}
+			if err := dumpPointer(buf, p, depth+1); err != nil {
+				return err
+			}
+		case CompositeListKind:
+			if err := dumpStruct(buf, l.Struct(i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	buf.WriteByte(']')
+	return nil
+}
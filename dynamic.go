@@ -0,0 +1,284 @@
+package capnp
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+// FieldKind is the type of a field described by a FieldSchema.
+type FieldKind uint8
+
+// Field kinds.  Struct, list, and AnyPointer fields all use
+// PointerField.
+const (
+	VoidField FieldKind = iota
+	BoolField
+	Int8Field
+	Int16Field
+	Int32Field
+	Int64Field
+	Uint8Field
+	Uint16Field
+	Uint32Field
+	Uint64Field
+	Float32Field
+	Float64Field
+	TextField
+	DataField
+	PointerField
+	InterfaceField
+)
+
+// NoDiscriminant is the FieldSchema.Discriminant of a field that is not
+// a member of its struct's union.
+const NoDiscriminant = 0xffff
+
+// A FieldSchema describes how a field is stored in its struct, using
+// the same conventions as the slot fields of a schema node.
+type FieldSchema struct {
+	Name string
+	Kind FieldKind
+
+	// Offset is the field's position in its section, in multiples of
+	// the field's size: bits for BoolField, bytes for Int8Field,
+	// pointers for pointer kinds, and so on.
+	Offset uint32
+
+	// Default is the bits of the field's default value, which are
+	// XORed with the stored value.  It is ignored for pointer kinds.
+	Default uint64
+
+	// Discriminant is the union discriminant value that selects the
+	// field, or NoDiscriminant if the field is not in a union.
+	Discriminant uint16
+}
+
+// A StructSchema describes the fields of a struct type, so that a
+// struct can be read without generated code.  It is usually built from
+// a schema node loaded at runtime.
+type StructSchema struct {
+	Fields []FieldSchema
+
+	// DiscriminantOffset is the position of the union discriminant in
+	// the data section, in multiples of 16 bits.  It is only used if
+	// some field has a discriminant.
+	DiscriminantOffset uint32
+}
+
+// A DynamicStruct reads the fields of a struct by name, using a
+// StructSchema to find where each field is stored.
+type DynamicStruct struct {
+	s   Struct
+	typ *StructSchema
+}
+
+// NewDynamicStruct returns a DynamicStruct that reads s as typeDesc
+// describes it.
+func NewDynamicStruct(s Struct, typeDesc *StructSchema) *DynamicStruct {
+	return &DynamicStruct{s: s, typ: typeDesc}
+}
+
+// Struct returns the underlying struct.
+func (d *DynamicStruct) Struct() Struct {
+	return d.s
+}
+
+// Get returns the value of the field with the given name.  Get
+// returns an error if the struct has no such field, or if the field is
+// a union member that isn't selected.
+func (d *DynamicStruct) Get(name string) (Value, error) {
+	f := d.typ.field(name)
+	if f == nil {
+		return Value{}, errors.New("capnp: no field " + strconv.Quote(name))
+	}
+	if f.Discriminant != NoDiscriminant {
+		if uint16(d.data(d.typ.DiscriminantOffset, 2)) != f.Discriminant {
+			return Value{}, errors.New("capnp: union field " + strconv.Quote(name) + " is not set")
+		}
+	}
+	switch f.Kind {
+	case VoidField:
+		return Value{kind: VoidField}, nil
+	case BoolField:
+		var b uint64
+		if uint64(f.Offset)/8 < uint64(d.s.size.DataSize) && d.s.Bit(BitOffset(f.Offset)) {
+			b = 1
+		}
+		return Value{kind: BoolField, bits: b ^ f.Default&1}, nil
+	case Int8Field, Uint8Field:
+		return Value{kind: f.Kind, bits: d.data(f.Offset, 1) ^ f.Default&math.MaxUint8}, nil
+	case Int16Field, Uint16Field:
+		return Value{kind: f.Kind, bits: d.data(f.Offset, 2) ^ f.Default&math.MaxUint16}, nil
+	case Int32Field, Uint32Field, Float32Field:
+		return Value{kind: f.Kind, bits: d.data(f.Offset, 4) ^ f.Default&math.MaxUint32}, nil
+	case Int64Field, Uint64Field, Float64Field:
+		return Value{kind: f.Kind, bits: d.data(f.Offset, 8) ^ f.Default}, nil
+	case TextField, DataField, PointerField, InterfaceField:
+		if f.Offset >= uint32(d.s.size.PointerCount) {
+			return Value{kind: f.Kind}, nil
+		}
+		p, err := d.s.Pointer(uint16(f.Offset))
+		if err != nil {
+			return Value{}, err
+		}
+		return Value{kind: f.Kind, ptr: p}, nil
+	default:
+		return Value{}, errors.New("capnp: field " + strconv.Quote(name) + " has unknown kind")
+	}
+}
+
+// data returns the width-byte slot at the given offset in the data
+// section, measured in multiples of width, or zero if the slot is past
+// the end of the data section.
+func (d *DynamicStruct) data(off uint32, width uint64) uint64 {
+	start := uint64(off) * width
+	if start+width > uint64(d.s.size.DataSize) {
+		return 0
+	}
+	switch width {
+	case 1:
+		return uint64(d.s.Uint8(DataOffset(start)))
+	case 2:
+		return uint64(d.s.Uint16(DataOffset(start)))
+	case 4:
+		return uint64(d.s.Uint32(DataOffset(start)))
+	default:
+		return d.s.Uint64(DataOffset(start))
+	}
+}
+
+// A Value is the value of a field read by DynamicStruct.Get.  Its
+// accessors return an error if the value is not of the kind they
+// read.
+type Value struct {
+	kind FieldKind
+	bits uint64  // for non-pointer kinds, the field's bits
+	ptr  Pointer // for pointer kinds
+}
+
+// Kind returns the kind of the field that v was read from.
+func (v Value) Kind() FieldKind {
+	return v.kind
+}
+
+// IsNull reports whether v is a text, data, pointer, or interface
+// value whose pointer is null.
+func (v Value) IsNull() bool {
+	switch v.kind {
+	case TextField, DataField, PointerField, InterfaceField:
+		return !IsValid(v.ptr)
+	default:
+		return false
+	}
+}
+
+// Bool returns the value of a BoolField.
+func (v Value) Bool() (bool, error) {
+	if v.kind != BoolField {
+		return false, errValueKind
+	}
+	return v.bits != 0, nil
+}
+
+// Int returns the value of an Int8Field through Int64Field, sign
+// extended to 64 bits.
+func (v Value) Int() (int64, error) {
+	switch v.kind {
+	case Int8Field:
+		return int64(int8(v.bits)), nil
+	case Int16Field:
+		return int64(int16(v.bits)), nil
+	case Int32Field:
+		return int64(int32(v.bits)), nil
+	case Int64Field:
+		return int64(v.bits), nil
+	default:
+		return 0, errValueKind
+	}
+}
+
+// Uint returns the value of a Uint8Field through Uint64Field.  Enums
+// are stored as Uint16Field.
+func (v Value) Uint() (uint64, error) {
+	switch v.kind {
+	case Uint8Field, Uint16Field, Uint32Field, Uint64Field:
+		return v.bits, nil
+	default:
+		return 0, errValueKind
+	}
+}
+
+// Float returns the value of a Float32Field or Float64Field.
+func (v Value) Float() (float64, error) {
+	switch v.kind {
+	case Float32Field:
+		return float64(math.Float32frombits(uint32(v.bits))), nil
+	case Float64Field:
+		return math.Float64frombits(v.bits), nil
+	default:
+		return 0, errValueKind
+	}
+}
+
+// Text returns the value of a TextField.
+func (v Value) Text() (string, error) {
+	if v.kind != TextField {
+		return "", errValueKind
+	}
+	return ToText(v.ptr), nil
+}
+
+// Data returns the value of a DataField.
+func (v Value) Data() ([]byte, error) {
+	if v.kind != DataField {
+		return nil, errValueKind
+	}
+	return ToData(v.ptr), nil
+}
+
+// Struct returns the value of a PointerField that points to a struct
+// or is null.
+func (v Value) Struct() (Struct, error) {
+	if v.kind != PointerField {
+		return Struct{}, errValueKind
+	}
+	if IsValid(v.ptr) {
+		if _, ok := v.ptr.underlying().(Struct); !ok {
+			return Struct{}, errValueKind
+		}
+	}
+	return ToStruct(v.ptr), nil
+}
+
+// List returns the value of a PointerField that points to a list or
+// is null.
+func (v Value) List() (List, error) {
+	if v.kind != PointerField {
+		return List{}, errValueKind
+	}
+	if IsValid(v.ptr) {
+		if _, ok := v.ptr.underlying().(List); !ok {
+			return List{}, errValueKind
+		}
+	}
+	return ToList(v.ptr), nil
+}
+
+// Interface returns the value of an InterfaceField.
+func (v Value) Interface() (Interface, error) {
+	if v.kind != InterfaceField {
+		return Interface{}, errValueKind
+	}
+	return ToInterface(v.ptr), nil
+}
+
+// field returns the field with the given name, or nil if there is none.
+func (typ *StructSchema) field(name string) *FieldSchema {
+	for i := range typ.Fields {
+		if typ.Fields[i].Name == name {
+			return &typ.Fields[i]
+		}
+	}
+	return nil
+}
@@ -9,4 +9,5 @@ const (
 	Notag      = uint64(0xc8768679ec52e012)
 	Customtype = uint64(0xfa10659ae02f2093)
 	Name       = uint64(0xc2b96012172f8df1)
+	Optional   = uint64(0xa4dea1e40fe68bce)
 )
@@ -1361,6 +1361,13 @@ func TestDefaultStructField(t *testing.T) {
 	})
 }
 
+func TestFieldDefaultConstants(t *testing.T) {
+	a := air.StackingA{Struct: capnp.ToStruct(capnp.MustUnmarshalRoot(air.StackingRoot_aWithDefault_Default))}
+	if n := a.Num(); n != 42 {
+		t.Errorf("StackingRoot_aWithDefault_Default num = %d; want 42", n)
+	}
+}
+
 func TestDataTextCopyOptimization(t *testing.T) {
 	cv.Convey("Given a text list from a different segment", t, func() {
 		cv.Convey("Adding it to a different segment shouldn't panic", func() {
@@ -1813,3 +1820,220 @@ func TestVoidUnionSetters(t *testing.T) {
 		})
 	})
 }
+
+func TestUnionVoidClearsStorage(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := air.NewRootZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z.SetF64(3.5)
+	z.SetVoid()
+	if w := z.Which(); w != air.Z_Which_void {
+		t.Errorf("after SetF64 then SetVoid, Which() = %v; want void", w)
+	}
+	if v := z.Struct.Uint64(8); v != 0 {
+		t.Errorf("after SetF64 then SetVoid, union data = %#x; want 0", v)
+	}
+
+	if err := z.SetText("hello"); err != nil {
+		t.Fatal(err)
+	}
+	z.SetVoid()
+	if w := z.Which(); w != air.Z_Which_void {
+		t.Errorf("after SetText then SetVoid, Which() = %v; want void", w)
+	}
+	if z.Struct.HasPointer(0) {
+		t.Error("after SetText then SetVoid, union pointer is still set")
+	}
+}
+
+func TestLookupAccessors(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := air.NewRootZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := z.LookupF64(); ok {
+		t.Error("LookupF64 on void union ok = true")
+	}
+	z.SetF64(2.5)
+	if v, ok := z.LookupF64(); !ok || v != 2.5 {
+		t.Errorf("LookupF64() = %v, %t; want 2.5, true", v, ok)
+	}
+	if _, ok, err := z.LookupText(); ok || err != nil {
+		t.Errorf("LookupText while f64 is set = _, %t, %v; want false, <nil>", ok, err)
+	}
+	if err := z.SetText("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := z.LookupText(); !ok || err != nil || v != "hi" {
+		t.Errorf("LookupText() = %q, %t, %v; want \"hi\", true, <nil>", v, ok, err)
+	}
+
+	pb, err := air.NewPlaneBase(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := pb.LookupName(); ok || err != nil {
+		t.Errorf("LookupName on new PlaneBase = _, %t, %v; want false, <nil>", ok, err)
+	}
+	if err := pb.SetName(""); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := pb.LookupName(); !ok || err != nil || v != "" {
+		t.Errorf("LookupName() = %q, %t, %v; want \"\", true, <nil>", v, ok, err)
+	}
+}
+
+func TestTextBytesAccessor(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := air.NewRootZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := z.TextBytes(); err != nil || b != nil {
+		t.Errorf("TextBytes on unset field = %q, %v; want nil, <nil>", b, err)
+	}
+	if err := z.SetText("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := z.TextBytes(); err != nil || string(b) != "hi" {
+		t.Errorf("TextBytes() = %q, %v; want \"hi\", <nil>", b, err)
+	}
+}
+
+func TestGeneratedMessageAccessor(t *testing.T) {
+	msg, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := air.NewRootZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := z.Message(); m != msg {
+		t.Errorf("z.Message() = %p; want %p", m, msg)
+	}
+	if m := (air.Z{}).Message(); m != nil {
+		t.Errorf("Z{}.Message() = %p; want nil", m)
+	}
+}
+
+func TestUnknownUnionDiscriminant(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, err := air.NewRootZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.SetText("hi"); err != nil {
+		t.Fatal(err)
+	}
+	// Simulate a variant added by a newer schema.
+	z.Struct.SetUint16(0, 1000)
+
+	if w := z.Which(); w != air.Z_Which_Unknown {
+		t.Errorf("Which() = %v; want Z_Which_Unknown", w)
+	}
+	if w := z.WhichRaw(); w != 1000 {
+		t.Errorf("WhichRaw() = %d; want 1000", w)
+	}
+	if _, ok, err := z.LookupText(); ok || err != nil {
+		t.Errorf("LookupText() = _, %t, %v; want false, <nil>", ok, err)
+	}
+	if _, ok := z.LookupF64(); ok {
+		t.Error("LookupF64() ok = true; want false")
+	}
+	z.SetF64(1.5)
+	if w := z.Which(); w != air.Z_Which_f64 {
+		t.Errorf("after SetF64, Which() = %v; want f64", w)
+	}
+}
+
+func TestGeneratedCopyFrom(t *testing.T) {
+	_, srcSeg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := air.NewRootPlaneBase(srcSeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetName("Cessna"); err != nil {
+		t.Fatal(err)
+	}
+	src.SetRating(7)
+	src.SetCanFly(true)
+
+	_, dstSeg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := air.NewRootPlaneBase(dstSeg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.CopyFrom(src); err != nil {
+		t.Fatal("CopyFrom:", err)
+	}
+	if name, err := dst.Name(); err != nil || name != "Cessna" {
+		t.Errorf("dst.Name() = %q, %v; want \"Cessna\", <nil>", name, err)
+	}
+	if r := dst.Rating(); r != 7 {
+		t.Errorf("dst.Rating() = %d; want 7", r)
+	}
+	if !dst.CanFly() {
+		t.Error("dst.CanFly() = false; want true")
+	}
+	if eq, err := dst.Struct.Equal(src.Struct); err != nil || !eq {
+		t.Errorf("dst.Equal(src) = %t, %v; want true, <nil>", eq, err)
+	}
+}
+
+func TestHasPointerField(t *testing.T) {
+	_, seg, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := air.NewRootPlaneBase(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.HasName() {
+		t.Error("HasName() on new struct = true; want false")
+	}
+	if err := base.SetName(""); err != nil {
+		t.Fatal(err)
+	}
+	if !base.HasName() {
+		t.Error("HasName() after SetName(\"\") = false; want true")
+	}
+
+	z, err := air.NewZ(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.SetText("hi"); err != nil {
+		t.Fatal(err)
+	}
+	if !z.HasText() {
+		t.Error("HasText() after SetText = false; want true")
+	}
+	if z.HasBlob() {
+		t.Error("HasBlob() while text is set = true; want false")
+	}
+}
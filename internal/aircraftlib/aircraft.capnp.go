@@ -4,7 +4,6 @@ package aircraftlib
 
 import (
 	context "golang.org/x/net/context"
-	math "math"
 	strconv "strconv"
 	capnp "zombiezen.com/go/capnproto2"
 	server "zombiezen.com/go/capnproto2/server"
@@ -88,6 +87,9 @@ func NewZdate_List(s *capnp.Segment, sz int32) (Zdate_List, error) {
 func (s Zdate_List) At(i int) Zdate           { return Zdate{s.List.Struct(i)} }
 func (s Zdate_List) Set(i int, v Zdate) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Zdate) CopyFrom(src Zdate) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Zdate_Promise is a wrapper for a Zdate promised by a client call.
 type Zdate_Promise struct{ *capnp.Pipeline }
 
@@ -133,6 +135,10 @@ func (s Zdata) Data() ([]byte, error) {
 
 }
 
+func (s Zdata) HasData() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Zdata) SetData(v []byte) error {
 
 	d, err := capnp.NewData(s.Struct.Segment(), []byte(v))
@@ -157,6 +163,9 @@ func NewZdata_List(s *capnp.Segment, sz int32) (Zdata_List, error) {
 func (s Zdata_List) At(i int) Zdata           { return Zdata{s.List.Struct(i)} }
 func (s Zdata_List) Set(i int, v Zdata) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Zdata) CopyFrom(src Zdata) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Zdata_Promise is a wrapper for a Zdata promised by a client call.
 type Zdata_Promise struct{ *capnp.Pipeline }
 
@@ -282,6 +291,22 @@ func (s PlaneBase) Name() (string, error) {
 
 }
 
+// NameBytes returns the name field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s PlaneBase) NameBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s PlaneBase) HasName() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s PlaneBase) SetName(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -291,6 +316,14 @@ func (s PlaneBase) SetName(v string) error {
 	return s.Struct.SetPointer(0, t)
 }
 
+func (s PlaneBase) LookupName() (v string, ok bool, err error) {
+	if !s.Struct.HasPointer(0) {
+		return
+	}
+	v, err = s.Name()
+	return v, true, err
+}
+
 func (s PlaneBase) Homes() (Airport_List, error) {
 	p, err := s.Struct.Pointer(1)
 	if err != nil {
@@ -302,6 +335,10 @@ func (s PlaneBase) Homes() (Airport_List, error) {
 	return Airport_List{List: l}, nil
 }
 
+func (s PlaneBase) HasHomes() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s PlaneBase) SetHomes(v Airport_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -335,12 +372,12 @@ func (s PlaneBase) SetCapacity(v int64) {
 }
 
 func (s PlaneBase) MaxSpeed() float64 {
-	return math.Float64frombits(s.Struct.Uint64(24))
+	return s.Struct.Float64(24)
 }
 
 func (s PlaneBase) SetMaxSpeed(v float64) {
 
-	s.Struct.SetUint64(24, math.Float64bits(v))
+	s.Struct.SetFloat64(24, v)
 }
 
 // PlaneBase_List is a list of PlaneBase.
@@ -358,6 +395,9 @@ func NewPlaneBase_List(s *capnp.Segment, sz int32) (PlaneBase_List, error) {
 func (s PlaneBase_List) At(i int) PlaneBase           { return PlaneBase{s.List.Struct(i)} }
 func (s PlaneBase_List) Set(i int, v PlaneBase) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s PlaneBase) CopyFrom(src PlaneBase) error { return s.Struct.CopyFrom(src.Struct) }
+
 // PlaneBase_Promise is a wrapper for a PlaneBase promised by a client call.
 type PlaneBase_Promise struct{ *capnp.Pipeline }
 
@@ -404,6 +444,10 @@ func (s B737) Base() (PlaneBase, error) {
 	return PlaneBase{Struct: ss}, nil
 }
 
+func (s B737) HasBase() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s B737) SetBase(v PlaneBase) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -436,6 +480,9 @@ func NewB737_List(s *capnp.Segment, sz int32) (B737_List, error) {
 func (s B737_List) At(i int) B737           { return B737{s.List.Struct(i)} }
 func (s B737_List) Set(i int, v B737) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s B737) CopyFrom(src B737) error { return s.Struct.CopyFrom(src.Struct) }
+
 // B737_Promise is a wrapper for a B737 promised by a client call.
 type B737_Promise struct{ *capnp.Pipeline }
 
@@ -486,6 +533,10 @@ func (s A320) Base() (PlaneBase, error) {
 	return PlaneBase{Struct: ss}, nil
 }
 
+func (s A320) HasBase() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s A320) SetBase(v PlaneBase) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -518,6 +569,9 @@ func NewA320_List(s *capnp.Segment, sz int32) (A320_List, error) {
 func (s A320_List) At(i int) A320           { return A320{s.List.Struct(i)} }
 func (s A320_List) Set(i int, v A320) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s A320) CopyFrom(src A320) error { return s.Struct.CopyFrom(src.Struct) }
+
 // A320_Promise is a wrapper for a A320 promised by a client call.
 type A320_Promise struct{ *capnp.Pipeline }
 
@@ -568,6 +622,10 @@ func (s F16) Base() (PlaneBase, error) {
 	return PlaneBase{Struct: ss}, nil
 }
 
+func (s F16) HasBase() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s F16) SetBase(v PlaneBase) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -600,6 +658,9 @@ func NewF16_List(s *capnp.Segment, sz int32) (F16_List, error) {
 func (s F16_List) At(i int) F16           { return F16{s.List.Struct(i)} }
 func (s F16_List) Set(i int, v F16) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s F16) CopyFrom(src F16) error { return s.Struct.CopyFrom(src.Struct) }
+
 // F16_Promise is a wrapper for a F16 promised by a client call.
 type F16_Promise struct{ *capnp.Pipeline }
 
@@ -650,6 +711,10 @@ func (s Regression) Base() (PlaneBase, error) {
 	return PlaneBase{Struct: ss}, nil
 }
 
+func (s Regression) HasBase() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Regression) SetBase(v PlaneBase) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -668,12 +733,12 @@ func (s Regression) NewBase() (PlaneBase, error) {
 }
 
 func (s Regression) B0() float64 {
-	return math.Float64frombits(s.Struct.Uint64(0))
+	return s.Struct.Float64(0)
 }
 
 func (s Regression) SetB0(v float64) {
 
-	s.Struct.SetUint64(0, math.Float64bits(v))
+	s.Struct.SetFloat64(0, v)
 }
 
 func (s Regression) Beta() (capnp.Float64List, error) {
@@ -687,6 +752,10 @@ func (s Regression) Beta() (capnp.Float64List, error) {
 	return capnp.Float64List{List: l}, nil
 }
 
+func (s Regression) HasBeta() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Regression) SetBeta(v capnp.Float64List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -703,27 +772,31 @@ func (s Regression) Planes() (Aircraft_List, error) {
 	return Aircraft_List{List: l}, nil
 }
 
+func (s Regression) HasPlanes() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s Regression) SetPlanes(v Aircraft_List) error {
 
 	return s.Struct.SetPointer(2, v.List)
 }
 
 func (s Regression) Ymu() float64 {
-	return math.Float64frombits(s.Struct.Uint64(8))
+	return s.Struct.Float64(8)
 }
 
 func (s Regression) SetYmu(v float64) {
 
-	s.Struct.SetUint64(8, math.Float64bits(v))
+	s.Struct.SetFloat64(8, v)
 }
 
 func (s Regression) Ysd() float64 {
-	return math.Float64frombits(s.Struct.Uint64(16))
+	return s.Struct.Float64(16)
 }
 
 func (s Regression) SetYsd(v float64) {
 
-	s.Struct.SetUint64(16, math.Float64bits(v))
+	s.Struct.SetFloat64(16, v)
 }
 
 // Regression_List is a list of Regression.
@@ -741,6 +814,9 @@ func NewRegression_List(s *capnp.Segment, sz int32) (Regression_List, error) {
 func (s Regression_List) At(i int) Regression           { return Regression{s.List.Struct(i)} }
 func (s Regression_List) Set(i int, v Regression) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Regression) CopyFrom(src Regression) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Regression_Promise is a wrapper for a Regression promised by a client call.
 type Regression_Promise struct{ *capnp.Pipeline }
 
@@ -761,6 +837,13 @@ const (
 	Aircraft_Which_b737 Aircraft_Which = 1
 	Aircraft_Which_a320 Aircraft_Which = 2
 	Aircraft_Which_f16  Aircraft_Which = 3
+
+	// Aircraft_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Aircraft_Which_Unknown Aircraft_Which = 0xffff
 )
 
 func (w Aircraft_Which) String() string {
@@ -805,11 +888,22 @@ func ReadRootAircraft(msg *capnp.Message) (Aircraft, error) {
 }
 
 func (s Aircraft) Which() Aircraft_Which {
-	return Aircraft_Which(s.Struct.Uint16(0))
+	w := Aircraft_Which(s.Struct.Uint16(0))
+	if w >= 4 {
+		return Aircraft_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Aircraft_Which_Unknown.
+func (s Aircraft) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Aircraft) SetVoid() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Aircraft) B737() (B737, error) {
@@ -823,6 +917,13 @@ func (s Aircraft) B737() (B737, error) {
 	return B737{Struct: ss}, nil
 }
 
+func (s Aircraft) HasB737() bool {
+	if s.Which() != Aircraft_Which_b737 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Aircraft) SetB737(v B737) error {
 	s.Struct.SetUint16(0, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -851,6 +952,13 @@ func (s Aircraft) A320() (A320, error) {
 	return A320{Struct: ss}, nil
 }
 
+func (s Aircraft) HasA320() bool {
+	if s.Which() != Aircraft_Which_a320 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Aircraft) SetA320(v A320) error {
 	s.Struct.SetUint16(0, 2)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -879,6 +987,13 @@ func (s Aircraft) F16() (F16, error) {
 	return F16{Struct: ss}, nil
 }
 
+func (s Aircraft) HasF16() bool {
+	if s.Which() != Aircraft_Which_f16 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Aircraft) SetF16(v F16) error {
 	s.Struct.SetUint16(0, 3)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -911,6 +1026,9 @@ func NewAircraft_List(s *capnp.Segment, sz int32) (Aircraft_List, error) {
 func (s Aircraft_List) At(i int) Aircraft           { return Aircraft{s.List.Struct(i)} }
 func (s Aircraft_List) Set(i int, v Aircraft) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Aircraft) CopyFrom(src Aircraft) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Aircraft_Promise is a wrapper for a Aircraft promised by a client call.
 type Aircraft_Promise struct{ *capnp.Pipeline }
 
@@ -975,6 +1093,13 @@ const (
 	Z_Which_zdatevec    Z_Which = 37
 	Z_Which_zdatavec    Z_Which = 38
 	Z_Which_boolvec     Z_Which = 39
+
+	// Z_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Z_Which_Unknown Z_Which = 0xffff
 )
 
 func (w Z_Which) String() string {
@@ -1091,11 +1216,23 @@ func ReadRootZ(msg *capnp.Message) (Z, error) {
 }
 
 func (s Z) Which() Z_Which {
-	return Z_Which(s.Struct.Uint16(0))
+	w := Z_Which(s.Struct.Uint16(0))
+	if w >= 40 {
+		return Z_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Z_Which_Unknown.
+func (s Z) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Z) SetVoid() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetUint64(8, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Z) Zz() (Z, error) {
@@ -1109,6 +1246,13 @@ func (s Z) Zz() (Z, error) {
 	return Z{Struct: ss}, nil
 }
 
+func (s Z) HasZz() bool {
+	if s.Which() != Z_Which_zz {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZz(v Z) error {
 	s.Struct.SetUint16(0, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1127,21 +1271,28 @@ func (s Z) NewZz() (Z, error) {
 }
 
 func (s Z) F64() float64 {
-	return math.Float64frombits(s.Struct.Uint64(8))
+	return s.Struct.Float64(8)
 }
 
 func (s Z) SetF64(v float64) {
 	s.Struct.SetUint16(0, 2)
-	s.Struct.SetUint64(8, math.Float64bits(v))
+	s.Struct.SetFloat64(8, v)
+}
+
+func (s Z) LookupF64() (v float64, ok bool) {
+	if s.Which() != Z_Which_f64 {
+		return
+	}
+	return s.F64(), true
 }
 
 func (s Z) F32() float32 {
-	return math.Float32frombits(s.Struct.Uint32(8))
+	return s.Struct.Float32(8)
 }
 
 func (s Z) SetF32(v float32) {
 	s.Struct.SetUint16(0, 3)
-	s.Struct.SetUint32(8, math.Float32bits(v))
+	s.Struct.SetFloat32(8, v)
 }
 
 func (s Z) I64() int64 {
@@ -1235,6 +1386,25 @@ func (s Z) Text() (string, error) {
 
 }
 
+// TextBytes returns the text field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Z) TextBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Z) HasText() bool {
+	if s.Which() != Z_Which_text {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetText(v string) error {
 	s.Struct.SetUint16(0, 13)
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -1244,6 +1414,14 @@ func (s Z) SetText(v string) error {
 	return s.Struct.SetPointer(0, t)
 }
 
+func (s Z) LookupText() (v string, ok bool, err error) {
+	if s.Which() != Z_Which_text || !s.Struct.HasPointer(0) {
+		return
+	}
+	v, err = s.Text()
+	return v, true, err
+}
+
 func (s Z) Blob() ([]byte, error) {
 	p, err := s.Struct.Pointer(0)
 	if err != nil {
@@ -1254,6 +1432,13 @@ func (s Z) Blob() ([]byte, error) {
 
 }
 
+func (s Z) HasBlob() bool {
+	if s.Which() != Z_Which_blob {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetBlob(v []byte) error {
 	s.Struct.SetUint16(0, 14)
 	d, err := capnp.NewData(s.Struct.Segment(), []byte(v))
@@ -1274,6 +1459,13 @@ func (s Z) F64vec() (capnp.Float64List, error) {
 	return capnp.Float64List{List: l}, nil
 }
 
+func (s Z) HasF64vec() bool {
+	if s.Which() != Z_Which_f64vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetF64vec(v capnp.Float64List) error {
 	s.Struct.SetUint16(0, 15)
 	return s.Struct.SetPointer(0, v.List)
@@ -1290,6 +1482,13 @@ func (s Z) F32vec() (capnp.Float32List, error) {
 	return capnp.Float32List{List: l}, nil
 }
 
+func (s Z) HasF32vec() bool {
+	if s.Which() != Z_Which_f32vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetF32vec(v capnp.Float32List) error {
 	s.Struct.SetUint16(0, 16)
 	return s.Struct.SetPointer(0, v.List)
@@ -1306,6 +1505,13 @@ func (s Z) I64vec() (capnp.Int64List, error) {
 	return capnp.Int64List{List: l}, nil
 }
 
+func (s Z) HasI64vec() bool {
+	if s.Which() != Z_Which_i64vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetI64vec(v capnp.Int64List) error {
 	s.Struct.SetUint16(0, 17)
 	return s.Struct.SetPointer(0, v.List)
@@ -1322,6 +1528,13 @@ func (s Z) I32vec() (capnp.Int32List, error) {
 	return capnp.Int32List{List: l}, nil
 }
 
+func (s Z) HasI32vec() bool {
+	if s.Which() != Z_Which_i32vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetI32vec(v capnp.Int32List) error {
 	s.Struct.SetUint16(0, 18)
 	return s.Struct.SetPointer(0, v.List)
@@ -1338,6 +1551,13 @@ func (s Z) I16vec() (capnp.Int16List, error) {
 	return capnp.Int16List{List: l}, nil
 }
 
+func (s Z) HasI16vec() bool {
+	if s.Which() != Z_Which_i16vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetI16vec(v capnp.Int16List) error {
 	s.Struct.SetUint16(0, 19)
 	return s.Struct.SetPointer(0, v.List)
@@ -1354,6 +1574,13 @@ func (s Z) I8vec() (capnp.Int8List, error) {
 	return capnp.Int8List{List: l}, nil
 }
 
+func (s Z) HasI8vec() bool {
+	if s.Which() != Z_Which_i8vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetI8vec(v capnp.Int8List) error {
 	s.Struct.SetUint16(0, 20)
 	return s.Struct.SetPointer(0, v.List)
@@ -1370,6 +1597,13 @@ func (s Z) U64vec() (capnp.UInt64List, error) {
 	return capnp.UInt64List{List: l}, nil
 }
 
+func (s Z) HasU64vec() bool {
+	if s.Which() != Z_Which_u64vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetU64vec(v capnp.UInt64List) error {
 	s.Struct.SetUint16(0, 21)
 	return s.Struct.SetPointer(0, v.List)
@@ -1386,6 +1620,13 @@ func (s Z) U32vec() (capnp.UInt32List, error) {
 	return capnp.UInt32List{List: l}, nil
 }
 
+func (s Z) HasU32vec() bool {
+	if s.Which() != Z_Which_u32vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetU32vec(v capnp.UInt32List) error {
 	s.Struct.SetUint16(0, 22)
 	return s.Struct.SetPointer(0, v.List)
@@ -1402,6 +1643,13 @@ func (s Z) U16vec() (capnp.UInt16List, error) {
 	return capnp.UInt16List{List: l}, nil
 }
 
+func (s Z) HasU16vec() bool {
+	if s.Which() != Z_Which_u16vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetU16vec(v capnp.UInt16List) error {
 	s.Struct.SetUint16(0, 23)
 	return s.Struct.SetPointer(0, v.List)
@@ -1418,6 +1666,13 @@ func (s Z) U8vec() (capnp.UInt8List, error) {
 	return capnp.UInt8List{List: l}, nil
 }
 
+func (s Z) HasU8vec() bool {
+	if s.Which() != Z_Which_u8vec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetU8vec(v capnp.UInt8List) error {
 	s.Struct.SetUint16(0, 24)
 	return s.Struct.SetPointer(0, v.List)
@@ -1434,6 +1689,13 @@ func (s Z) Zvec() (Z_List, error) {
 	return Z_List{List: l}, nil
 }
 
+func (s Z) HasZvec() bool {
+	if s.Which() != Z_Which_zvec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZvec(v Z_List) error {
 	s.Struct.SetUint16(0, 25)
 	return s.Struct.SetPointer(0, v.List)
@@ -1450,6 +1712,13 @@ func (s Z) Zvecvec() (capnp.PointerList, error) {
 	return capnp.PointerList{List: l}, nil
 }
 
+func (s Z) HasZvecvec() bool {
+	if s.Which() != Z_Which_zvecvec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZvecvec(v capnp.PointerList) error {
 	s.Struct.SetUint16(0, 26)
 	return s.Struct.SetPointer(0, v.List)
@@ -1466,6 +1735,13 @@ func (s Z) Zdate() (Zdate, error) {
 	return Zdate{Struct: ss}, nil
 }
 
+func (s Z) HasZdate() bool {
+	if s.Which() != Z_Which_zdate {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZdate(v Zdate) error {
 	s.Struct.SetUint16(0, 27)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1494,6 +1770,13 @@ func (s Z) Zdata() (Zdata, error) {
 	return Zdata{Struct: ss}, nil
 }
 
+func (s Z) HasZdata() bool {
+	if s.Which() != Z_Which_zdata {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZdata(v Zdata) error {
 	s.Struct.SetUint16(0, 28)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1522,6 +1805,13 @@ func (s Z) Aircraftvec() (Aircraft_List, error) {
 	return Aircraft_List{List: l}, nil
 }
 
+func (s Z) HasAircraftvec() bool {
+	if s.Which() != Z_Which_aircraftvec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetAircraftvec(v Aircraft_List) error {
 	s.Struct.SetUint16(0, 29)
 	return s.Struct.SetPointer(0, v.List)
@@ -1538,6 +1828,13 @@ func (s Z) Aircraft() (Aircraft, error) {
 	return Aircraft{Struct: ss}, nil
 }
 
+func (s Z) HasAircraft() bool {
+	if s.Which() != Z_Which_aircraft {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetAircraft(v Aircraft) error {
 	s.Struct.SetUint16(0, 30)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1566,6 +1863,13 @@ func (s Z) Regression() (Regression, error) {
 	return Regression{Struct: ss}, nil
 }
 
+func (s Z) HasRegression() bool {
+	if s.Which() != Z_Which_regression {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetRegression(v Regression) error {
 	s.Struct.SetUint16(0, 31)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1594,6 +1898,13 @@ func (s Z) Planebase() (PlaneBase, error) {
 	return PlaneBase{Struct: ss}, nil
 }
 
+func (s Z) HasPlanebase() bool {
+	if s.Which() != Z_Which_planebase {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetPlanebase(v PlaneBase) error {
 	s.Struct.SetUint16(0, 32)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1631,6 +1942,13 @@ func (s Z) B737() (B737, error) {
 	return B737{Struct: ss}, nil
 }
 
+func (s Z) HasB737() bool {
+	if s.Which() != Z_Which_b737 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetB737(v B737) error {
 	s.Struct.SetUint16(0, 34)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1659,6 +1977,13 @@ func (s Z) A320() (A320, error) {
 	return A320{Struct: ss}, nil
 }
 
+func (s Z) HasA320() bool {
+	if s.Which() != Z_Which_a320 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetA320(v A320) error {
 	s.Struct.SetUint16(0, 35)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1687,6 +2012,13 @@ func (s Z) F16() (F16, error) {
 	return F16{Struct: ss}, nil
 }
 
+func (s Z) HasF16() bool {
+	if s.Which() != Z_Which_f16 {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetF16(v F16) error {
 	s.Struct.SetUint16(0, 36)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1715,6 +2047,13 @@ func (s Z) Zdatevec() (Zdate_List, error) {
 	return Zdate_List{List: l}, nil
 }
 
+func (s Z) HasZdatevec() bool {
+	if s.Which() != Z_Which_zdatevec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZdatevec(v Zdate_List) error {
 	s.Struct.SetUint16(0, 37)
 	return s.Struct.SetPointer(0, v.List)
@@ -1731,6 +2070,13 @@ func (s Z) Zdatavec() (Zdata_List, error) {
 	return Zdata_List{List: l}, nil
 }
 
+func (s Z) HasZdatavec() bool {
+	if s.Which() != Z_Which_zdatavec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetZdatavec(v Zdata_List) error {
 	s.Struct.SetUint16(0, 38)
 	return s.Struct.SetPointer(0, v.List)
@@ -1747,6 +2093,13 @@ func (s Z) Boolvec() (capnp.BitList, error) {
 	return capnp.BitList{List: l}, nil
 }
 
+func (s Z) HasBoolvec() bool {
+	if s.Which() != Z_Which_boolvec {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Z) SetBoolvec(v capnp.BitList) error {
 	s.Struct.SetUint16(0, 39)
 	return s.Struct.SetPointer(0, v.List)
@@ -1767,6 +2120,9 @@ func NewZ_List(s *capnp.Segment, sz int32) (Z_List, error) {
 func (s Z_List) At(i int) Z           { return Z{s.List.Struct(i)} }
 func (s Z_List) Set(i int, v Z) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Z) CopyFrom(src Z) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Z_Promise is a wrapper for a Z promised by a client call.
 type Z_Promise struct{ *capnp.Pipeline }
 
@@ -1857,6 +2213,22 @@ func (s Counter) Words() (string, error) {
 
 }
 
+// WordsBytes returns the words field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Counter) WordsBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Counter) HasWords() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Counter) SetWords(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -1877,6 +2249,10 @@ func (s Counter) Wordlist() (capnp.TextList, error) {
 	return capnp.TextList{List: l}, nil
 }
 
+func (s Counter) HasWordlist() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Counter) SetWordlist(v capnp.TextList) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -1897,6 +2273,9 @@ func NewCounter_List(s *capnp.Segment, sz int32) (Counter_List, error) {
 func (s Counter_List) At(i int) Counter           { return Counter{s.List.Struct(i)} }
 func (s Counter_List) Set(i int, v Counter) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Counter) CopyFrom(src Counter) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Counter_Promise is a wrapper for a Counter promised by a client call.
 type Counter_Promise struct{ *capnp.Pipeline }
 
@@ -1943,6 +2322,10 @@ func (s Bag) Counter() (Counter, error) {
 	return Counter{Struct: ss}, nil
 }
 
+func (s Bag) HasCounter() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Bag) SetCounter(v Counter) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1975,6 +2358,9 @@ func NewBag_List(s *capnp.Segment, sz int32) (Bag_List, error) {
 func (s Bag_List) At(i int) Bag           { return Bag{s.List.Struct(i)} }
 func (s Bag_List) Set(i int, v Bag) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Bag) CopyFrom(src Bag) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Bag_Promise is a wrapper for a Bag promised by a client call.
 type Bag_Promise struct{ *capnp.Pipeline }
 
@@ -2025,6 +2411,10 @@ func (s Zserver) Waitingjobs() (Zjob_List, error) {
 	return Zjob_List{List: l}, nil
 }
 
+func (s Zserver) HasWaitingjobs() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Zserver) SetWaitingjobs(v Zjob_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2045,6 +2435,9 @@ func NewZserver_List(s *capnp.Segment, sz int32) (Zserver_List, error) {
 func (s Zserver_List) At(i int) Zserver           { return Zserver{s.List.Struct(i)} }
 func (s Zserver_List) Set(i int, v Zserver) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Zserver) CopyFrom(src Zserver) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Zserver_Promise is a wrapper for a Zserver promised by a client call.
 type Zserver_Promise struct{ *capnp.Pipeline }
 
@@ -2090,6 +2483,22 @@ func (s Zjob) Cmd() (string, error) {
 
 }
 
+// CmdBytes returns the cmd field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Zjob) CmdBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Zjob) HasCmd() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Zjob) SetCmd(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -2110,6 +2519,10 @@ func (s Zjob) Args() (capnp.TextList, error) {
 	return capnp.TextList{List: l}, nil
 }
 
+func (s Zjob) HasArgs() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Zjob) SetArgs(v capnp.TextList) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -2130,6 +2543,9 @@ func NewZjob_List(s *capnp.Segment, sz int32) (Zjob_List, error) {
 func (s Zjob_List) At(i int) Zjob           { return Zjob{s.List.Struct(i)} }
 func (s Zjob_List) Set(i int, v Zjob) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Zjob) CopyFrom(src Zjob) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Zjob_Promise is a wrapper for a Zjob promised by a client call.
 type Zjob_Promise struct{ *capnp.Pipeline }
 
@@ -2180,6 +2596,9 @@ func NewVerEmpty_List(s *capnp.Segment, sz int32) (VerEmpty_List, error) {
 func (s VerEmpty_List) At(i int) VerEmpty           { return VerEmpty{s.List.Struct(i)} }
 func (s VerEmpty_List) Set(i int, v VerEmpty) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerEmpty) CopyFrom(src VerEmpty) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerEmpty_Promise is a wrapper for a VerEmpty promised by a client call.
 type VerEmpty_Promise struct{ *capnp.Pipeline }
 
@@ -2239,6 +2658,9 @@ func NewVerOneData_List(s *capnp.Segment, sz int32) (VerOneData_List, error) {
 func (s VerOneData_List) At(i int) VerOneData           { return VerOneData{s.List.Struct(i)} }
 func (s VerOneData_List) Set(i int, v VerOneData) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerOneData) CopyFrom(src VerOneData) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerOneData_Promise is a wrapper for a VerOneData promised by a client call.
 type VerOneData_Promise struct{ *capnp.Pipeline }
 
@@ -2307,6 +2729,9 @@ func NewVerTwoData_List(s *capnp.Segment, sz int32) (VerTwoData_List, error) {
 func (s VerTwoData_List) At(i int) VerTwoData           { return VerTwoData{s.List.Struct(i)} }
 func (s VerTwoData_List) Set(i int, v VerTwoData) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerTwoData) CopyFrom(src VerTwoData) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerTwoData_Promise is a wrapper for a VerTwoData promised by a client call.
 type VerTwoData_Promise struct{ *capnp.Pipeline }
 
@@ -2353,6 +2778,10 @@ func (s VerOnePtr) Ptr() (VerOneData, error) {
 	return VerOneData{Struct: ss}, nil
 }
 
+func (s VerOnePtr) HasPtr() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s VerOnePtr) SetPtr(v VerOneData) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2385,6 +2814,9 @@ func NewVerOnePtr_List(s *capnp.Segment, sz int32) (VerOnePtr_List, error) {
 func (s VerOnePtr_List) At(i int) VerOnePtr           { return VerOnePtr{s.List.Struct(i)} }
 func (s VerOnePtr_List) Set(i int, v VerOnePtr) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerOnePtr) CopyFrom(src VerOnePtr) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerOnePtr_Promise is a wrapper for a VerOnePtr promised by a client call.
 type VerOnePtr_Promise struct{ *capnp.Pipeline }
 
@@ -2435,6 +2867,10 @@ func (s VerTwoPtr) Ptr1() (VerOneData, error) {
 	return VerOneData{Struct: ss}, nil
 }
 
+func (s VerTwoPtr) HasPtr1() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s VerTwoPtr) SetPtr1(v VerOneData) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2463,6 +2899,10 @@ func (s VerTwoPtr) Ptr2() (VerOneData, error) {
 	return VerOneData{Struct: ss}, nil
 }
 
+func (s VerTwoPtr) HasPtr2() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s VerTwoPtr) SetPtr2(v VerOneData) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -2495,6 +2935,9 @@ func NewVerTwoPtr_List(s *capnp.Segment, sz int32) (VerTwoPtr_List, error) {
 func (s VerTwoPtr_List) At(i int) VerTwoPtr           { return VerTwoPtr{s.List.Struct(i)} }
 func (s VerTwoPtr_List) Set(i int, v VerTwoPtr) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerTwoPtr) CopyFrom(src VerTwoPtr) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerTwoPtr_Promise is a wrapper for a VerTwoPtr promised by a client call.
 type VerTwoPtr_Promise struct{ *capnp.Pipeline }
 
@@ -2567,6 +3010,10 @@ func (s VerTwoDataTwoPtr) Ptr1() (VerOneData, error) {
 	return VerOneData{Struct: ss}, nil
 }
 
+func (s VerTwoDataTwoPtr) HasPtr1() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s VerTwoDataTwoPtr) SetPtr1(v VerOneData) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2595,6 +3042,10 @@ func (s VerTwoDataTwoPtr) Ptr2() (VerOneData, error) {
 	return VerOneData{Struct: ss}, nil
 }
 
+func (s VerTwoDataTwoPtr) HasPtr2() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s VerTwoDataTwoPtr) SetPtr2(v VerOneData) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -2629,6 +3080,9 @@ func (s VerTwoDataTwoPtr_List) Set(i int, v VerTwoDataTwoPtr) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerTwoDataTwoPtr) CopyFrom(src VerTwoDataTwoPtr) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerTwoDataTwoPtr_Promise is a wrapper for a VerTwoDataTwoPtr promised by a client call.
 type VerTwoDataTwoPtr_Promise struct{ *capnp.Pipeline }
 
@@ -2683,6 +3137,10 @@ func (s HoldsVerEmptyList) Mylist() (VerEmpty_List, error) {
 	return VerEmpty_List{List: l}, nil
 }
 
+func (s HoldsVerEmptyList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerEmptyList) SetMylist(v VerEmpty_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2707,6 +3165,11 @@ func (s HoldsVerEmptyList_List) Set(i int, v HoldsVerEmptyList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerEmptyList) CopyFrom(src HoldsVerEmptyList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerEmptyList_Promise is a wrapper for a HoldsVerEmptyList promised by a client call.
 type HoldsVerEmptyList_Promise struct{ *capnp.Pipeline }
 
@@ -2753,6 +3216,10 @@ func (s HoldsVerOneDataList) Mylist() (VerOneData_List, error) {
 	return VerOneData_List{List: l}, nil
 }
 
+func (s HoldsVerOneDataList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerOneDataList) SetMylist(v VerOneData_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2777,6 +3244,11 @@ func (s HoldsVerOneDataList_List) Set(i int, v HoldsVerOneDataList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerOneDataList) CopyFrom(src HoldsVerOneDataList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerOneDataList_Promise is a wrapper for a HoldsVerOneDataList promised by a client call.
 type HoldsVerOneDataList_Promise struct{ *capnp.Pipeline }
 
@@ -2823,6 +3295,10 @@ func (s HoldsVerTwoDataList) Mylist() (VerTwoData_List, error) {
 	return VerTwoData_List{List: l}, nil
 }
 
+func (s HoldsVerTwoDataList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerTwoDataList) SetMylist(v VerTwoData_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2847,6 +3323,11 @@ func (s HoldsVerTwoDataList_List) Set(i int, v HoldsVerTwoDataList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerTwoDataList) CopyFrom(src HoldsVerTwoDataList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerTwoDataList_Promise is a wrapper for a HoldsVerTwoDataList promised by a client call.
 type HoldsVerTwoDataList_Promise struct{ *capnp.Pipeline }
 
@@ -2893,6 +3374,10 @@ func (s HoldsVerOnePtrList) Mylist() (VerOnePtr_List, error) {
 	return VerOnePtr_List{List: l}, nil
 }
 
+func (s HoldsVerOnePtrList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerOnePtrList) SetMylist(v VerOnePtr_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2917,6 +3402,11 @@ func (s HoldsVerOnePtrList_List) Set(i int, v HoldsVerOnePtrList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerOnePtrList) CopyFrom(src HoldsVerOnePtrList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerOnePtrList_Promise is a wrapper for a HoldsVerOnePtrList promised by a client call.
 type HoldsVerOnePtrList_Promise struct{ *capnp.Pipeline }
 
@@ -2963,6 +3453,10 @@ func (s HoldsVerTwoPtrList) Mylist() (VerTwoPtr_List, error) {
 	return VerTwoPtr_List{List: l}, nil
 }
 
+func (s HoldsVerTwoPtrList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerTwoPtrList) SetMylist(v VerTwoPtr_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2987,6 +3481,11 @@ func (s HoldsVerTwoPtrList_List) Set(i int, v HoldsVerTwoPtrList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerTwoPtrList) CopyFrom(src HoldsVerTwoPtrList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerTwoPtrList_Promise is a wrapper for a HoldsVerTwoPtrList promised by a client call.
 type HoldsVerTwoPtrList_Promise struct{ *capnp.Pipeline }
 
@@ -3033,6 +3532,10 @@ func (s HoldsVerTwoTwoList) Mylist() (VerTwoDataTwoPtr_List, error) {
 	return VerTwoDataTwoPtr_List{List: l}, nil
 }
 
+func (s HoldsVerTwoTwoList) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerTwoTwoList) SetMylist(v VerTwoDataTwoPtr_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -3057,6 +3560,11 @@ func (s HoldsVerTwoTwoList_List) Set(i int, v HoldsVerTwoTwoList) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerTwoTwoList) CopyFrom(src HoldsVerTwoTwoList) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerTwoTwoList_Promise is a wrapper for a HoldsVerTwoTwoList promised by a client call.
 type HoldsVerTwoTwoList_Promise struct{ *capnp.Pipeline }
 
@@ -3103,6 +3611,10 @@ func (s HoldsVerTwoTwoPlus) Mylist() (VerTwoTwoPlus_List, error) {
 	return VerTwoTwoPlus_List{List: l}, nil
 }
 
+func (s HoldsVerTwoTwoPlus) HasMylist() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsVerTwoTwoPlus) SetMylist(v VerTwoTwoPlus_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -3127,6 +3639,11 @@ func (s HoldsVerTwoTwoPlus_List) Set(i int, v HoldsVerTwoTwoPlus) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsVerTwoTwoPlus) CopyFrom(src HoldsVerTwoTwoPlus) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HoldsVerTwoTwoPlus_Promise is a wrapper for a HoldsVerTwoTwoPlus promised by a client call.
 type HoldsVerTwoTwoPlus_Promise struct{ *capnp.Pipeline }
 
@@ -3191,6 +3708,10 @@ func (s VerTwoTwoPlus) Ptr1() (VerTwoDataTwoPtr, error) {
 	return VerTwoDataTwoPtr{Struct: ss}, nil
 }
 
+func (s VerTwoTwoPlus) HasPtr1() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s VerTwoTwoPlus) SetPtr1(v VerTwoDataTwoPtr) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -3219,6 +3740,10 @@ func (s VerTwoTwoPlus) Ptr2() (VerTwoDataTwoPtr, error) {
 	return VerTwoDataTwoPtr{Struct: ss}, nil
 }
 
+func (s VerTwoTwoPlus) HasPtr2() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s VerTwoTwoPlus) SetPtr2(v VerTwoDataTwoPtr) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -3256,6 +3781,10 @@ func (s VerTwoTwoPlus) Lst3() (capnp.Int64List, error) {
 	return capnp.Int64List{List: l}, nil
 }
 
+func (s VerTwoTwoPlus) HasLst3() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s VerTwoTwoPlus) SetLst3(v capnp.Int64List) error {
 
 	return s.Struct.SetPointer(2, v.List)
@@ -3276,6 +3805,9 @@ func NewVerTwoTwoPlus_List(s *capnp.Segment, sz int32) (VerTwoTwoPlus_List, erro
 func (s VerTwoTwoPlus_List) At(i int) VerTwoTwoPlus           { return VerTwoTwoPlus{s.List.Struct(i)} }
 func (s VerTwoTwoPlus_List) Set(i int, v VerTwoTwoPlus) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VerTwoTwoPlus) CopyFrom(src VerTwoTwoPlus) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VerTwoTwoPlus_Promise is a wrapper for a VerTwoTwoPlus promised by a client call.
 type VerTwoTwoPlus_Promise struct{ *capnp.Pipeline }
 
@@ -3329,6 +3861,22 @@ func (s HoldsText) Txt() (string, error) {
 
 }
 
+// TxtBytes returns the txt field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s HoldsText) TxtBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s HoldsText) HasTxt() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HoldsText) SetTxt(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -3349,6 +3897,10 @@ func (s HoldsText) Lst() (capnp.TextList, error) {
 	return capnp.TextList{List: l}, nil
 }
 
+func (s HoldsText) HasLst() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s HoldsText) SetLst(v capnp.TextList) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -3365,6 +3917,10 @@ func (s HoldsText) Lstlst() (capnp.PointerList, error) {
 	return capnp.PointerList{List: l}, nil
 }
 
+func (s HoldsText) HasLstlst() bool {
+	return s.Struct.HasPointer(2)
+}
+
 func (s HoldsText) SetLstlst(v capnp.PointerList) error {
 
 	return s.Struct.SetPointer(2, v.List)
@@ -3385,6 +3941,9 @@ func NewHoldsText_List(s *capnp.Segment, sz int32) (HoldsText_List, error) {
 func (s HoldsText_List) At(i int) HoldsText           { return HoldsText{s.List.Struct(i)} }
 func (s HoldsText_List) Set(i int, v HoldsText) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HoldsText) CopyFrom(src HoldsText) error { return s.Struct.CopyFrom(src.Struct) }
+
 // HoldsText_Promise is a wrapper for a HoldsText promised by a client call.
 type HoldsText_Promise struct{ *capnp.Pipeline }
 
@@ -3431,6 +3990,10 @@ func (s WrapEmpty) MightNotBeReallyEmpty() (VerEmpty, error) {
 	return VerEmpty{Struct: ss}, nil
 }
 
+func (s WrapEmpty) HasMightNotBeReallyEmpty() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s WrapEmpty) SetMightNotBeReallyEmpty(v VerEmpty) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -3463,6 +4026,9 @@ func NewWrapEmpty_List(s *capnp.Segment, sz int32) (WrapEmpty_List, error) {
 func (s WrapEmpty_List) At(i int) WrapEmpty           { return WrapEmpty{s.List.Struct(i)} }
 func (s WrapEmpty_List) Set(i int, v WrapEmpty) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s WrapEmpty) CopyFrom(src WrapEmpty) error { return s.Struct.CopyFrom(src.Struct) }
+
 // WrapEmpty_Promise is a wrapper for a WrapEmpty promised by a client call.
 type WrapEmpty_Promise struct{ *capnp.Pipeline }
 
@@ -3513,6 +4079,10 @@ func (s Wrap2x2) MightNotBeReallyEmpty() (VerTwoDataTwoPtr, error) {
 	return VerTwoDataTwoPtr{Struct: ss}, nil
 }
 
+func (s Wrap2x2) HasMightNotBeReallyEmpty() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Wrap2x2) SetMightNotBeReallyEmpty(v VerTwoDataTwoPtr) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -3545,6 +4115,9 @@ func NewWrap2x2_List(s *capnp.Segment, sz int32) (Wrap2x2_List, error) {
 func (s Wrap2x2_List) At(i int) Wrap2x2           { return Wrap2x2{s.List.Struct(i)} }
 func (s Wrap2x2_List) Set(i int, v Wrap2x2) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Wrap2x2) CopyFrom(src Wrap2x2) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Wrap2x2_Promise is a wrapper for a Wrap2x2 promised by a client call.
 type Wrap2x2_Promise struct{ *capnp.Pipeline }
 
@@ -3595,6 +4168,10 @@ func (s Wrap2x2plus) MightNotBeReallyEmpty() (VerTwoTwoPlus, error) {
 	return VerTwoTwoPlus{Struct: ss}, nil
 }
 
+func (s Wrap2x2plus) HasMightNotBeReallyEmpty() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Wrap2x2plus) SetMightNotBeReallyEmpty(v VerTwoTwoPlus) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -3627,6 +4204,9 @@ func NewWrap2x2plus_List(s *capnp.Segment, sz int32) (Wrap2x2plus_List, error) {
 func (s Wrap2x2plus_List) At(i int) Wrap2x2plus           { return Wrap2x2plus{s.List.Struct(i)} }
 func (s Wrap2x2plus_List) Set(i int, v Wrap2x2plus) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Wrap2x2plus) CopyFrom(src Wrap2x2plus) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Wrap2x2plus_Promise is a wrapper for a Wrap2x2plus promised by a client call.
 type Wrap2x2plus_Promise struct{ *capnp.Pipeline }
 
@@ -3645,6 +4225,13 @@ type VoidUnion_Which uint16
 const (
 	VoidUnion_Which_a VoidUnion_Which = 0
 	VoidUnion_Which_b VoidUnion_Which = 1
+
+	// VoidUnion_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	VoidUnion_Which_Unknown VoidUnion_Which = 0xffff
 )
 
 func (w VoidUnion_Which) String() string {
@@ -3685,7 +4272,17 @@ func ReadRootVoidUnion(msg *capnp.Message) (VoidUnion, error) {
 }
 
 func (s VoidUnion) Which() VoidUnion_Which {
-	return VoidUnion_Which(s.Struct.Uint16(0))
+	w := VoidUnion_Which(s.Struct.Uint16(0))
+	if w >= 2 {
+		return VoidUnion_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as VoidUnion_Which_Unknown.
+func (s VoidUnion) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s VoidUnion) SetA() {
@@ -3711,6 +4308,9 @@ func NewVoidUnion_List(s *capnp.Segment, sz int32) (VoidUnion_List, error) {
 func (s VoidUnion_List) At(i int) VoidUnion           { return VoidUnion{s.List.Struct(i)} }
 func (s VoidUnion_List) Set(i int, v VoidUnion) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s VoidUnion) CopyFrom(src VoidUnion) error { return s.Struct.CopyFrom(src.Struct) }
+
 // VoidUnion_Promise is a wrapper for a VoidUnion promised by a client call.
 type VoidUnion_Promise struct{ *capnp.Pipeline }
 
@@ -3757,6 +4357,10 @@ func (s Nester1Capn) Strs() (capnp.TextList, error) {
 	return capnp.TextList{List: l}, nil
 }
 
+func (s Nester1Capn) HasStrs() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Nester1Capn) SetStrs(v capnp.TextList) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -3777,6 +4381,9 @@ func NewNester1Capn_List(s *capnp.Segment, sz int32) (Nester1Capn_List, error) {
 func (s Nester1Capn_List) At(i int) Nester1Capn           { return Nester1Capn{s.List.Struct(i)} }
 func (s Nester1Capn_List) Set(i int, v Nester1Capn) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Nester1Capn) CopyFrom(src Nester1Capn) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Nester1Capn_Promise is a wrapper for a Nester1Capn promised by a client call.
 type Nester1Capn_Promise struct{ *capnp.Pipeline }
 
@@ -3823,6 +4430,10 @@ func (s RWTestCapn) NestMatrix() (capnp.PointerList, error) {
 	return capnp.PointerList{List: l}, nil
 }
 
+func (s RWTestCapn) HasNestMatrix() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s RWTestCapn) SetNestMatrix(v capnp.PointerList) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -3843,6 +4454,9 @@ func NewRWTestCapn_List(s *capnp.Segment, sz int32) (RWTestCapn_List, error) {
 func (s RWTestCapn_List) At(i int) RWTestCapn           { return RWTestCapn{s.List.Struct(i)} }
 func (s RWTestCapn_List) Set(i int, v RWTestCapn) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s RWTestCapn) CopyFrom(src RWTestCapn) error { return s.Struct.CopyFrom(src.Struct) }
+
 // RWTestCapn_Promise is a wrapper for a RWTestCapn promised by a client call.
 type RWTestCapn_Promise struct{ *capnp.Pipeline }
 
@@ -3889,6 +4503,10 @@ func (s ListStructCapn) Vec() (Nester1Capn_List, error) {
 	return Nester1Capn_List{List: l}, nil
 }
 
+func (s ListStructCapn) HasVec() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s ListStructCapn) SetVec(v Nester1Capn_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -3909,6 +4527,9 @@ func NewListStructCapn_List(s *capnp.Segment, sz int32) (ListStructCapn_List, er
 func (s ListStructCapn_List) At(i int) ListStructCapn           { return ListStructCapn{s.List.Struct(i)} }
 func (s ListStructCapn_List) Set(i int, v ListStructCapn) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s ListStructCapn) CopyFrom(src ListStructCapn) error { return s.Struct.CopyFrom(src.Struct) }
+
 // ListStructCapn_Promise is a wrapper for a ListStructCapn promised by a client call.
 type ListStructCapn_Promise struct{ *capnp.Pipeline }
 
@@ -4015,6 +4636,22 @@ func (s Echo_echo_Params) In() (string, error) {
 
 }
 
+// InBytes returns the in field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Echo_echo_Params) InBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Echo_echo_Params) HasIn() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Echo_echo_Params) SetIn(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -4041,6 +4678,9 @@ func (s Echo_echo_Params_List) Set(i int, v Echo_echo_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Echo_echo_Params) CopyFrom(src Echo_echo_Params) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Echo_echo_Params_Promise is a wrapper for a Echo_echo_Params promised by a client call.
 type Echo_echo_Params_Promise struct{ *capnp.Pipeline }
 
@@ -4086,6 +4726,22 @@ func (s Echo_echo_Results) Out() (string, error) {
 
 }
 
+// OutBytes returns the out field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Echo_echo_Results) OutBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Echo_echo_Results) HasOut() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Echo_echo_Results) SetOut(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -4114,6 +4770,11 @@ func (s Echo_echo_Results_List) Set(i int, v Echo_echo_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Echo_echo_Results) CopyFrom(src Echo_echo_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Echo_echo_Results_Promise is a wrapper for a Echo_echo_Results promised by a client call.
 type Echo_echo_Results_Promise struct{ *capnp.Pipeline }
 
@@ -4160,6 +4821,10 @@ func (s Hoth) Base() (EchoBase, error) {
 	return EchoBase{Struct: ss}, nil
 }
 
+func (s Hoth) HasBase() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Hoth) SetBase(v EchoBase) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -4192,6 +4857,9 @@ func NewHoth_List(s *capnp.Segment, sz int32) (Hoth_List, error) {
 func (s Hoth_List) At(i int) Hoth           { return Hoth{s.List.Struct(i)} }
 func (s Hoth_List) Set(i int, v Hoth) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hoth) CopyFrom(src Hoth) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Hoth_Promise is a wrapper for a Hoth promised by a client call.
 type Hoth_Promise struct{ *capnp.Pipeline }
 
@@ -4241,6 +4909,10 @@ func (s EchoBase) Echo() Echo {
 	return Echo{Client: c}
 }
 
+func (s EchoBase) HasEcho() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s EchoBase) SetEcho(v Echo) error {
 
 	seg := s.Segment()
@@ -4267,6 +4939,9 @@ func NewEchoBase_List(s *capnp.Segment, sz int32) (EchoBase_List, error) {
 func (s EchoBase_List) At(i int) EchoBase           { return EchoBase{s.List.Struct(i)} }
 func (s EchoBase_List) Set(i int, v EchoBase) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s EchoBase) CopyFrom(src EchoBase) error { return s.Struct.CopyFrom(src.Struct) }
+
 // EchoBase_Promise is a wrapper for a EchoBase promised by a client call.
 type EchoBase_Promise struct{ *capnp.Pipeline }
 
@@ -4317,6 +4992,10 @@ func (s StackingRoot) A() (StackingA, error) {
 	return StackingA{Struct: ss}, nil
 }
 
+func (s StackingRoot) HasA() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s StackingRoot) SetA(v StackingA) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -4348,6 +5027,10 @@ func (s StackingRoot) AWithDefault() (StackingA, error) {
 	return StackingA{Struct: ss}, nil
 }
 
+func (s StackingRoot) HasAWithDefault() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s StackingRoot) SetAWithDefault(v StackingA) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -4365,6 +5048,9 @@ func (s StackingRoot) NewAWithDefault() (StackingA, error) {
 	return ss, err
 }
 
+// StackingRoot_aWithDefault_Default is the encoded default value of StackingRoot.aWithDefault.
+var StackingRoot_aWithDefault_Default = x_832bcc6686a26d56[64:96]
+
 // StackingRoot_List is a list of StackingRoot.
 type StackingRoot_List struct{ capnp.List }
 
@@ -4380,6 +5066,9 @@ func NewStackingRoot_List(s *capnp.Segment, sz int32) (StackingRoot_List, error)
 func (s StackingRoot_List) At(i int) StackingRoot           { return StackingRoot{s.List.Struct(i)} }
 func (s StackingRoot_List) Set(i int, v StackingRoot) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s StackingRoot) CopyFrom(src StackingRoot) error { return s.Struct.CopyFrom(src.Struct) }
+
 // StackingRoot_Promise is a wrapper for a StackingRoot promised by a client call.
 type StackingRoot_Promise struct{ *capnp.Pipeline }
 
@@ -4443,6 +5132,10 @@ func (s StackingA) B() (StackingB, error) {
 	return StackingB{Struct: ss}, nil
 }
 
+func (s StackingA) HasB() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s StackingA) SetB(v StackingB) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -4475,6 +5168,9 @@ func NewStackingA_List(s *capnp.Segment, sz int32) (StackingA_List, error) {
 func (s StackingA_List) At(i int) StackingA           { return StackingA{s.List.Struct(i)} }
 func (s StackingA_List) Set(i int, v StackingA) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s StackingA) CopyFrom(src StackingA) error { return s.Struct.CopyFrom(src.Struct) }
+
 // StackingA_Promise is a wrapper for a StackingA promised by a client call.
 type StackingA_Promise struct{ *capnp.Pipeline }
 
@@ -4538,6 +5234,9 @@ func NewStackingB_List(s *capnp.Segment, sz int32) (StackingB_List, error) {
 func (s StackingB_List) At(i int) StackingB           { return StackingB{s.List.Struct(i)} }
 func (s StackingB_List) Set(i int, v StackingB) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s StackingB) CopyFrom(src StackingB) error { return s.Struct.CopyFrom(src.Struct) }
+
 // StackingB_Promise is a wrapper for a StackingB promised by a client call.
 type StackingB_Promise struct{ *capnp.Pipeline }
 
@@ -4653,6 +5352,11 @@ func (s CallSequence_getNumber_Params_List) Set(i int, v CallSequence_getNumber_
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CallSequence_getNumber_Params) CopyFrom(src CallSequence_getNumber_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // CallSequence_getNumber_Params_Promise is a wrapper for a CallSequence_getNumber_Params promised by a client call.
 type CallSequence_getNumber_Params_Promise struct{ *capnp.Pipeline }
 
@@ -4716,6 +5420,11 @@ func (s CallSequence_getNumber_Results_List) Set(i int, v CallSequence_getNumber
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CallSequence_getNumber_Results) CopyFrom(src CallSequence_getNumber_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // CallSequence_getNumber_Results_Promise is a wrapper for a CallSequence_getNumber_Results promised by a client call.
 type CallSequence_getNumber_Results_Promise struct{ *capnp.Pipeline }
 
@@ -43,6 +43,22 @@ func (s Book) Title() (string, error) {
 
 }
 
+// TitleBytes returns the title field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Book) TitleBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Book) HasTitle() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Book) SetTitle(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -76,6 +92,9 @@ func NewBook_List(s *capnp.Segment, sz int32) (Book_List, error) {
 func (s Book_List) At(i int) Book           { return Book{s.List.Struct(i)} }
 func (s Book_List) Set(i int, v Book) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Book) CopyFrom(src Book) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Book_Promise is a wrapper for a Book promised by a client call.
 type Book_Promise struct{ *capnp.Pipeline }
 
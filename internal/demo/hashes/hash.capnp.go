@@ -115,6 +115,11 @@ func (s HashFactory_newSha1_Params_List) Set(i int, v HashFactory_newSha1_Params
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HashFactory_newSha1_Params) CopyFrom(src HashFactory_newSha1_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HashFactory_newSha1_Params_Promise is a wrapper for a HashFactory_newSha1_Params promised by a client call.
 type HashFactory_newSha1_Params_Promise struct{ *capnp.Pipeline }
 
@@ -160,6 +165,10 @@ func (s HashFactory_newSha1_Results) Hash() Hash {
 	return Hash{Client: c}
 }
 
+func (s HashFactory_newSha1_Results) HasHash() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HashFactory_newSha1_Results) SetHash(v Hash) error {
 
 	seg := s.Segment()
@@ -190,6 +199,11 @@ func (s HashFactory_newSha1_Results_List) Set(i int, v HashFactory_newSha1_Resul
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HashFactory_newSha1_Results) CopyFrom(src HashFactory_newSha1_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HashFactory_newSha1_Results_Promise is a wrapper for a HashFactory_newSha1_Results promised by a client call.
 type HashFactory_newSha1_Results_Promise struct{ *capnp.Pipeline }
 
@@ -344,6 +358,10 @@ func (s Hash_write_Params) Data() ([]byte, error) {
 
 }
 
+func (s Hash_write_Params) HasData() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Hash_write_Params) SetData(v []byte) error {
 
 	d, err := capnp.NewData(s.Struct.Segment(), []byte(v))
@@ -372,6 +390,11 @@ func (s Hash_write_Params_List) Set(i int, v Hash_write_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hash_write_Params) CopyFrom(src Hash_write_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Hash_write_Params_Promise is a wrapper for a Hash_write_Params promised by a client call.
 type Hash_write_Params_Promise struct{ *capnp.Pipeline }
 
@@ -426,6 +449,11 @@ func (s Hash_write_Results_List) Set(i int, v Hash_write_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hash_write_Results) CopyFrom(src Hash_write_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Hash_write_Results_Promise is a wrapper for a Hash_write_Results promised by a client call.
 type Hash_write_Results_Promise struct{ *capnp.Pipeline }
 
@@ -478,6 +506,9 @@ func (s Hash_sum_Params_List) Set(i int, v Hash_sum_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hash_sum_Params) CopyFrom(src Hash_sum_Params) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Hash_sum_Params_Promise is a wrapper for a Hash_sum_Params promised by a client call.
 type Hash_sum_Params_Promise struct{ *capnp.Pipeline }
 
@@ -523,6 +554,10 @@ func (s Hash_sum_Results) Hash() ([]byte, error) {
 
 }
 
+func (s Hash_sum_Results) HasHash() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Hash_sum_Results) SetHash(v []byte) error {
 
 	d, err := capnp.NewData(s.Struct.Segment(), []byte(v))
@@ -549,6 +584,9 @@ func (s Hash_sum_Results_List) Set(i int, v Hash_sum_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hash_sum_Results) CopyFrom(src Hash_sum_Results) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Hash_sum_Results_Promise is a wrapper for a Hash_sum_Results promised by a client call.
 type Hash_sum_Results_Promise struct{ *capnp.Pipeline }
 
@@ -87,6 +87,12 @@ type decompressor struct {
 	zeros int
 	raw   int // number of raw bytes left to copy through
 	state decompressorState
+
+	// Scratch space for reading tags and packed words.  These live in
+	// the decompressor so that passing them to r.Read does not allocate
+	// on every call.
+	tag    [1]byte
+	packed [wordSize]byte
 }
 
 // NewReader returns a reader that decompresses a packed stream from r.
@@ -103,7 +109,7 @@ func min(a, b int) int {
 
 func (c *decompressor) Read(v []byte) (n int, err error) {
 
-	var b [1]byte
+	b := c.tag[:]
 	var bytesRead int
 
 	for {
@@ -166,7 +172,7 @@ func (c *decompressor) Read(v []byte) (n int, err error) {
 			// stay in postFFState
 
 		case readnState:
-			if bytesRead, err = c.r.Read(b[:]); err != nil {
+			if bytesRead, err = c.r.Read(b); err != nil {
 				return
 			}
 			if bytesRead == 0 {
@@ -208,7 +214,7 @@ func (c *decompressor) Read(v []byte) (n int, err error) {
 
 			for c.state == normalState && len(v) > 0 {
 
-				if _, err = c.r.Read(b[:]); err != nil {
+				if _, err = c.r.Read(b); err != nil {
 					return
 				}
 
@@ -221,7 +227,7 @@ func (c *decompressor) Read(v []byte) (n int, err error) {
 					break
 
 				case zeroTag:
-					if _, err = c.r.Read(b[:]); err != nil {
+					if _, err = c.r.Read(b); err != nil {
 						return
 					}
 
@@ -238,7 +244,7 @@ func (c *decompressor) Read(v []byte) (n int, err error) {
 
 				default:
 					ones := 0
-					var buf [wordSize]byte
+					buf := c.packed[:]
 					for i := 0; i < wordSize; i++ {
 						if (b[0] & (1 << uint(i))) != 0 {
 							ones++
@@ -268,7 +274,6 @@ func (c *decompressor) Read(v []byte) (n int, err error) {
 		}
 
 	}
-	return
 }
 
 // decompressorState is the state of a decompressor.
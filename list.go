@@ -1,8 +1,11 @@
 package capnp
 
 import (
+	"encoding/binary"
 	"errors"
 	"math"
+	"sort"
+	"unicode/utf8"
 )
 
 // A List is a reference to an array of values.
@@ -50,6 +53,117 @@ func NewCompositeList(s *Segment, sz ObjectSize, n int32) (List, error) {
 	}, nil
 }
 
+// NewCompositeListFunc creates a new composite list, preferring
+// placement in s, by calling f to fill in each element in turn.  f is
+// given element i, zeroed, and reports whether it filled it in; the
+// list ends at the first element for which f returns false or an
+// error.  The list's storage doubles each time it fills up: the
+// elements are copied to a new allocation and the old one is zeroed and
+// left in the message as dead space.  Counting those earlier
+// allocations, up to about three quarters of the space the list takes
+// in the message can be unused.
+//
+// Growing the list moves its elements to a new allocation, so the
+// Struct passed to f, and any slice of its data section, is only valid
+// until f returns.  Objects that the element's pointers refer to are
+// not moved.
+func NewCompositeListFunc(s *Segment, sz ObjectSize, f func(i int, s Struct) (more bool, err error)) (List, error) {
+	const initialCap = 8
+	l, err := NewCompositeList(s, sz, initialCap)
+	if err != nil {
+		return List{}, err
+	}
+	n := 0
+	for ; ; n++ {
+		if n == int(l.length) {
+			bigger, err := NewCompositeList(l.seg, sz, l.length*2)
+			if err != nil {
+				return List{}, err
+			}
+			for i := 0; i < n; i++ {
+				if err := copyStruct(copyContext{}, bigger.Struct(i), l.Struct(i)); err != nil {
+					return List{}, err
+				}
+			}
+			// Zero the old list, tag word included, so that it doesn't
+			// keep stale copies of the elements' pointers.
+			old := l.seg.slice(l.off-Address(wordSize), wordSize+l.size.totalSize().times(l.length))
+			for i := range old {
+				old[i] = 0
+			}
+			l = bigger
+		}
+		more, err := f(n, l.Struct(n))
+		if err != nil {
+			return List{}, err
+		}
+		if !more {
+			break
+		}
+	}
+	return l.truncate(int32(n)), nil
+}
+
+// truncate shrinks the composite list l to its first n elements by
+// rewriting its tag word.  Space after the new end is released if l is
+// at the end of its segment and is otherwise left unused.
+func (l List) truncate(n int32) List {
+	end := l.off.addSize(l.size.totalSize().times(l.length))
+	l.length = n
+	l.seg.writeRawPointer(l.off-Address(wordSize), rawStructPointer(pointerOffset(n), l.size))
+	if end == Address(len(l.seg.data)) {
+		l.seg.data = l.seg.data[:l.off.addSize(l.size.totalSize().times(n))]
+	}
+	return l
+}
+
+// AppendStruct returns a new composite list in m that holds copies of
+// l's elements followed by a copy of v, like the built-in append.  An
+// invalid l is treated as an empty list.  The elements are as large as
+// the larger of l's elements and v, so that no fields are lost.
+//
+// The new list is always a fresh allocation: l is left as it was, but
+// once the pointer to it is replaced with one to the returned list it
+// is orphaned, and its space in the message is not reclaimed.  Building
+// a list of n elements this way takes space quadratic in n, so use
+// NewCompositeListFunc or NewCompositeList when the elements can be
+// created in order.
+func (m *Message) AppendStruct(l List, v Struct) (List, error) {
+	if l.seg != nil && l.flags&isCompositeList == 0 {
+		return List{}, errAppendList
+	}
+	s := l.seg
+	if s == nil || s.msg != m {
+		var err error
+		if s, err = m.Segment(0); err != nil {
+			return List{}, err
+		}
+	}
+	sz := l.size
+	if v.size.DataSize > sz.DataSize {
+		sz.DataSize = v.size.DataSize
+	}
+	if v.size.PointerCount > sz.PointerCount {
+		sz.PointerCount = v.size.PointerCount
+	}
+	n := l.Len()
+	nl, err := NewCompositeList(s, sz, int32(n+1))
+	if err != nil {
+		return List{}, err
+	}
+	for i := 0; i < n; i++ {
+		if err := copyStruct(copyContext{}, nl.Struct(i), l.Struct(i)); err != nil {
+			return List{}, err
+		}
+	}
+	if v.seg != nil {
+		if err := copyStruct(copyContext{}, nl.Struct(n), v); err != nil {
+			return List{}, err
+		}
+	}
+	return nl, nil
+}
+
 // ToList attempts to convert p into a list.  If p is not a valid
 // list, then it returns an invalid List.
 func ToList(p Pointer) List {
@@ -57,6 +171,20 @@ func ToList(p Pointer) List {
 	return l
 }
 
+// ToListErr converts p into a list, returning an error if p is a
+// valid pointer to an object other than a list.  A null pointer
+// results in an invalid List and no error.
+func ToListErr(p Pointer) (List, error) {
+	if !IsValid(p) {
+		return List{}, nil
+	}
+	l, ok := p.underlying().(List)
+	if !ok {
+		return List{}, newPointerTypeError("list", p)
+	}
+	return l, nil
+}
+
 // ToListDefault attempts to convert p into a struct, reading the
 // default value from def if p is not a struct.
 func ToListDefault(p Pointer, def []byte) (List, error) {
@@ -142,6 +270,70 @@ func (p List) Len() int {
 	return int(p.length)
 }
 
+// Slice returns a view of the list's elements in [start, end), without
+// copying.  Reads and writes through the view reach p's elements.  A
+// slice of a bit list must start at a multiple of 8.  The view has no
+// list pointer of its own, so storing it with SetPointer or Set copies
+// its elements into a new list.
+func (p List) Slice(start, end int) (List, error) {
+	if start < 0 || end < start || end > p.Len() {
+		return List{}, errSliceBounds
+	}
+	if p.seg == nil {
+		return List{}, nil
+	}
+	if start == 0 && end == p.Len() {
+		return p, nil
+	}
+	off := p.off
+	if p.flags&isBitList != 0 {
+		if start%8 != 0 {
+			return List{}, errBitListSlice
+		}
+		off = off.addSize(Size(start / 8))
+	} else {
+		off = off.element(int32(start), p.size.totalSize())
+	}
+	return List{
+		seg:    p.seg,
+		off:    off,
+		length: int32(end - start),
+		size:   p.size,
+		flags:  p.flags | isListSlice,
+	}, nil
+}
+
+// Kind returns the element encoding of the list, as recorded in the
+// list pointer.  The kind of an invalid list is VoidListKind.
+func (p List) Kind() ListKind {
+	switch {
+	case p.seg == nil:
+		return VoidListKind
+	case p.flags&isCompositeList != 0:
+		return CompositeListKind
+	case p.flags&isBitList != 0:
+		return BitListKind
+	case p.size.PointerCount == 1 && p.size.DataSize == 0:
+		return PointerListKind
+	case p.size.PointerCount != 0:
+		panic(errListSize)
+	}
+	switch p.size.DataSize {
+	case 0:
+		return VoidListKind
+	case 1:
+		return ByteListKind
+	case 2:
+		return TwoByteListKind
+	case 4:
+		return FourByteListKind
+	case 8:
+		return EightByteListKind
+	default:
+		panic(errListSize)
+	}
+}
+
 // elem returns the slice of segment data for a list element.
 func (p List) elem(i int) (addr Address, sz Size) {
 	if p.seg == nil || i < 0 || i >= int(p.length) {
@@ -154,11 +346,47 @@ func (p List) elem(i int) (addr Address, sz Size) {
 	return p.off.element(int32(i), p.size.totalSize()), p.size.totalSize()
 }
 
+// bulk returns the segment data for the first n elements of p and the
+// distance in bytes from the start of one element to the next.  n must
+// be at most p.Len().
+func (p List) bulk(n int) (b []byte, stride int) {
+	if n == 0 {
+		return nil, 0
+	}
+	sz := p.size.totalSize()
+	return p.seg.slice(p.off, sz.times(int32(n))), int(sz)
+}
+
+// writeBulk is like bulk, but for elements that are about to be
+// written.  It panics if p's segment is read-only.
+func (p List) writeBulk(n int) (b []byte, stride int) {
+	if n == 0 {
+		return nil, 0
+	}
+	sz := p.size.totalSize()
+	return p.seg.writeSlice(p.off, sz.times(int32(n))), int(sz)
+}
+
+// hasWidth reports whether each element of p has at least width bytes
+// of data, so that bulk can be used to read them as values of that
+// width.  A list decoded from the wire may be narrower than its type,
+// in which case callers must go through the element accessors instead.
+func (p List) hasWidth(width Size) bool {
+	return p.flags&isBitList == 0 && p.size.DataSize >= width
+}
+
 func (p List) slice(i int) []byte {
 	addr, sz := p.elem(i)
 	return p.seg.slice(addr, sz)
 }
 
+// writeSlice is like slice, but for an element that is about to be
+// written.  It panics if p's segment is read-only.
+func (p List) writeSlice(i int) []byte {
+	addr, sz := p.elem(i)
+	return p.seg.writeSlice(addr, sz)
+}
+
 // Struct returns the i'th element as a struct.
 func (p List) Struct(i int) Struct {
 	if p.flags&isBitList != 0 {
@@ -181,12 +409,142 @@ func (p List) SetStruct(i int, s Struct) error {
 	return copyStruct(copyContext{}, p.Struct(i), s)
 }
 
+// CopyList copies the elements of src into dst, which must have the
+// same kind of elements, as reported by Kind.  If the lists' lengths
+// differ, only the first min(dst.Len(), src.Len()) elements are
+// copied.  Primitive elements are copied in bulk.  Pointers, including
+// those in struct elements, are copied as by SetPointer: objects in
+// another message are deep-copied, but if dst and src are in the same
+// message, dst's pointers refer to the same objects as src's.  Struct
+// elements may differ in size: data and pointers past the end of dst's
+// elements are discarded, and the rest of each dst element is zeroed.
+func CopyList(dst, src List) error {
+	n := dst.Len()
+	if src.Len() < n {
+		n = src.Len()
+	}
+	if n == 0 {
+		return nil
+	}
+	kind := dst.Kind()
+	if src.Kind() != kind {
+		return errCopyListKind
+	}
+	if dst.seg.readOnly {
+		return errReadOnly
+	}
+	switch kind {
+	case VoidListKind:
+	case BitListKind:
+		d, s := BitList{dst}, BitList{src}
+		for i := 0; i < n; i++ {
+			d.Set(i, s.At(i))
+		}
+	case ByteListKind, TwoByteListKind, FourByteListKind, EightByteListKind:
+		db, _ := dst.writeBulk(n)
+		sb, _ := src.bulk(n)
+		copy(db, sb)
+	default:
+		for i := 0; i < n; i++ {
+			if err := copyStruct(copyContext{}, dst.Struct(i), src.Struct(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SortList sorts the elements of l in place, keeping equal elements
+// in their original order.  less reports whether the element now at
+// index i sorts before the element now at index j.  Elements are
+// moved without copying the objects they point to: data is swapped as
+// raw bytes and pointers are rewritten to reference the same objects
+// from their new places.  If l holds a pointer whose target is out of
+// range, SortList stops and returns an error, leaving l partly sorted.
+func SortList(l List, less func(i, j int) bool) error {
+	s := &listSorter{l: l, less: less}
+	sort.Stable(s)
+	return s.err
+}
+
+// listSorter implements sort.Interface for SortList.  After a swap
+// fails, it stops moving elements and records the error.
+type listSorter struct {
+	l    List
+	less func(i, j int) bool
+	err  error
+}
+
+func (s *listSorter) Len() int           { return s.l.Len() }
+func (s *listSorter) Less(i, j int) bool { return s.less(i, j) }
+
+func (s *listSorter) Swap(i, j int) {
+	if s.err == nil {
+		s.err = s.l.swap(i, j)
+	}
+}
+
+// SearchList uses binary search to find and return the smallest index
+// i in [0, l.Len()) at which f(i) is true, as sort.Search does.  l's
+// elements must be sorted so that f is false for some (possibly empty)
+// prefix of the list and true for the rest.  SearchList returns
+// l.Len() if f is false for every element.
+func SearchList(l List, f func(i int) bool) int {
+	return sort.Search(l.Len(), f)
+}
+
+// swap exchanges the i'th and j'th elements of p.
+func (p List) swap(i, j int) error {
+	if i == j {
+		return nil
+	}
+	if p.seg.readOnly {
+		return errReadOnly
+	}
+	if p.flags&isBitList != 0 {
+		b := BitList{p}
+		vi, vj := b.At(i), b.At(j)
+		b.Set(i, vj)
+		b.Set(j, vi)
+		return nil
+	}
+	ai, _ := p.elem(i)
+	aj, _ := p.elem(j)
+	pi, pj := ai.addSize(p.size.DataSize), aj.addSize(p.size.DataSize)
+	n := int32(p.size.PointerCount)
+	// Check every pointer before moving anything, so that a failed
+	// swap leaves both elements as they were.
+	for k := int32(0); k < n; k++ {
+		ki, kj := pi.element(k, wordSize), pj.element(k, wordSize)
+		if _, ok := p.seg.readRawPointer(ki).relocate(ki, kj); !ok {
+			return errPointerAddress
+		}
+		if _, ok := p.seg.readRawPointer(kj).relocate(kj, ki); !ok {
+			return errPointerAddress
+		}
+	}
+	di, dj := p.seg.slice(ai, p.size.DataSize), p.seg.slice(aj, p.size.DataSize)
+	for k := range di {
+		di[k], dj[k] = dj[k], di[k]
+	}
+	for k := int32(0); k < n; k++ {
+		ki, kj := pi.element(k, wordSize), pj.element(k, wordSize)
+		vi, _ := p.seg.readRawPointer(ki).relocate(ki, kj)
+		vj, _ := p.seg.readRawPointer(kj).relocate(kj, ki)
+		p.seg.writeRawPointer(ki, vj)
+		p.seg.writeRawPointer(kj, vi)
+	}
+	return nil
+}
+
 // A BitList is a reference to a list of booleans.
 type BitList struct{ List }
 
-// NewBitList creates a new bit list, preferring placement in s.
+// NewBitList creates a new bit list, preferring placement in s.  The
+// list packs eight elements into each byte, and its storage is rounded
+// up to a whole number of words.
 func NewBitList(s *Segment, n int32) (BitList, error) {
-	s, addr, err := alloc(s, Size(1).times((n+7)&^7))
+	s, addr, err := alloc(s, Size((int64(n)+7)/8))
 	if err != nil {
 		return BitList{}, err
 	}
@@ -210,7 +568,7 @@ func (p BitList) At(i int) bool {
 
 // Set sets the i'th bit to v.
 func (p BitList) Set(i int, v bool) {
-	b := p.slice(i)
+	b := p.writeSlice(i)
 	if b == nil {
 		panic(errOutOfBounds)
 	}
@@ -251,6 +609,37 @@ func (p PointerList) Set(i int, v Pointer) error {
 	return p.seg.writePtr(copyContext{}, addr, v)
 }
 
+// CompactPointerList returns a new list holding only the non-null
+// elements of l, in their original order.  The new list is allocated
+// in l's message and its elements point to the same objects as l's
+// elements; no objects are copied.  An invalid list yields an invalid
+// list.
+func CompactPointerList(l PointerList) (PointerList, error) {
+	if l.seg == nil {
+		return PointerList{}, nil
+	}
+	var ptrs []Pointer
+	for i := 0; i < l.Len(); i++ {
+		p, err := l.At(i)
+		if err != nil {
+			return PointerList{}, err
+		}
+		if p != nil {
+			ptrs = append(ptrs, p)
+		}
+	}
+	c, err := NewPointerList(l.seg, int32(len(ptrs)))
+	if err != nil {
+		return PointerList{}, err
+	}
+	for i, p := range ptrs {
+		if err := c.Set(i, p); err != nil {
+			return PointerList{}, err
+		}
+	}
+	return c, nil
+}
+
 // TextList is an array of pointers to strings.
 type TextList struct{ List }
 
@@ -283,6 +672,23 @@ func (l TextList) Set(i int, v string) error {
 	return p.seg.writePtr(copyContext{}, addr, p)
 }
 
+// NewTextListFromStrings allocates a new list of text pointers,
+// preferring placement in s, and sets its elements to the strings in
+// v.  Every element is non-null: an empty string is stored as
+// zero-length text.
+func NewTextListFromStrings(s *Segment, v []string) (TextList, error) {
+	l, err := NewTextList(s, int32(len(v)))
+	if err != nil {
+		return TextList{}, err
+	}
+	for i, str := range v {
+		if err := l.Set(i, str); err != nil {
+			return TextList{}, err
+		}
+	}
+	return l, nil
+}
+
 // DataList is an array of pointers to data.
 type DataList struct{ List }
 
@@ -339,9 +745,13 @@ func NewUInt8List(s *Segment, n int32) (UInt8List, error) {
 	return UInt8List{l}, nil
 }
 
-// NewText creates a new list of UInt8 from a string.
+// NewText creates a new list of UInt8 from a string.  If s's message
+// has StrictText set, NewText returns an error if v is not valid UTF-8.
 func NewText(s *Segment, v string) (UInt8List, error) {
 	// TODO(light): error if v is too long
+	if s != nil && s.msg != nil && s.msg.StrictText && !utf8.ValidString(v) {
+		return UInt8List{}, errInvalidText
+	}
 	l, err := NewUInt8List(s, int32(len(v)+1))
 	if err != nil {
 		return UInt8List{}, err
@@ -370,26 +780,62 @@ func ToText(p Pointer) string {
 // ToTextDefault attempts to convert p into Text, returning def if p is
 // not a valid 1-byte list pointer.
 func ToTextDefault(p Pointer, def string) string {
-	l, ok := toOneByteList(p)
+	b, ok := textBytes(p)
 	if !ok {
 		return def
 	}
-	b := l.seg.slice(l.off, l.size.totalSize().times(l.length))
+	return string(b)
+}
+
+// ToTextBytes is like ToText, but returns the text's bytes without the
+// NUL terminator instead of a string, so it does not allocate.  The
+// slice aliases the message's segment: it is only valid while the
+// message is, and writes to it modify the message.  It returns nil if
+// p is not a valid 1-byte list pointer.
+func ToTextBytes(p Pointer) []byte {
+	b, _ := textBytes(p)
+	return b
+}
+
+// ToTextBytesDefault is like ToTextBytes, but returns def as bytes if
+// p is not a valid 1-byte list pointer.
+func ToTextBytesDefault(p Pointer, def string) []byte {
+	b, ok := textBytes(p)
+	if !ok {
+		return []byte(def)
+	}
+	return b
+}
+
+// textBytes returns the bytes of the text p points to, excluding the
+// NUL terminator.  The slice's capacity ends before the terminator.
+func textBytes(p Pointer) (b []byte, ok bool) {
+	l, ok := toOneByteList(p)
+	if !ok {
+		return nil, false
+	}
+	b = l.seg.slice(l.off, l.size.totalSize().times(l.length))
 	if len(b) == 0 || b[len(b)-1] != 0 {
 		// Text must be null-terminated.
-		return def
+		return nil, false
 	}
-	return string(b[:len(b)-1])
+	n := len(b) - 1
+	return b[:n:n], true
 }
 
 // ToData attempts to convert p into Data, returning nil if p is not a
-// valid 1-byte list pointer.
+// valid 1-byte list pointer.  The data is not copied: the slice aliases
+// the message's segment, so it is only valid while the message is, and
+// writes to it modify the message.  Its capacity is its length, so
+// appending to it copies instead of overwriting the message.  Callers
+// that need their own copy should copy the slice.
 func ToData(p Pointer) []byte {
 	return ToDataDefault(p, nil)
 }
 
 // ToDataDefault attempts to convert p into Data, returning def if p is
-// not a valid 1-byte list pointer.
+// not a valid 1-byte list pointer.  Like ToData, it returns a slice
+// that aliases the message.
 func ToDataDefault(p Pointer, def []byte) []byte {
 	l, ok := toOneByteList(p)
 	if !ok {
@@ -399,7 +845,7 @@ func ToDataDefault(p Pointer, def []byte) []byte {
 	if b == nil {
 		return def
 	}
-	return b
+	return b[:len(b):len(b)]
 }
 
 func toOneByteList(p Pointer) (l List, ok bool) {
@@ -421,13 +867,56 @@ func (l UInt8List) At(i int) uint8 {
 
 // Set sets the i'th element to v.
 func (l UInt8List) Set(i int, v uint8) {
-	b := l.slice(i)
+	b := l.writeSlice(i)
 	if b == nil {
 		panic(errOutOfBounds)
 	}
 	b[0] = v
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l UInt8List) Search(v uint8) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l UInt8List) CopyFromSlice(src []uint8) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(1) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		b[i*stride] = v
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l UInt8List) ToSlice() []uint8 {
+	v := make([]uint8, l.Len())
+	if !l.hasWidth(1) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = b[i*stride]
+	}
+	return v
+}
+
 // Int8List is an array of Int8 values.
 type Int8List struct{ List }
 
@@ -451,13 +940,56 @@ func (l Int8List) At(i int) int8 {
 
 // Set sets the i'th element to v.
 func (l Int8List) Set(i int, v int8) {
-	b := l.slice(i)
+	b := l.writeSlice(i)
 	if b == nil {
 		panic(errOutOfBounds)
 	}
 	b[0] = uint8(v)
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Int8List) Search(v int8) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Int8List) CopyFromSlice(src []int8) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(1) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		b[i*stride] = uint8(v)
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Int8List) ToSlice() []int8 {
+	v := make([]int8, l.Len())
+	if !l.hasWidth(1) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = int8(b[i*stride])
+	}
+	return v
+}
+
 // A UInt16List is an array of UInt16 values.
 type UInt16List struct{ List }
 
@@ -482,6 +1014,49 @@ func (l UInt16List) Set(i int, v uint16) {
 	l.seg.writeUint16(addr, v)
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l UInt16List) Search(v uint16) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l UInt16List) CopyFromSlice(src []uint16) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(2) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint16(b[i*stride:], v)
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l UInt16List) ToSlice() []uint16 {
+	v := make([]uint16, l.Len())
+	if !l.hasWidth(2) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint16(b[i*stride:])
+	}
+	return v
+}
+
 // Int16List is an array of Int16 values.
 type Int16List struct{ List }
 
@@ -506,6 +1081,49 @@ func (l Int16List) Set(i int, v int16) {
 	l.seg.writeUint16(addr, uint16(v))
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Int16List) Search(v int16) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Int16List) CopyFromSlice(src []int16) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(2) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint16(b[i*stride:], uint16(v))
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Int16List) ToSlice() []int16 {
+	v := make([]int16, l.Len())
+	if !l.hasWidth(2) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = int16(binary.LittleEndian.Uint16(b[i*stride:]))
+	}
+	return v
+}
+
 // UInt32List is an array of UInt32 values.
 type UInt32List struct{ List }
 
@@ -530,6 +1148,49 @@ func (l UInt32List) Set(i int, v uint32) {
 	l.seg.writeUint32(addr, v)
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l UInt32List) Search(v uint32) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l UInt32List) CopyFromSlice(src []uint32) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(4) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint32(b[i*stride:], v)
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l UInt32List) ToSlice() []uint32 {
+	v := make([]uint32, l.Len())
+	if !l.hasWidth(4) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint32(b[i*stride:])
+	}
+	return v
+}
+
 // Int32List is an array of Int32 values.
 type Int32List struct{ List }
 
@@ -554,6 +1215,49 @@ func (l Int32List) Set(i int, v int32) {
 	l.seg.writeUint32(addr, uint32(v))
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Int32List) Search(v int32) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Int32List) CopyFromSlice(src []int32) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(4) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint32(b[i*stride:], uint32(v))
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Int32List) ToSlice() []int32 {
+	v := make([]int32, l.Len())
+	if !l.hasWidth(4) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = int32(binary.LittleEndian.Uint32(b[i*stride:]))
+	}
+	return v
+}
+
 // UInt64List is an array of UInt64 values.
 type UInt64List struct{ List }
 
@@ -578,6 +1282,49 @@ func (l UInt64List) Set(i int, v uint64) {
 	l.seg.writeUint64(addr, v)
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l UInt64List) Search(v uint64) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l UInt64List) CopyFromSlice(src []uint64) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(8) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint64(b[i*stride:], v)
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l UInt64List) ToSlice() []uint64 {
+	v := make([]uint64, l.Len())
+	if !l.hasWidth(8) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = binary.LittleEndian.Uint64(b[i*stride:])
+	}
+	return v
+}
+
 // Int64List is an array of Int64 values.
 type Int64List struct{ List }
 
@@ -602,6 +1349,49 @@ func (l Int64List) Set(i int, v int64) {
 	l.seg.writeUint64(addr, uint64(v))
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Int64List) Search(v int64) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Int64List) CopyFromSlice(src []int64) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(8) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint64(b[i*stride:], uint64(v))
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Int64List) ToSlice() []int64 {
+	v := make([]int64, l.Len())
+	if !l.hasWidth(8) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = int64(binary.LittleEndian.Uint64(b[i*stride:]))
+	}
+	return v
+}
+
 // Float32List is an array of Float32 values.
 type Float32List struct{ List }
 
@@ -626,6 +1416,49 @@ func (l Float32List) Set(i int, v float32) {
 	l.seg.writeUint32(addr, math.Float32bits(v))
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Float32List) Search(v float32) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Float32List) CopyFromSlice(src []float32) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(4) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint32(b[i*stride:], math.Float32bits(v))
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Float32List) ToSlice() []float32 {
+	v := make([]float32, l.Len())
+	if !l.hasWidth(4) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*stride:]))
+	}
+	return v
+}
+
 // Float64List is an array of Float64 values.
 type Float64List struct{ List }
 
@@ -650,11 +1483,78 @@ func (l Float64List) Set(i int, v float64) {
 	l.seg.writeUint64(addr, math.Float64bits(v))
 }
 
+// Search returns the smallest index i at which l.At(i) >= v, or
+// l.Len() if there is none.  l must be sorted in ascending order.
+func (l Float64List) Search(v float64) int {
+	return SearchList(l.List, func(i int) bool { return l.At(i) >= v })
+}
+
+// CopyFromSlice sets the first elements of l to the values in src and
+// returns the number of elements set, the minimum of len(src) and
+// l.Len().
+func (l Float64List) CopyFromSlice(src []float64) int {
+	n := len(src)
+	if n > l.Len() {
+		n = l.Len()
+	}
+	if !l.hasWidth(8) {
+		for i, v := range src[:n] {
+			l.Set(i, v)
+		}
+		return n
+	}
+	b, stride := l.writeBulk(n)
+	for i, v := range src[:n] {
+		binary.LittleEndian.PutUint64(b[i*stride:], math.Float64bits(v))
+	}
+	return n
+}
+
+// ToSlice returns a new slice holding the elements of l.
+func (l Float64List) ToSlice() []float64 {
+	v := make([]float64, l.Len())
+	if !l.hasWidth(8) {
+		for i := range v {
+			v[i] = l.At(i)
+		}
+		return v
+	}
+	b, stride := l.bulk(len(v))
+	for i := range v {
+		v[i] = math.Float64frombits(binary.LittleEndian.Uint64(b[i*stride:]))
+	}
+	return v
+}
+
+// A ListKind is the element encoding of a list.  Its values match the
+// element size field of a list pointer.
+type ListKind uint8
+
+// List kinds.
+const (
+	VoidListKind ListKind = iota
+	BitListKind
+	ByteListKind
+	TwoByteListKind
+	FourByteListKind
+	EightByteListKind
+	PointerListKind
+	CompositeListKind
+)
+
 type listFlags uint8
 
 const (
 	isCompositeList listFlags = 1 << iota
 	isBitList
+	isListSlice // a view into another list that can't be pointed to
 )
 
-var errBitListStruct = errors.New("capnp: SetStruct called on bit list")
+var (
+	errBitListStruct = errors.New("capnp: SetStruct called on bit list")
+	errAppendList    = errors.New("capnp: AppendStruct called on non-struct list")
+	errInvalidText   = errors.New("capnp: text is not valid UTF-8")
+	errSliceBounds   = errors.New("capnp: list slice out of bounds")
+	errBitListSlice  = errors.New("capnp: bit list slice must start on a byte boundary")
+	errCopyListKind  = errors.New("capnp: CopyList between lists of different element kinds")
+)
@@ -1,6 +1,9 @@
 package capnp
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"testing"
 )
 
@@ -50,6 +53,45 @@ func TestToListDefault(t *testing.T) {
 	}
 }
 
+func TestToListErr(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ptr    Pointer
+		list   List
+		errMsg string
+	}{
+		{ptr: nil, list: List{}},
+		{ptr: List{}, list: List{}},
+		{
+			ptr:  List{seg: seg, off: 8, length: 1, size: ObjectSize{DataSize: 8}},
+			list: List{seg: seg, off: 8, length: 1, size: ObjectSize{DataSize: 8}},
+		},
+		{ptr: Struct{seg: seg, off: 8, size: ObjectSize{DataSize: 8}}, errMsg: "capnp: expected list pointer, got struct pointer"},
+		{ptr: Interface{seg: seg, cap: 0}, errMsg: "capnp: expected list pointer, got interface pointer"},
+	}
+	for _, test := range tests {
+		list, err := ToListErr(test.ptr)
+		if test.errMsg != "" {
+			if err == nil {
+				t.Errorf("ToListErr(%#v) = %#v, <nil>; want error %q", test.ptr, list, test.errMsg)
+			} else if err.Error() != test.errMsg {
+				t.Errorf("ToListErr(%#v) error = %q; want %q", test.ptr, err.Error(), test.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ToListErr(%#v) error: %v", test.ptr, err)
+			continue
+		}
+		if list != test.list {
+			t.Errorf("ToListErr(%#v) = %#v; want %#v", test.ptr, list, test.list)
+		}
+	}
+}
+
 func TestListValue(t *testing.T) {
 	_, seg, err := NewMessage(SingleSegment(nil))
 	if err != nil {
@@ -112,3 +154,942 @@ func TestListValue(t *testing.T) {
 		}
 	}
 }
+
+func TestCompactPointerList(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewPointerList(seg, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Elements 1, 2 and 4 are set; 0, 3 and 5 are null.
+	want := map[int]string{1: "foo", 2: "bar", 4: "baz"}
+	for i, v := range want {
+		txt, err := NewText(seg, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Set(i, txt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := CompactPointerList(l)
+	if err != nil {
+		t.Fatal("CompactPointerList:", err)
+	}
+	wantOrder := []string{"foo", "bar", "baz"}
+	if c.Len() != len(wantOrder) {
+		t.Fatalf("CompactPointerList(l).Len() = %d; want %d", c.Len(), len(wantOrder))
+	}
+	for i, w := range wantOrder {
+		p, err := c.At(i)
+		if err != nil {
+			t.Errorf("CompactPointerList(l).At(%d) error: %v", i, err)
+			continue
+		}
+		if s := ToText(p); s != w {
+			t.Errorf("CompactPointerList(l).At(%d) = %q; want %q", i, s, w)
+		}
+	}
+	if l.Len() != 6 {
+		t.Errorf("l.Len() after CompactPointerList = %d; want 6", l.Len())
+	}
+
+	if c, err := CompactPointerList(PointerList{}); err != nil || IsValid(c) {
+		t.Errorf("CompactPointerList(PointerList{}) = %#v, %v; want invalid list, <nil>", c, err)
+	}
+}
+
+func TestSortList(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ints, err := NewInt64List(seg, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []int64{3, -1, 4, 1, -5} {
+		ints.Set(i, v)
+	}
+	if err := SortList(ints.List, func(i, j int) bool { return ints.At(i) < ints.At(j) }); err != nil {
+		t.Fatal("SortList(ints):", err)
+	}
+	for i, want := range []int64{-5, -1, 1, 3, 4} {
+		if v := ints.At(i); v != want {
+			t.Errorf("sorted ints.At(%d) = %d; want %d", i, v, want)
+		}
+	}
+
+	texts, err := NewTextList(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []string{"pear", "apple", "fig"} {
+		if err := texts.Set(i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = SortList(texts.List, func(i, j int) bool {
+		a, _ := texts.At(i)
+		b, _ := texts.At(j)
+		return a < b
+	})
+	if err != nil {
+		t.Fatal("SortList(texts):", err)
+	}
+	for i, want := range []string{"apple", "fig", "pear"} {
+		if v, err := texts.At(i); err != nil || v != want {
+			t.Errorf("sorted texts.At(%d) = %q, %v; want %q, <nil>", i, v, err, want)
+		}
+	}
+
+	// Sort by the data field only; elements with equal keys must keep
+	// their order and their names.
+	structs, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range []struct {
+		key  uint64
+		name string
+	}{{2, "b1"}, {1, "a"}, {2, "b2"}, {0, "z"}} {
+		s := structs.Struct(i)
+		s.SetUint64(0, e.key)
+		txt, err := NewText(seg, e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = SortList(structs, func(i, j int) bool {
+		return structs.Struct(i).Uint64(0) < structs.Struct(j).Uint64(0)
+	})
+	if err != nil {
+		t.Fatal("SortList(structs):", err)
+	}
+	for i, want := range []string{"z", "a", "b1", "b2"} {
+		p, err := structs.Struct(i).Pointer(0)
+		if err != nil {
+			t.Errorf("sorted structs.Struct(%d).Pointer(0) error: %v", i, err)
+			continue
+		}
+		if name := ToText(p); name != want {
+			t.Errorf("sorted structs.Struct(%d) name = %q; want %q", i, name, want)
+		}
+	}
+
+	bits, err := NewBitList(seg, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(0, true)
+	bits.Set(3, true)
+	if err := SortList(bits.List, func(i, j int) bool { return !bits.At(i) && bits.At(j) }); err != nil {
+		t.Fatal("SortList(bits):", err)
+	}
+	for i, want := range []bool{false, false, false, true, true} {
+		if v := bits.At(i); v != want {
+			t.Errorf("sorted bits.At(%d) = %t; want %t", i, v, want)
+		}
+	}
+
+	// A zero-sized struct moved into the slot before it must not
+	// become a null pointer.
+	ptrs, err := NewPointerList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	big.SetUint64(0, 42)
+	empty, err := NewStruct(seg, ObjectSize{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ptrs.Set(0, big); err != nil {
+		t.Fatal(err)
+	}
+	if err := ptrs.Set(1, empty); err != nil {
+		t.Fatal(err)
+	}
+	err = SortList(ptrs.List, func(i, j int) bool {
+		a, _ := ptrs.At(i)
+		b, _ := ptrs.At(j)
+		return ToStruct(a).Size().DataSize < ToStruct(b).Size().DataSize
+	})
+	if err != nil {
+		t.Fatal("SortList(ptrs):", err)
+	}
+	for i, want := range []Size{0, 8} {
+		p, err := ptrs.At(i)
+		if err != nil {
+			t.Errorf("sorted ptrs.At(%d) error: %v", i, err)
+			continue
+		}
+		if p == nil {
+			t.Errorf("sorted ptrs.At(%d) = nil; want struct with %d data bytes", i, want)
+			continue
+		}
+		if sz := ToStruct(p).Size().DataSize; sz != want {
+			t.Errorf("sorted ptrs.At(%d) data size = %d; want %d", i, sz, want)
+		}
+	}
+	if p, _ := ptrs.At(1); ToStruct(p).Uint64(0) != 42 {
+		t.Errorf("sorted ptrs.At(1).Uint64(0) = %d; want 42", ToStruct(p).Uint64(0))
+	}
+}
+
+func TestListSlice(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nums, err := NewInt32List(seg, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < nums.Len(); i++ {
+		nums.Set(i, int32(i*10))
+	}
+	sl, err := nums.Slice(1, 4)
+	if err != nil {
+		t.Fatal("Slice(1, 4):", err)
+	}
+	view := Int32List{sl}
+	if n := view.Len(); n != 3 {
+		t.Fatalf("slice Len() = %d; want 3", n)
+	}
+	if v := view.At(0); v != 10 {
+		t.Errorf("slice At(0) = %d; want 10", v)
+	}
+	view.Set(2, 99)
+	if v := nums.At(3); v != 99 {
+		t.Errorf("after writing through slice, parent At(3) = %d; want 99", v)
+	}
+	for _, r := range [][2]int{{-1, 2}, {3, 2}, {0, 6}} {
+		if _, err := nums.Slice(r[0], r[1]); err != errSliceBounds {
+			t.Errorf("Slice(%d, %d) error = %v; want %v", r[0], r[1], err, errSliceBounds)
+		}
+	}
+
+	// Storing a slice of a composite list copies it with its own tag.
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		l.Struct(i).SetUint64(0, uint64(i+1))
+	}
+	sub, err := l.Slice(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, err := NewStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.SetPointer(0, sub); err != nil {
+		t.Fatal("SetPointer(composite slice):", err)
+	}
+	p, err := holder.Pointer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := ToList(p)
+	if got.Len() != 2 || got.Struct(0).Uint64(0) != 3 || got.Struct(1).Uint64(0) != 4 {
+		t.Errorf("stored composite slice = len %d; want elements 3, 4", got.Len())
+	}
+	if got.Address() == sub.Address() {
+		t.Error("stored composite slice refers to parent's elements; want copy")
+	}
+
+	// Bit list slices start on a byte boundary.
+	bits, err := NewBitList(seg, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(8, true)
+	bits.Set(79, true)
+	if _, err := bits.Slice(3, 10); err != errBitListSlice {
+		t.Errorf("bit list Slice(3, 10) error = %v; want %v", err, errBitListSlice)
+	}
+	bsl, err := bits.Slice(8, 80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.SetPointer(1, bsl); err != nil {
+		t.Fatal("SetPointer(bit list slice):", err)
+	}
+	if p, err = holder.Pointer(1); err != nil {
+		t.Fatal(err)
+	}
+	stored := BitList{ToList(p)}
+	if stored.Len() != 72 || !stored.At(0) || stored.At(1) || !stored.At(71) {
+		t.Errorf("stored bit list slice = len %d, [0]=%t [1]=%t [71]=%t; want 72, true, false, true",
+			stored.Len(), stored.At(0), stored.At(1), stored.At(71))
+	}
+}
+
+func TestNewBitListSize(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(seg.Data())
+	bits, err := NewBitList(seg, 65)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(seg.Data()) - before; n != 16 {
+		t.Errorf("NewBitList(seg, 65) allocated %d bytes; want 16", n)
+	}
+	if bits.Len() != 65 {
+		t.Errorf("bits.Len() = %d; want 65", bits.Len())
+	}
+	bits.Set(0, true)
+	bits.Set(64, true)
+	for i := 0; i < bits.Len(); i++ {
+		if want := i == 0 || i == 64; bits.At(i) != want {
+			t.Errorf("bits.At(%d) = %t; want %t", i, bits.At(i), want)
+		}
+	}
+}
+
+func TestCopyList(t *testing.T) {
+	_, src, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dst, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snums, err := NewUInt16List(src, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snums.Set(0, 1)
+	snums.Set(1, 2)
+	snums.Set(2, 3)
+	dnums, err := NewUInt16List(dst, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyList(dnums.List, snums.List); err != nil {
+		t.Fatal("CopyList(uint16):", err)
+	}
+	if dnums.At(0) != 1 || dnums.At(1) != 2 {
+		t.Errorf("copied uint16 list = [%d %d]; want [1 2]", dnums.At(0), dnums.At(1))
+	}
+
+	stexts, err := NewTextListFromStrings(src, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtexts, err := NewTextList(dst, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyList(dtexts.List, stexts.List); err != nil {
+		t.Fatal("CopyList(text):", err)
+	}
+	for i, want := range []string{"a", "b"} {
+		if got, err := dtexts.At(i); err != nil || got != want {
+			t.Errorf("copied text list [%d] = %q, %v; want %q, <nil>", i, got, err, want)
+		}
+	}
+	p, err := PointerList{dtexts.List}.At(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Segment().Message() != dst.Message() {
+		t.Error("copied text element is not in the destination message")
+	}
+
+	sstructs, err := NewCompositeList(src, ObjectSize{DataSize: 16}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sstructs.Struct(0).SetUint64(0, 10)
+	sstructs.Struct(0).SetUint64(8, 11)
+	sstructs.Struct(1).SetUint64(0, 20)
+	dstructs, err := NewCompositeList(dst, ObjectSize{DataSize: 8}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyList(dstructs, sstructs); err != nil {
+		t.Fatal("CopyList(struct):", err)
+	}
+	if a, b := dstructs.Struct(0).Uint64(0), dstructs.Struct(1).Uint64(0); a != 10 || b != 20 {
+		t.Errorf("copied struct list = [%d %d]; want [10 20]", a, b)
+	}
+
+	if err := CopyList(dnums.List, stexts.List); err != errCopyListKind {
+		t.Errorf("CopyList between kinds error = %v; want %v", err, errCopyListKind)
+	}
+
+	// Within a message, the copied pointers share their targets.
+	same, err := NewTextList(src, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyList(same.List, stexts.List); err != nil {
+		t.Fatal("CopyList(text) in same message:", err)
+	}
+	for i := 0; i < 2; i++ {
+		sp, err := PointerList{stexts.List}.At(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dp, err := PointerList{same.List}.At(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sl, dl := ToList(sp), ToList(dp); dl.Segment() != sl.Segment() || dl.Address() != sl.Address() {
+			t.Errorf("same-message copy [%d] at %v; want shared with source at %v", i, dl.Address(), sl.Address())
+		}
+	}
+}
+
+func TestSearchList(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewInt64List(seg, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []int64{-5, -1, 1, 3, 3} {
+		l.Set(i, v)
+	}
+	tests := []struct {
+		v    int64
+		want int
+	}{
+		{-10, 0},
+		{-5, 0},
+		{0, 2},
+		{3, 3},
+		{4, 5},
+	}
+	for _, test := range tests {
+		if i := l.Search(test.v); i != test.want {
+			t.Errorf("l.Search(%d) = %d; want %d", test.v, i, test.want)
+		}
+		i := SearchList(l.List, func(i int) bool { return l.At(i) >= test.v })
+		if i != test.want {
+			t.Errorf("SearchList(l, >= %d) = %d; want %d", test.v, i, test.want)
+		}
+	}
+	if i := (Float64List{}).Search(1); i != 0 {
+		t.Errorf("Float64List{}.Search(1) = %d; want 0", i)
+	}
+}
+
+func TestPrimitiveListCopyFromSlice(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewInt32List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := l.CopyFromSlice([]int32{1, -2, 3, 4}); n != 3 {
+		t.Errorf("l.CopyFromSlice(4 elements) = %d; want 3", n)
+	}
+	if n := l.CopyFromSlice([]int32{7}); n != 1 {
+		t.Errorf("l.CopyFromSlice(1 element) = %d; want 1", n)
+	}
+	want := []int32{7, -2, 3}
+	for i, w := range want {
+		if v := l.At(i); v != w {
+			t.Errorf("l.At(%d) = %d; want %d", i, v, w)
+		}
+	}
+	if v := l.ToSlice(); !reflect.DeepEqual(v, want) {
+		t.Errorf("l.ToSlice() = %v; want %v", v, want)
+	}
+
+	f, err := NewFloat64List(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.CopyFromSlice([]float64{1.5, -0.25})
+	if v := f.ToSlice(); !reflect.DeepEqual(v, []float64{1.5, -0.25}) {
+		t.Errorf("f.ToSlice() = %v; want [1.5 -0.25]", v)
+	}
+
+	if v := (UInt16List{}).ToSlice(); len(v) != 0 {
+		t.Errorf("UInt16List{}.ToSlice() = %v; want []", v)
+	}
+	if n := (UInt16List{}).CopyFromSlice([]uint16{1}); n != 0 {
+		t.Errorf("UInt16List{}.CopyFromSlice(1 element) = %d; want 0", n)
+	}
+
+	// Lists read from the wire can be narrower than their type.
+	u8, err := NewUInt8List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u8.CopyFromSlice([]uint8{1, 2, 3})
+	wide := UInt16List{u8.List}
+	if v, want := wide.ToSlice(), []uint16{wide.At(0), wide.At(1), wide.At(2)}; !reflect.DeepEqual(v, want) {
+		t.Errorf("UInt16List over 1-byte list ToSlice() = %v; want %v", v, want)
+	}
+	if n := wide.CopyFromSlice([]uint16{0x0504}); n != 1 || u8.At(0) != 4 {
+		t.Errorf("UInt16List over 1-byte list CopyFromSlice(1 element) = %d, first byte %d; want 1, 4", n, u8.At(0))
+	}
+	bits, err := NewBitList(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(1, true)
+	i64 := Int64List{bits.List}
+	if v, want := i64.ToSlice(), []int64{i64.At(0), i64.At(1), i64.At(2)}; !reflect.DeepEqual(v, want) {
+		t.Errorf("Int64List over bit list ToSlice() = %v; want %v", v, want)
+	}
+	if n := i64.CopyFromSlice([]int64{0}); n != 1 || bits.At(1) {
+		t.Errorf("Int64List over bit list CopyFromSlice(1 element) = %d, bit 1 = %t; want 1, false", n, bits.At(1))
+	}
+}
+
+func TestStrictText(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const bad = "a\xffb"
+	if _, err := NewText(seg, bad); err != nil {
+		t.Errorf("NewText(%q) without StrictText: %v", bad, err)
+	}
+	msg.StrictText = true
+	if _, err := NewText(seg, bad); err == nil {
+		t.Errorf("NewText(%q) with StrictText succeeded; want error", bad)
+	}
+	if _, err := NewText(seg, "héllo"); err != nil {
+		t.Errorf("NewText(\"héllo\") with StrictText: %v", err)
+	}
+	l, err := NewTextList(seg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Set(0, bad); err == nil {
+		t.Errorf("TextList.Set(0, %q) with StrictText succeeded; want error", bad)
+	}
+}
+
+func TestNewTextListFromStrings(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"foo", "", "bar"}
+	l, err := NewTextListFromStrings(seg, want)
+	if err != nil {
+		t.Fatal("NewTextListFromStrings:", err)
+	}
+	if l.Len() != len(want) {
+		t.Fatalf("l.Len() = %d; want %d", l.Len(), len(want))
+	}
+	for i, w := range want {
+		if v, err := l.At(i); err != nil || v != w {
+			t.Errorf("l.At(%d) = %q, %v; want %q, <nil>", i, v, err, w)
+		}
+		if p, err := (PointerList{l.List}).At(i); err != nil || !IsValid(p) {
+			t.Errorf("element %d pointer = %v, %v; want non-null", i, p, err)
+		}
+	}
+
+	empty, err := NewTextListFromStrings(seg, nil)
+	if err != nil || !IsValid(empty) || empty.Len() != 0 {
+		t.Errorf("NewTextListFromStrings(nil) = %#v, %v; want valid empty list, <nil>", empty, err)
+	}
+}
+
+func TestCompositeListTagCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		words int32
+		tag   rawPointer
+		ok    bool
+	}{
+		{"exact", 2, rawStructPointer(2, ObjectSize{DataSize: 8}), true},
+		{"short", 3, rawStructPointer(2, ObjectSize{DataSize: 8}), true},
+		{"too many elements", 2, rawStructPointer(3, ObjectSize{DataSize: 8}), false},
+		{"elements too large", 2, rawStructPointer(2, ObjectSize{DataSize: 8, PointerCount: 1}), false},
+		{"negative count", 2, rawStructPointer(-1, ObjectSize{DataSize: 8}), false},
+	}
+	for _, test := range tests {
+		// Leave plenty of room after the list so that only the tag
+		// check can catch the inconsistency.
+		msg := &Message{Arena: SingleSegment(make([]byte, 8*8))}
+		seg, err := msg.Segment(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seg.writeRawPointer(0, rawListPointer(0, compositeList, test.words))
+		seg.writeRawPointer(8, test.tag)
+		p, err := seg.readPtr(0)
+		if test.ok {
+			if err != nil {
+				t.Errorf("%s: readPtr error: %v", test.name, err)
+			} else if !IsValid(ToList(p)) {
+				t.Errorf("%s: readPtr = %#v; want list", test.name, p)
+			}
+			continue
+		}
+		if err != errBadTag {
+			t.Errorf("%s: readPtr = %#v, %v; want error %v", test.name, p, err, errBadTag)
+		}
+	}
+}
+
+func TestNewCompositeListFunc(t *testing.T) {
+	for _, count := range []int{0, 1, 8, 9, 37} {
+		_, seg, err := NewMessage(SingleSegment(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sz := ObjectSize{DataSize: 8, PointerCount: 1}
+		l, err := NewCompositeListFunc(seg, sz, func(i int, s Struct) (bool, error) {
+			if i == count {
+				return false, nil
+			}
+			s.SetUint64(0, uint64(i*i))
+			txt, err := NewText(s.Segment(), string(rune('a'+i%26)))
+			if err != nil {
+				return false, err
+			}
+			return true, s.SetPointer(0, txt)
+		})
+		if err != nil {
+			t.Errorf("NewCompositeListFunc(%d elements) error: %v", count, err)
+			continue
+		}
+		if l.Len() != count {
+			t.Errorf("NewCompositeListFunc(%d elements).Len() = %d", count, l.Len())
+			continue
+		}
+
+		// Read the list back through a pointer to check the tag word.
+		root, err := NewRootStruct(seg, ObjectSize{PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := root.SetPointer(0, l); err != nil {
+			t.Fatal(err)
+		}
+		p, err := root.Pointer(0)
+		if err != nil {
+			t.Fatalf("reading back %d-element list: %v", count, err)
+		}
+		l = ToList(p)
+		if l.Len() != count {
+			t.Errorf("read back %d-element list with Len() = %d", count, l.Len())
+			continue
+		}
+		for i := 0; i < count; i++ {
+			s := l.Struct(i)
+			if v := s.Uint64(0); v != uint64(i*i) {
+				t.Errorf("%d-element list [%d].Uint64(0) = %d; want %d", count, i, v, i*i)
+			}
+			tp, err := s.Pointer(0)
+			if err != nil {
+				t.Errorf("%d-element list [%d].Pointer(0) error: %v", count, i, err)
+				continue
+			}
+			if got, want := ToText(tp), string(rune('a'+i%26)); got != want {
+				t.Errorf("%d-element list [%d] text = %q; want %q", count, i, got, want)
+			}
+		}
+	}
+}
+
+func TestAppendStruct(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l List
+	for i := 0; i < 3; i++ {
+		v, err := NewStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.SetUint64(0, uint64(i+1))
+		txt, err := NewText(seg, fmt.Sprint("item", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		l, err = msg.AppendStruct(l, v)
+		if err != nil {
+			t.Fatalf("AppendStruct #%d: %v", i, err)
+		}
+	}
+	// A smaller struct still fits, with its missing fields zeroed.
+	small, err := NewStruct(seg, ObjectSize{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = msg.AppendStruct(l, small)
+	if err != nil {
+		t.Fatal("AppendStruct(small):", err)
+	}
+
+	if l.Len() != 4 {
+		t.Fatalf("l.Len() = %d; want 4", l.Len())
+	}
+	for i := 0; i < 4; i++ {
+		s := l.Struct(i)
+		want, wantText := uint64(i+1), fmt.Sprint("item", i)
+		if i == 3 {
+			want, wantText = 0, ""
+		}
+		if v := s.Uint64(0); v != want {
+			t.Errorf("l.Struct(%d).Uint64(0) = %d; want %d", i, v, want)
+		}
+		p, err := s.Pointer(0)
+		if err != nil {
+			t.Errorf("l.Struct(%d).Pointer(0): %v", i, err)
+			continue
+		}
+		if txt := ToText(p); txt != wantText {
+			t.Errorf("l.Struct(%d) text = %q; want %q", i, txt, wantText)
+		}
+	}
+
+	ints, err := NewInt64List(seg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := msg.AppendStruct(ints.List, small); err == nil {
+		t.Error("AppendStruct on Int64List succeeded; want error")
+	}
+}
+
+func TestNewCompositeListFuncError(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	errStop := errors.New("stop")
+	_, err = NewCompositeListFunc(seg, ObjectSize{DataSize: 8}, func(i int, s Struct) (bool, error) {
+		if i == 3 {
+			return false, errStop
+		}
+		return true, nil
+	})
+	if err != errStop {
+		t.Errorf("NewCompositeListFunc error = %v; want %v", err, errStop)
+	}
+}
+
+func TestNewCompositeListFuncMovesElements(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first Struct
+	l, err := NewCompositeListFunc(seg, ObjectSize{DataSize: 8}, func(i int, s Struct) (bool, error) {
+		if i == 0 {
+			first = s
+		}
+		s.SetUint64(0, uint64(i+1))
+		return i < 20, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The list grew after the first element was filled in, so the
+	// element f was given is no longer part of the list.
+	if first.Address() == l.Struct(0).Address() {
+		t.Error("first element was not moved when the list grew")
+	}
+	if v := l.Struct(0).Uint64(0); v != 1 {
+		t.Errorf("l.Struct(0).Uint64(0) = %d; want 1", v)
+	}
+	// The list it was moved out of is zeroed.
+	if v := first.Uint64(0); v != 0 {
+		t.Errorf("old first element Uint64(0) = %d; want 0", v)
+	}
+}
+
+func TestListKind(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits, err := NewBitList(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u8, err := NewUInt8List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u16, err := NewUInt16List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u32, err := NewUInt32List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u64, err := NewUInt64List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ptrs, err := NewPointerList(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	comp, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A composite list of pointer-only structs must not be mistaken
+	// for a pointer list.
+	compPtr, err := NewCompositeList(seg, ObjectSize{PointerCount: 1}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		l    List
+		kind ListKind
+	}{
+		{"void", NewVoidList(seg, 3).List, VoidListKind},
+		{"bit", bits.List, BitListKind},
+		{"byte", u8.List, ByteListKind},
+		{"two byte", u16.List, TwoByteListKind},
+		{"four byte", u32.List, FourByteListKind},
+		{"eight byte", u64.List, EightByteListKind},
+		{"pointer", ptrs.List, PointerListKind},
+		{"composite", comp, CompositeListKind},
+		{"composite of pointers", compPtr, CompositeListKind},
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: uint16(len(tests))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range tests {
+		if k := test.l.Kind(); k != test.kind {
+			t.Errorf("%s list Kind() = %d; want %d", test.name, k, test.kind)
+		}
+		if err := root.SetPointer(uint16(i), test.l); err != nil {
+			t.Fatalf("%s list SetPointer: %v", test.name, err)
+		}
+	}
+	if k := (List{}).Kind(); k != VoidListKind {
+		t.Errorf("List{}.Kind() = %d; want %d", k, VoidListKind)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	msg2, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	rp, err := msg2.Root()
+	if err != nil {
+		t.Fatal("Root:", err)
+	}
+	root2 := ToStruct(rp)
+	for i, test := range tests {
+		p, err := root2.Pointer(uint16(i))
+		if err != nil {
+			t.Errorf("decoded %s list: %v", test.name, err)
+			continue
+		}
+		if k := ToList(p).Kind(); k != test.kind {
+			t.Errorf("decoded %s list Kind() = %d; want %d", test.name, k, test.kind)
+		}
+	}
+}
+
+func TestToDataAliases(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := NewData(seg, []byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := NewData(seg, []byte("xyz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := ToData(data)
+	if string(b) != "abc" {
+		t.Fatalf("ToData = %q; want \"abc\"", b)
+	}
+	b[0] = 'A'
+	if b := ToData(data); string(b) != "Abc" {
+		t.Errorf("after writing through ToData, ToData = %q; want \"Abc\"", b)
+	}
+	if cap(b) != len(b) {
+		t.Errorf("cap(ToData) = %d; want %d", cap(b), len(b))
+	}
+	_ = append(b, "----------"...)
+	if b := ToData(next); string(b) != "xyz" {
+		t.Errorf("after appending to ToData result, following data = %q; want \"xyz\"", b)
+	}
+}
+
+func TestToTextBytes(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := ToTextBytes(txt)
+	if string(b) != "hello" {
+		t.Fatalf("ToTextBytes = %q; want \"hello\"", b)
+	}
+	if cap(b) != len(b) {
+		t.Errorf("cap(ToTextBytes) = %d; want %d so appends cannot clobber the terminator", cap(b), len(b))
+	}
+	b[0] = 'j'
+	if s := ToText(txt); s != "jello" {
+		t.Errorf("after writing through ToTextBytes, ToText = %q; want \"jello\"", s)
+	}
+
+	empty, err := NewText(seg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := ToTextBytes(empty); b == nil || len(b) != 0 {
+		t.Errorf("ToTextBytes(empty text) = %#v; want empty non-nil slice", b)
+	}
+	if b := ToTextBytes(nil); b != nil {
+		t.Errorf("ToTextBytes(nil) = %#v; want nil", b)
+	}
+	data, err := NewData(seg, []byte("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := ToTextBytesDefault(data, "def"); string(b) != "def" {
+		t.Errorf("ToTextBytesDefault(unterminated) = %q; want \"def\"", b)
+	}
+}
@@ -1,10 +1,13 @@
 package capnp
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
 	"math"
+	"strconv"
+	"sync"
 
 	"zombiezen.com/go/capnproto2/internal/packed"
 )
@@ -23,7 +26,50 @@ type Message struct {
 	// more details on the capability table.
 	CapTable []Client
 
-	segs map[SegmentID]*Segment
+	// OnAllocFail, if not nil, is called when the arena fails to
+	// allocate a requested number of bytes.  It can try to make room,
+	// for example by releasing memory that the arena draws from.  If it
+	// returns nil, the allocation is retried once; if that also fails,
+	// the arena's error is returned without calling OnAllocFail again.
+	// If OnAllocFail returns an error, the allocation fails with that
+	// error.
+	OnAllocFail func(requested Size) error
+
+	// OnAlloc, if not nil, is called before every object is allocated
+	// in the message, with the object's size rounded up to a whole
+	// number of words.  It can be used to track how much memory the
+	// message uses.  If OnAlloc returns an error, nothing is allocated
+	// and the allocation fails with that error, which makes it a way to
+	// limit how large a message may grow.
+	OnAlloc func(sz Size) error
+
+	// TraversalLimit, if not zero, is the most data, in words, that may
+	// be read through pointers in the message.  Every struct or list
+	// reached through a pointer counts its size against the limit, even
+	// if other pointers already reached the same data, and lists of
+	// zero-sized elements count one word per element.  Once the limit
+	// is spent, reading a pointer returns an error.  This bounds the
+	// work done on a malicious message that points many pointers at
+	// the same data.  Reset clears the count of words read.
+	TraversalLimit uint64
+
+	// StrictText, if true, makes NewText return an error instead of
+	// storing a string that is not valid UTF-8.  Generated text setters
+	// and TextList.Set use NewText, so they are checked too.  Text read
+	// from the message is never checked.
+	StrictText bool
+
+	segs      map[SegmentID]*Segment
+	traversed uint64 // words read through pointers, for TraversalLimit
+
+	// Counts of allocations since the message was created or Reset,
+	// for AllocStats.
+	allocs     uint64
+	allocBytes uint64
+
+	// pads holds the landing pads of far pointers written so far, so
+	// that later far pointers to the same object can share them.
+	pads map[landingKey]landingPad
 }
 
 // NewMessage creates a message with a new root and returns the first
@@ -63,6 +109,64 @@ func (m *Message) Root() (Pointer, error) {
 	return s.root().At(0)
 }
 
+// Capabilities returns the IDs of every capability referenced by an
+// interface pointer reachable from the message's root, in the order
+// they are first found.  Each ID appears at most once.
+func (m *Message) Capabilities() ([]CapabilityID, error) {
+	root, err := m.Root()
+	if err != nil {
+		return nil, err
+	}
+	w := newCapWalker()
+	if err := w.walk(root, 0); err != nil {
+		return nil, err
+	}
+	return w.ids, nil
+}
+
+// MaxDepth returns the deepest pointer nesting in the message: the
+// number of pointers a reader must follow from the root pointer to
+// reach the most deeply nested object.  A message whose root is a
+// struct without pointers has depth 1, and a null root has depth 0.
+// Far pointers do not count toward the depth.  MaxDepth returns an
+// error if the message contains a pointer cycle or is more than 64
+// levels deep.
+func (m *Message) MaxDepth() (int, error) {
+	root, err := m.Root()
+	if err != nil {
+		return 0, err
+	}
+	w := &depthWalker{
+		onPath: make(map[objectKey]struct{}),
+		memo:   make(map[objectKey]int),
+	}
+	d, err := w.depth(root)
+	if err != nil {
+		return 0, err
+	}
+	if d > maxSizeDepth {
+		return 0, errMaxDepth
+	}
+	return d, nil
+}
+
+// Equal reports whether m and o have equal roots, as defined by
+// Struct.Equal.  The comparison follows pointers, so it does not
+// depend on how either message's objects are laid out or split into
+// segments.  Equal returns an error if either root cannot be read or
+// its pointers nest too deeply, which includes any pointer cycle.
+func (m *Message) Equal(o *Message) (bool, error) {
+	a, err := m.Root()
+	if err != nil {
+		return false, err
+	}
+	b, err := o.Root()
+	if err != nil {
+		return false, err
+	}
+	return newEqualWalker().pointerEqual(a, b, 0)
+}
+
 // SetRoot sets the message's root object to p.
 func (m *Message) SetRoot(p Pointer) error {
 	s, err := m.Segment(0)
@@ -80,11 +184,63 @@ func (m *Message) AddCap(c Client) CapabilityID {
 	return n
 }
 
+// NumCaps returns the number of entries in the message's capability
+// table.
+func (m *Message) NumCaps() int {
+	return len(m.CapTable)
+}
+
+// Caps returns a copy of the message's capability table, indexed by
+// CapabilityID, so that it can be inspected without changing the
+// message.  The clients themselves are not copied.
+func (m *Message) Caps() []Client {
+	if len(m.CapTable) == 0 {
+		return nil
+	}
+	return append([]Client(nil), m.CapTable...)
+}
+
 // NumSegments returns the number of segments in the message.
 func (m *Message) NumSegments() int64 {
 	return int64(m.Arena.NumSegments())
 }
 
+// AllocStats describes how much memory a message uses.
+type AllocStats struct {
+	// Segments is the number of segments in the message's arena.
+	Segments int64
+
+	// Capacity and Used are the total capacity and length, in bytes,
+	// of the segments loaded so far.  Capacity minus Used is memory
+	// that the arena has reserved but no object occupies yet.
+	Capacity uint64
+	Used     uint64
+
+	// Allocs is the number of objects allocated in the message since
+	// it was created or last Reset, and AllocBytes is their total
+	// size in bytes, padded to whole words.
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// AllocStats returns statistics about the message's memory use.  It
+// only reads counters that alloc maintains and the lengths of loaded
+// segments, so it is cheap to call.
+func (m *Message) AllocStats() AllocStats {
+	st := AllocStats{
+		Allocs:     m.allocs,
+		AllocBytes: m.allocBytes,
+	}
+	if m.Arena != nil {
+		st.Segments = m.Arena.NumSegments()
+	}
+	for _, seg := range m.segs {
+		st.Capacity += uint64(cap(seg.data))
+		st.Used += uint64(len(seg.data))
+	}
+	return st
+}
+
 // Segment returns the segment with the given ID.
 func (m *Message) Segment(id SegmentID) (*Segment, error) {
 	if isInt32Bit() && id > maxInt32 {
@@ -103,6 +259,86 @@ func (m *Message) Segment(id SegmentID) (*Segment, error) {
 	return m.setSegment(id, data), nil
 }
 
+// Reset discards the message's contents and replaces its arena with
+// arena, so that m can be reused to read or build another message.
+// The capability table is emptied, but OnAlloc and OnAllocFail are
+// kept.  If arena holds no data, Reset reserves space for the root
+// pointer as NewMessage does, so SetRoot and NewRootStruct work as they
+// would on a new message.  Objects obtained from m before the call to Reset
+// must not be used afterward: m's segments are reused for the new
+// arena's data.
+func (m *Message) Reset(arena Arena) {
+	m.Arena = arena
+	m.CapTable = nil
+	m.traversed = 0
+	m.allocs, m.allocBytes = 0, 0
+	m.pads = nil
+	n := arena.NumSegments()
+	for id, seg := range m.segs {
+		if int64(id) >= n {
+			delete(m.segs, id)
+			continue
+		}
+		data, err := arena.Data(id)
+		if err != nil {
+			// Load lazily and report the error from Segment.
+			delete(m.segs, id)
+			continue
+		}
+		seg.data = data
+		seg.readOnly = m.readOnly()
+	}
+	switch n {
+	case 0:
+		if first, err := m.allocSegment(defaultBufferSize); err == nil {
+			alloc(first, wordSize) // allocate root
+		}
+	case 1:
+		first, err := m.Segment(0)
+		if err == nil && len(first.data) == 0 && hasCapacity(first.data, wordSize) {
+			alloc(first, wordSize) // allocate root
+		}
+	}
+}
+
+// Release tells m's arena that m is done with its segments, so that an
+// arena that reuses buffers, like one from NewPooledArena, can reclaim
+// them.  The arena is told through its Release method, if it has one.
+// Afterward m is empty: objects obtained from m must not be used, and
+// m must be given an arena with Reset before it is used again.
+func (m *Message) Release() {
+	if r, ok := m.Arena.(interface {
+		Release()
+	}); ok {
+		r.Release()
+	}
+	m.Arena = nil
+	m.CapTable = nil
+	for _, seg := range m.segs {
+		seg.data = nil
+	}
+}
+
+// canRead charges words against m's traversal limit, reporting false
+// if the limit is spent.
+func (m *Message) canRead(words uint64) bool {
+	if m == nil || m.TraversalLimit == 0 {
+		return true
+	}
+	if words > m.TraversalLimit-m.traversed {
+		m.traversed = m.TraversalLimit
+		return false
+	}
+	m.traversed += words
+	return true
+}
+
+// readOnly reports whether m's arena rejects writes to its segments.
+func (m *Message) readOnly() bool {
+	_, ok := m.Arena.(writeProtectedArena)
+	return ok
+}
+
 func (m *Message) segment(id SegmentID) *Segment {
 	if m.segs == nil {
 		return nil
@@ -115,12 +351,14 @@ func (m *Message) setSegment(id SegmentID, data []byte) *Segment {
 		m.segs = make(map[SegmentID]*Segment)
 	} else if seg := m.segs[id]; seg != nil {
 		seg.data = data
+		seg.readOnly = m.readOnly()
 		return seg
 	}
 	seg := &Segment{
-		id:   id,
-		msg:  m,
-		data: data,
+		id:       id,
+		msg:      m,
+		data:     data,
+		readOnly: m.readOnly(),
 	}
 	m.segs[id] = seg
 	return seg
@@ -130,6 +368,12 @@ func (m *Message) setSegment(id SegmentID, data []byte) *Segment {
 // cap(seg.Data) - len(seg.Data) >= sz.
 func (m *Message) allocSegment(sz Size) (*Segment, error) {
 	id, data, err := m.Arena.Allocate(sz, m.segs)
+	if err != nil && m.OnAllocFail != nil {
+		if herr := m.OnAllocFail(sz); herr != nil {
+			return nil, herr
+		}
+		id, data, err = m.Arena.Allocate(sz, m.segs)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -139,6 +383,51 @@ func (m *Message) allocSegment(sz Size) (*Segment, error) {
 	return m.setSegment(id, data), nil
 }
 
+// A landingKey identifies the object that a landing pad points to by
+// the segment and address of its pointer target.
+type landingKey struct {
+	seg  SegmentID
+	addr Address
+}
+
+// A landingPad is the location of a far pointer's landing pad.  double
+// is set for the two-word pad of a double-far pointer.
+type landingPad struct {
+	seg    SegmentID
+	addr   Address
+	double bool
+}
+
+// findLandingPad returns a landing pad written for an earlier far pointer
+// to p.  The pad is only returned if it still holds what a new pad for
+// p would, so a far pointer to it refers to p.
+func (m *Message) findLandingPad(p Pointer) (landingPad, bool) {
+	srcSeg, srcAddr := p.Segment(), pointerAddress(p)
+	pad, ok := m.pads[landingKey{srcSeg.id, srcAddr}]
+	if !ok {
+		return landingPad{}, false
+	}
+	seg := m.segment(pad.seg)
+	if pad.double {
+		ok = seg != nil && seg.regionInBounds(pad.addr, wordSize*2) &&
+			seg.readRawPointer(pad.addr) == rawFarPointer(srcSeg.id, srcAddr) &&
+			seg.readRawPointer(pad.addr.addSize(wordSize)) == p.value(srcAddr-Address(wordSize))
+	} else {
+		ok = seg != nil && seg.regionInBounds(pad.addr, wordSize) &&
+			seg.readRawPointer(pad.addr) == p.value(pad.addr)
+	}
+	return pad, ok
+}
+
+// addLandingPad records pad as the landing pad for far pointers to the
+// object at addr in segment id.
+func (m *Message) addLandingPad(id SegmentID, addr Address, pad landingPad) {
+	if m.pads == nil {
+		m.pads = make(map[landingKey]landingPad)
+	}
+	m.pads[landingKey{id, addr}] = pad
+}
+
 // alloc allocates sz zero-filled bytes.  It prefers using s, but may
 // use a different segment in the same message if there's not sufficient
 // capacity.
@@ -147,6 +436,11 @@ func alloc(s *Segment, sz Size) (*Segment, Address, error) {
 	if sz > Size(math.MaxUint32)-wordSize {
 		return nil, 0, errOverlarge
 	}
+	if s.msg != nil && s.msg.OnAlloc != nil {
+		if err := s.msg.OnAlloc(sz); err != nil {
+			return nil, 0, err
+		}
+	}
 
 	if !hasCapacity(s.data, sz) {
 		var err error
@@ -156,6 +450,10 @@ func alloc(s *Segment, sz Size) (*Segment, Address, error) {
 		}
 	}
 
+	if s.msg != nil {
+		s.msg.allocs++
+		s.msg.allocBytes += uint64(sz)
+	}
 	addr := Address(len(s.data))
 	end := addr.addSize(sz)
 	s.data = s.data[:end]
@@ -189,12 +487,32 @@ const (
 	minSingleSegmentGrowth = 4096
 )
 
+// NewDefaultArena returns the arena for messages created by
+// NewDefaultMessage, which the library uses whenever it creates a
+// message on the caller's behalf, such as in Call.PlaceParams or for
+// server results.  It defaults to SingleSegment(nil).  Tests can
+// replace it to route those allocations, and any generated constructors
+// called on the resulting segments, through a pooled or instrumented
+// arena.  It must not be replaced while messages are being created.
+var NewDefaultArena = func() Arena {
+	return SingleSegment(nil)
+}
+
+// NewDefaultMessage calls NewMessage with an arena from NewDefaultArena.
+func NewDefaultMessage() (msg *Message, first *Segment, err error) {
+	return NewMessage(NewDefaultArena())
+}
+
 type singleSegmentArena []byte
 
 // SingleSegment returns a new arena with an expanding single-segment
 // buffer.  b can be used to populate the segment for reading or to
-// reserve memory of a specific size.  A SingleSegment arena does not
-// return errors unless you attempt to access another segment.
+// reserve memory of a specific size.  A message built on a
+// SingleSegment arena always has exactly one segment: the arena grows
+// the segment by reallocating it, so the message never needs far
+// pointers.  A SingleSegment arena does not return errors unless you
+// attempt to access another segment or grow the segment past the
+// largest size a segment can address.
 func SingleSegment(b []byte) Arena {
 	if cap(b) == 0 {
 		b = make([]byte, 0, defaultBufferSize)
@@ -224,12 +542,20 @@ func (ssa *singleSegmentArena) Allocate(sz Size, segs map[SegmentID]*Segment) (S
 		return 0, data, nil
 	}
 	// TODO(light): ensure len(data)+sz is word-aligned
+	const maxSegSize = uint64(maxSegmentWords) * uint64(wordSize)
+	if uint64(len(data))+(uint64(sz)+uint64(wordSize-1))&^uint64(wordSize-1) > maxSegSize {
+		return 0, nil, errSegmentFull
+	}
 	if sz < minSingleSegmentGrowth {
 		sz = minSingleSegmentGrowth
 	} else {
 		sz = sz.padToWord()
 	}
-	buf := make([]byte, len(data), cap(data)+int(sz))
+	newCap := uint64(cap(data)) + uint64(sz)
+	if newCap > maxSegSize {
+		newCap = maxSegSize
+	}
+	buf := make([]byte, len(data), newCap)
 	copy(buf, data)
 	*ssa = buf
 	return 0, *ssa, nil
@@ -246,6 +572,59 @@ func MultiSegment(b [][]byte) Arena {
 	return msa
 }
 
+// MultiSegmentOptions controls how an arena from NewMultiSegmentArena
+// sizes the segments it adds when none of its segments has room for an
+// allocation.  Small segments waste less memory on small messages, but
+// split large messages into many segments joined by far pointers,
+// which take extra space and make reads slower.  Growing each segment
+// keeps the number of segments logarithmic in the message size, at the
+// cost of leaving up to most of the last segment unused.
+type MultiSegmentOptions struct {
+	// MinSegmentSize is the smallest segment the arena adds, in bytes.
+	// It is rounded up to a whole number of words.  If zero, the
+	// default of 4096 bytes is used.
+	MinSegmentSize Size
+
+	// GrowthFactor is how much larger each new segment is than the
+	// last one.  Values of one or less, including zero, keep every
+	// segment at MinSegmentSize.
+	GrowthFactor float64
+}
+
+// nextSize returns the smallest size for a segment that is added to segs.
+func (opts MultiSegmentOptions) nextSize(segs [][]byte) Size {
+	next := float64(defaultBufferSize)
+	if opts.MinSegmentSize != 0 {
+		next = float64(opts.MinSegmentSize)
+	}
+	if opts.GrowthFactor > 1 && len(segs) > 0 {
+		if g := float64(cap(segs[len(segs)-1])) * opts.GrowthFactor; g > next {
+			next = g
+		}
+	}
+	if max := float64(wordSize.times(int32(maxSegmentWords))); next > max {
+		return Size(max)
+	}
+	return Size(next).padToWord()
+}
+
+type optionsArena struct {
+	multiSegmentArena
+	opts MultiSegmentOptions
+}
+
+// NewMultiSegmentArena returns a new, empty arena that allocates new
+// segments when they are full, like MultiSegment, sizing them as opts
+// says.  NewMultiSegmentArena(MultiSegmentOptions{}) behaves just like
+// MultiSegment(nil).
+func NewMultiSegmentArena(opts MultiSegmentOptions) Arena {
+	return &optionsArena{opts: opts}
+}
+
+func (oa *optionsArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	return oa.multiSegmentArena.allocate(sz, segs, oa.opts)
+}
+
 // demuxArena slices b into a multi-segment arena.
 func demuxArena(sizes []Size, data []byte) Arena {
 	segs := make([][]byte, len(sizes))
@@ -267,6 +646,11 @@ func (msa *multiSegmentArena) Data(id SegmentID) ([]byte, error) {
 }
 
 func (msa *multiSegmentArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	return msa.allocate(sz, segs, MultiSegmentOptions{})
+}
+
+// allocate implements Allocate, sizing any new segment as opts says.
+func (msa *multiSegmentArena) allocate(sz Size, segs map[SegmentID]*Segment, opts MultiSegmentOptions) (SegmentID, []byte, error) {
 	for i, data := range *msa {
 		id := SegmentID(i)
 		if s := segs[id]; s != nil {
@@ -276,8 +660,9 @@ func (msa *multiSegmentArena) Allocate(sz Size, segs map[SegmentID]*Segment) (Se
 			return id, data, nil
 		}
 	}
-	if sz < defaultBufferSize {
-		sz = defaultBufferSize
+	next := opts.nextSize(*msa)
+	if sz < next {
+		sz = next
 	} else {
 		// TODO(light): check >maxInt
 		sz = sz.padToWord()
@@ -288,10 +673,128 @@ func (msa *multiSegmentArena) Allocate(sz Size, segs map[SegmentID]*Segment) (Se
 	return id, buf, nil
 }
 
+type pooledArena struct {
+	pool *sync.Pool
+	segs [][]byte
+}
+
+// NewPooledArena returns a new arena that allocates segments like
+// MultiSegment, but takes their buffers from pool and puts them back
+// when the message is released with Message.Release.  The pool holds
+// byte slices: a value of another type is ignored, and a buffer too
+// small for the segment is put back for a later allocation.  The arena
+// can be reused for another message once it has been released.
+func NewPooledArena(pool *sync.Pool) Arena {
+	return &pooledArena{pool: pool}
+}
+
+func (pa *pooledArena) NumSegments() int64 {
+	return int64(len(pa.segs))
+}
+
+func (pa *pooledArena) Data(id SegmentID) ([]byte, error) {
+	if int64(id) >= int64(len(pa.segs)) {
+		return nil, errSegmentOutOfBounds
+	}
+	return pa.segs[id], nil
+}
+
+func (pa *pooledArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	for i, data := range pa.segs {
+		id := SegmentID(i)
+		if s := segs[id]; s != nil {
+			data = s.data
+		}
+		if hasCapacity(data, sz) {
+			return id, data, nil
+		}
+	}
+	if sz < defaultBufferSize {
+		sz = defaultBufferSize
+	} else {
+		sz = sz.padToWord()
+	}
+	buf, ok := pa.pool.Get().([]byte)
+	if cap(buf) < int(sz) {
+		if ok {
+			pa.pool.Put(buf)
+		}
+		buf = make([]byte, 0, int(sz))
+	}
+	buf = buf[:0]
+	id := SegmentID(len(pa.segs))
+	pa.segs = append(pa.segs, buf)
+	return id, buf, nil
+}
+
+// Release puts the arena's buffers back in its pool.
+func (pa *pooledArena) Release() {
+	for i, buf := range pa.segs {
+		pa.pool.Put(buf[:0])
+		pa.segs[i] = nil
+	}
+	pa.segs = pa.segs[:0]
+}
+
+// A writeProtectedArena is an arena whose segments must not be written to.
+// Segments loaded from it are marked read-only, so setters fail with
+// errReadOnly instead of modifying the arena's data.
+type writeProtectedArena interface {
+	Arena
+	readOnly()
+}
+
+// fixedArena is a read-only arena of segments that cannot grow.
+type fixedArena [][]byte
+
+func (fixedArena) readOnly() {}
+
+func (fa fixedArena) NumSegments() int64 {
+	return int64(len(fa))
+}
+
+func (fa fixedArena) Data(id SegmentID) ([]byte, error) {
+	if int64(id) >= int64(len(fa)) {
+		return nil, errSegmentOutOfBounds
+	}
+	return fa[id], nil
+}
+
+func (fa fixedArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	return 0, nil, errReadOnly
+}
+
+// DefaultMaxMessageSize is the largest message, in bytes, that a
+// Decoder reads when its MaxMessageSize is zero.
+const DefaultMaxMessageSize = 64 << 20
+
+// ErrMessageTooLarge is returned by a Decoder when a stream header
+// announces a message larger than the decoder's MaxMessageSize.
+var ErrMessageTooLarge = errors.New("capnp: message exceeds maximum size")
+
 // A Decoder represents a framer that deserializes a particular Cap'n
 // Proto input stream.
 type Decoder struct {
 	r io.Reader
+
+	// MaxMessageSize is the largest message, in bytes and including the
+	// stream header, that the decoder will read.  If zero,
+	// DefaultMaxMessageSize is used.  A message whose header announces
+	// a larger size is rejected with ErrMessageTooLarge before any
+	// memory is allocated for its segments.
+	MaxMessageSize uint64
+
+	// Buffers reused across calls.  buf and arena are only used by
+	// DecodeInto, since Decode returns messages that own their data.
+	hdrbuf []byte
+	sizes  []Size
+	buf    []byte
+	arena  multiSegmentArena
+
+	// peeked is set when More has read the segment count of the next
+	// message into hdrbuf, or failed to with peekErr.
+	peeked  bool
+	peekErr error
 }
 
 // NewDecoder creates a new Cap'n Proto framer that reads from r.
@@ -305,36 +808,302 @@ func NewPackedDecoder(r io.Reader) *Decoder {
 	return NewDecoder(packed.NewReader(r))
 }
 
-// Decode reads a message from the decoder stream.
+// NewAutoDecoder creates a new Cap'n Proto framer that reads from r,
+// which may be either a packed or an unpacked stream.  It reads the
+// first two bytes of r to decide: an unpacked stream starts with the
+// segment count, whose second byte is zero for any message with fewer
+// than 257 segments, while a packed stream starts with a tag byte
+// followed by a non-zero byte of the stream header.  The peeked bytes
+// are replayed to the chosen decoder.  The whole stream must use one
+// encoding.
+func NewAutoDecoder(r io.Reader) (*Decoder, error) {
+	var peek [2]byte
+	n, err := io.ReadFull(r, peek[:])
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// Let Decode report the short stream.
+		return NewDecoder(bytes.NewReader(peek[:n])), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r = io.MultiReader(bytes.NewReader(peek[:]), r)
+	if peek[1] != 0 {
+		return NewPackedDecoder(r), nil
+	}
+	return NewDecoder(r), nil
+}
+
+// Decode reads a message from the decoder stream.  It returns io.EOF
+// if the stream ends before a message starts and
+// io.ErrUnexpectedEOF if the stream ends partway through a message.
 func (d *Decoder) Decode() (*Message, error) {
-	var maxSegBuf [msgHeaderSize]byte
-	if _, err := io.ReadFull(d.r, maxSegBuf[:]); err != nil {
+	sizes, err := d.readHeader()
+	if err != nil {
 		return nil, err
 	}
-	maxSeg := binary.LittleEndian.Uint32(maxSegBuf[:])
+	buf, err := d.readSegments(nil, sizes)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Arena: demuxArena(sizes, buf)}, nil
+}
+
+// DecodeInto reads a message from the decoder stream into m, replacing
+// m's contents as if by m.Reset.  The decoder reuses the same memory
+// for every message it decodes with DecodeInto, so the message's data
+// is only valid until the next call to DecodeInto.  The errors
+// returned are the same as for Decode.
+func (d *Decoder) DecodeInto(m *Message) error {
+	sizes, err := d.readHeader()
+	if err != nil {
+		return err
+	}
+	buf, err := d.readSegments(d.buf, sizes)
+	if err != nil {
+		return err
+	}
+	d.buf = buf
+	d.arena = d.arena[:0]
+	for _, sz := range sizes {
+		d.arena, buf = append(d.arena, buf[:sz:sz]), buf[sz:]
+	}
+	m.Reset(&d.arena)
+	return nil
+}
+
+// DecodeAll reads messages from the decoder stream until the end of
+// the stream, calling f with each message.  DecodeAll returns nil if
+// the stream ends cleanly between messages, or the first error
+// returned by f.
+//
+// To avoid allocating for every message, DecodeAll reuses the same
+// Message and its backing memory for each call to f using DecodeInto.
+// f must not retain the message, or any object read from it, after it
+// returns.
+func (d *Decoder) DecodeAll(f func(*Message) error) error {
+	msg := new(Message)
+	for {
+		err := d.DecodeInto(msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// More reports whether another message starts in the decoder stream.
+// It returns false once the stream ends cleanly between messages, at
+// which point Decode would return io.EOF.  If reading the stream fails
+// for any other reason, More returns true and the next call to Decode
+// or DecodeInto returns the error.  More may block until the first
+// bytes of the next message are available.
+func (d *Decoder) More() bool {
+	if !d.peeked {
+		d.peekErr = d.readSegmentCount()
+		d.peeked = true
+	}
+	return d.peekErr != io.EOF
+}
+
+// readSegmentCount reads the first word of a stream header, the
+// segment count, into d.hdrbuf.
+func (d *Decoder) readSegmentCount() error {
+	if cap(d.hdrbuf) < streamHeaderSize(0) {
+		d.hdrbuf = make([]byte, streamHeaderSize(0))
+	}
+	_, err := io.ReadFull(d.r, d.hdrbuf[:msgHeaderSize])
+	return err
+}
+
+// readHeader reads a stream header from the decoder stream, returning
+// the sizes of the message's segments.  The returned slice is only
+// valid until the next call to readHeader.
+func (d *Decoder) readHeader() ([]Size, error) {
+	var err error
+	if d.peeked {
+		err = d.peekErr
+		d.peeked, d.peekErr = false, nil
+	} else {
+		err = d.readSegmentCount()
+	}
+	if err != nil {
+		return nil, err
+	}
+	maxSegBuf := d.hdrbuf[:msgHeaderSize]
+	maxSeg := binary.LittleEndian.Uint32(maxSegBuf)
+	if uint64(maxSeg) >= d.maxSize()/segHeaderSize {
+		return nil, ErrMessageTooLarge
+	}
 	hdrSize := streamHeaderSize(maxSeg)
-	hdr := make([]byte, hdrSize)
-	copy(hdr, maxSegBuf[:])
+	if uint64(hdrSize) > d.maxSize() {
+		return nil, ErrMessageTooLarge
+	}
+	if cap(d.hdrbuf) < hdrSize {
+		d.hdrbuf = append(make([]byte, 0, hdrSize), maxSegBuf...)
+	}
+	hdr := d.hdrbuf[:hdrSize]
 	if _, err := io.ReadFull(d.r, hdr[msgHeaderSize:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	sizes, _, err := unmarshalStreamHeader(hdr)
+	sizes, _, err := unmarshalStreamHeaderInto(d.sizes[:0], hdr)
 	if err != nil {
 		return nil, err
 	}
-	total := totalSize(sizes)
-	// TODO(light): size check
-	buf := make([]byte, int(total))
+	if uint64(hdrSize)+totalSize(sizes) > d.maxSize() {
+		return nil, ErrMessageTooLarge
+	}
+	d.sizes = sizes
+	return sizes, nil
+}
+
+// readSegments reads the segment data described by sizes into buf,
+// growing it as needed, and returns the filled buffer.
+func (d *Decoder) readSegments(buf []byte, sizes []Size) ([]byte, error) {
+	total := int(totalSize(sizes)) // readHeader checked that this fits
+	if cap(buf) < total {
+		buf = make([]byte, total)
+	}
+	buf = buf[:total]
 	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
 		return nil, err
 	}
-	return &Message{Arena: demuxArena(sizes, buf)}, nil
+	return buf, nil
+}
+
+// maxSize returns the largest message size the decoder will read.
+func (d *Decoder) maxSize() uint64 {
+	const maxInt = uint64(^uint(0) >> 1)
+	max := d.MaxMessageSize
+	if max == 0 {
+		max = DefaultMaxMessageSize
+	}
+	if max > maxInt {
+		return maxInt
+	}
+	return max
 }
 
 // Unmarshal reads an unpacked serialized stream into a message.  No
 // copying is performed, so the objects in the returned message read
-// directly from data.
+// directly from data.  Any bytes after the first message are ignored.
 func Unmarshal(data []byte) (*Message, error) {
+	msg, _, err := UnmarshalN(data)
+	return msg, err
+}
+
+// UnmarshalExact is like Unmarshal, but returns an error if data has
+// any bytes after the message.  Use it when data is known to hold
+// exactly one message, so that a header that understates the segment
+// sizes is caught instead of silently truncating the message.
+func UnmarshalExact(data []byte) (*Message, error) {
+	msg, n, err := UnmarshalN(data)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, newFrameSizeError(data)
+		}
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, newFrameSizeError(data)
+	}
+	return msg, nil
+}
+
+// newFrameSizeError returns an error describing how the size given by
+// data's stream header differs from len(data).
+func newFrameSizeError(data []byte) error {
+	sizes, rest, err := unmarshalStreamHeader(data)
+	if err != nil {
+		return err
+	}
+	want := uint64(len(data)-len(rest)) + totalSize(sizes)
+	return errors.New("capnp: stream header declares " + strconv.FormatUint(want, 10) + " bytes, but have " + strconv.Itoa(len(data)))
+}
+
+// UnmarshalN is like Unmarshal, but also returns the number of bytes
+// of data that the message occupies, so that the caller can advance
+// past it to whatever follows.
+func UnmarshalN(data []byte) (*Message, int, error) {
+	if len(data) == 0 {
+		return nil, 0, io.EOF
+	}
+	sizes, rest, err := unmarshalStreamHeader(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	tot := totalSize(sizes)
+	if tot > uint64(len(rest)) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	n := len(data) - len(rest) + int(tot)
+	return &Message{Arena: demuxArena(sizes, rest)}, n, nil
+}
+
+// UnmarshalSegments builds a message from a stream header and the
+// segments it describes, received as separate buffers, for example
+// with a scatter read.  The segments are used in place instead of being
+// concatenated.  header must hold exactly the framing header, including
+// its padding, and segs must match the number and sizes of the segments
+// that the header declares.
+func UnmarshalSegments(header []byte, segs [][]byte) (*Message, error) {
+	sizes, rest, err := unmarshalStreamHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("capnp: stream header is " + strconv.Itoa(len(header)-len(rest)) + " bytes, but have " + strconv.Itoa(len(header)))
+	}
+	if len(segs) != len(sizes) {
+		return nil, errors.New("capnp: stream header declares " + strconv.Itoa(len(sizes)) + " segments, but have " + strconv.Itoa(len(segs)))
+	}
+	arena := make([][]byte, len(segs))
+	for i, b := range segs {
+		if uint64(len(b)) != uint64(sizes[i]) {
+			return nil, errors.New("capnp: stream header declares segment " + strconv.Itoa(i) + " as " + strconv.FormatUint(uint64(sizes[i]), 10) + " bytes, but have " + strconv.Itoa(len(b)))
+		}
+		// Limit capacity so that allocating doesn't write past the
+		// segment into the caller's buffer.
+		arena[i] = b[:len(b):len(b)]
+	}
+	return &Message{Arena: MultiSegment(arena)}, nil
+}
+
+// UnpackOne reads a single packed message from the start of b and
+// returns it along with the number of bytes of b that it occupied.
+// Bytes after the message are not read.  UnpackOne returns
+// io.ErrUnexpectedEOF if b ends partway through a message.
+func UnpackOne(b []byte) (msg *Message, consumed int, err error) {
+	r := bytes.NewReader(b)
+	msg, err = NewPackedDecoder(r).Decode()
+	if err == io.EOF && len(b) > 0 {
+		// A partial packed word can end before any unpacked bytes.
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return msg, len(b) - r.Len(), nil
+}
+
+// UnmarshalInPlace reads an unpacked serialized stream into a message
+// whose segments alias data without copying, such as a memory-mapped
+// file.  The segment sizes in the stream header are checked against
+// len(data).  The returned message is read-only: operations that would
+// allocate or write, like creating a new object or setting a pointer,
+// return an error and leave data unchanged, and setters that do not
+// return an error panic.
+func UnmarshalInPlace(data []byte) (*Message, error) {
 	if len(data) == 0 {
 		return nil, io.EOF
 	}
@@ -345,7 +1114,98 @@ func Unmarshal(data []byte) (*Message, error) {
 	if tot := totalSize(sizes); tot > uint64(len(data)) {
 		return nil, io.ErrUnexpectedEOF
 	}
-	return &Message{Arena: demuxArena(sizes, data)}, nil
+	segs := make(fixedArena, len(sizes))
+	for i, sz := range sizes {
+		segs[i], data = data[:sz:sz], data[sz:]
+	}
+	return &Message{Arena: segs}, nil
+}
+
+// UnmarshalReaderAt reads the stream header of the unpacked serialized
+// stream in the first size bytes of r and returns a message whose
+// segments are read from r as they are used, so a large file can be
+// decoded without reading all of it.  The segment sizes in the header
+// are checked against size, and an error reading a segment is returned
+// when the segment is first accessed.  Like UnmarshalInPlace, the
+// returned message is read-only.  Each segment is copied
+// into memory once it is read; to avoid copying, map the file and pass
+// its data to UnmarshalInPlace instead.
+func UnmarshalReaderAt(r io.ReaderAt, size int64) (*Message, error) {
+	if size == 0 {
+		return nil, io.EOF
+	}
+	if size < int64(streamHeaderSize(0)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var first [msgHeaderSize]byte
+	if err := readFullAt(r, first[:], 0); err != nil {
+		return nil, err
+	}
+	maxSeg := binary.LittleEndian.Uint32(first[:])
+	if uint64(maxSeg) >= uint64(size)/segHeaderSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	hdrSize := streamHeaderSize(maxSeg)
+	if int64(hdrSize) > size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	hdr := make([]byte, hdrSize)
+	if err := readFullAt(r, hdr, 0); err != nil {
+		return nil, err
+	}
+	sizes, _, err := unmarshalStreamHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if tot := totalSize(sizes); tot > uint64(size)-uint64(len(hdr)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &Message{Arena: &readerAtArena{r: r, base: int64(len(hdr)), sizes: sizes}}, nil
+}
+
+// readFullAt reads len(b) bytes from r at off.
+func readFullAt(r io.ReaderAt, b []byte, off int64) error {
+	n, err := r.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readerAtArena is a read-only arena that cannot grow, whose segments
+// are read from r, starting at base.
+type readerAtArena struct {
+	r     io.ReaderAt
+	base  int64
+	sizes []Size
+}
+
+func (ra *readerAtArena) readOnly() {}
+
+func (ra *readerAtArena) NumSegments() int64 {
+	return int64(len(ra.sizes))
+}
+
+func (ra *readerAtArena) Data(id SegmentID) ([]byte, error) {
+	if int64(id) >= int64(len(ra.sizes)) {
+		return nil, errSegmentOutOfBounds
+	}
+	off := ra.base
+	for _, sz := range ra.sizes[:id] {
+		off += int64(sz)
+	}
+	data := make([]byte, ra.sizes[id])
+	if err := readFullAt(ra.r, data, off); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (ra *readerAtArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	return 0, nil, errReadOnly
 }
 
 // MustUnmarshalRoot reads an unpacked serialized stream and returns its
@@ -378,7 +1238,9 @@ func NewEncoder(w io.Writer) *Encoder {
 }
 
 // NewPackedEncoder creates a new Cap'n Proto framer that writes to a
-// packed stream w.
+// packed stream w.  The Encoder packs each message as it writes it, a
+// few kilobytes at a time, so the packed form of a message is never
+// held in memory all at once.
 func NewPackedEncoder(w io.Writer) *Encoder {
 	return &Encoder{w: w, packed: true}
 }
@@ -386,46 +1248,67 @@ func NewPackedEncoder(w io.Writer) *Encoder {
 // Encode writes a message to the encoder stream.
 func (e *Encoder) Encode(m *Message) error {
 	// TODO(light): Lazily load from arena, don't necessarily need to fit in memory.
-	nsegs := m.NumSegments()
-	if nsegs == 0 {
-		return errMessageEmpty
-	}
-	sizes, err := m.segmentSizes()
+	plan, err := m.SegmentsForOutput()
 	if err != nil {
 		return err
 	}
-	maxSeg := uint32(nsegs - 1)
-	hdrSize := streamHeaderSize(maxSeg)
-	if cap(e.hdrbuf) < hdrSize {
-		e.hdrbuf = make([]byte, hdrSize)
-	} else {
-		e.hdrbuf = e.hdrbuf[:hdrSize]
+	return e.EncodePlan(m, plan)
+}
+
+// EncodeAll writes each message in msgs to the encoder stream in
+// order, stopping at the first error.  Since every message is framed
+// with its own stream header, a stream of messages can be read back
+// one at a time by a Decoder, which returns io.EOF after the last
+// message.  This makes an append-only file of messages a valid stream.
+func (e *Encoder) EncodeAll(msgs []*Message) error {
+	for _, m := range msgs {
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodePlan writes a message to the encoder stream using a plan
+// previously returned by m.SegmentsForOutput.  It returns an error
+// without writing anything if m's segments no longer match the plan.
+func (e *Encoder) EncodePlan(m *Message, plan OutputPlan) error {
+	if err := m.checkPlan(plan); err != nil {
+		return err
 	}
-	marshalStreamHeader(e.hdrbuf, sizes)
+	e.hdrbuf = plan.AppendHeader(e.hdrbuf[:0])
 	if err := e.write(e.hdrbuf); err != nil {
 		return err
 	}
-	for i := int64(0); i < nsegs; i++ {
-		s, err := m.Segment(SegmentID(i))
-		if err != nil {
-			return err
+	return m.eachSegment(plan, e.write)
+}
+
+// packChunkSize is the most unpacked data, in bytes, that a packed
+// Encoder compresses per write, so that its buffer stays small no
+// matter how large the segments are.  Must be a multiple of wordSize.
+const packChunkSize = 8192
+
+func (e *Encoder) write(b []byte) error {
+	if !e.packed {
+		_, err := e.w.Write(b)
+		return err
+	}
+	// Each chunk packs to a valid stream on its own, so runs that would
+	// cross a chunk boundary are just split in two.
+	for len(b) > 0 {
+		n := len(b)
+		if n > packChunkSize {
+			n = packChunkSize
 		}
-		if err := e.write(s.data); err != nil {
+		e.packbuf = packed.Pack(e.packbuf[:0], b[:n])
+		if _, err := e.w.Write(e.packbuf); err != nil {
 			return err
 		}
+		b = b[n:]
 	}
 	return nil
 }
 
-func (e *Encoder) write(b []byte) error {
-	if e.packed {
-		e.packbuf = packed.Pack(e.packbuf[:0], b)
-		b = e.packbuf
-	}
-	_, err := e.w.Write(b)
-	return err
-}
-
 func (m *Message) segmentSizes() ([]Size, error) {
 	nsegs := m.NumSegments()
 	sizes := make([]Size, nsegs)
@@ -443,35 +1326,293 @@ func (m *Message) segmentSizes() ([]Size, error) {
 	return sizes, nil
 }
 
-// Marshal concatenates the segments in the message into a single byte
-// slice including framing.
-func (m *Message) Marshal() ([]byte, error) {
-	// Compute buffer size.
+// An OutputPlan describes the framing of a message as it would be
+// written by WriteSegments, Marshal, or an Encoder.
+type OutputPlan struct {
+	// HeaderSize is the size of the stream framing header in bytes,
+	// including padding.
+	HeaderSize uint64
+
+	// SegmentSizes holds the size of each segment in bytes.
+	SegmentSizes []Size
+
+	// TotalSize is the number of bytes in the framed message, including
+	// the header.
+	TotalSize uint64
+}
+
+// AppendHeader appends the stream framing header described by the plan
+// to b and returns the resulting slice.
+func (plan OutputPlan) AppendHeader(b []byte) []byte {
+	n := len(b)
+	for i := uint64(0); i < plan.HeaderSize; i++ {
+		b = append(b, 0)
+	}
+	marshalStreamHeader(b[n:], plan.SegmentSizes)
+	return b
+}
+
+// SegmentsForOutput computes the framing of m without writing any data.
+// The plan is only accurate as long as m's segments do not grow.
+func (m *Message) SegmentsForOutput() (OutputPlan, error) {
 	// TODO(light): error out if too many segments
 	nsegs := m.NumSegments()
 	if nsegs == 0 {
-		return nil, errMessageEmpty
+		return OutputPlan{}, errMessageEmpty
 	}
-	maxSeg := uint32(nsegs - 1)
-	hdrSize := streamHeaderSize(maxSeg)
 	sizes, err := m.segmentSizes()
 	if err != nil {
-		return nil, err
+		return OutputPlan{}, err
+	}
+	hdrSize := uint64(streamHeaderSize(uint32(nsegs - 1)))
+	return OutputPlan{
+		HeaderSize:   hdrSize,
+		SegmentSizes: sizes,
+		TotalSize:    hdrSize + totalSize(sizes),
+	}, nil
+}
+
+// TotalSize returns the number of bytes that Marshal would produce for
+// m: the stream framing header, including its padding, plus the data
+// in every segment.  It does not copy any data.
+func (m *Message) TotalSize() (uint64, error) {
+	plan, err := m.SegmentsForOutput()
+	if err != nil {
+		return 0, err
+	}
+	return plan.TotalSize, nil
+}
+
+// checkPlan returns an error if m's segments do not match plan.
+func (m *Message) checkPlan(plan OutputPlan) error {
+	if m.NumSegments() != int64(len(plan.SegmentSizes)) {
+		return errPlanMismatch
+	}
+	for i, sz := range plan.SegmentSizes {
+		s, err := m.Segment(SegmentID(i))
+		if err != nil {
+			return err
+		}
+		if int64(len(s.data)) != int64(sz) {
+			return errPlanMismatch
+		}
+	}
+	return nil
+}
+
+// eachSegment calls f with the data of each segment in plan.
+func (m *Message) eachSegment(plan OutputPlan, f func([]byte) error) error {
+	for i := range plan.SegmentSizes {
+		s, err := m.Segment(SegmentID(i))
+		if err != nil {
+			return err
+		}
+		if err := f(s.data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteSegments writes the framed message to w, emitting exactly the
+// plan.TotalSize bytes described by plan.  plan must have been returned
+// by m.SegmentsForOutput; if m's segments have changed since then,
+// WriteSegments returns an error without writing anything.  To write
+// many messages to the same stream, an Encoder's EncodePlan method
+// avoids allocating a header for each message.
+func (m *Message) WriteSegments(w io.Writer, plan OutputPlan) error {
+	return NewEncoder(w).EncodePlan(m, plan)
+}
+
+// Marshal concatenates the segments in the message into a single byte
+// slice including framing.
+func (m *Message) Marshal() ([]byte, error) {
+	return m.MarshalTo(nil)
+}
+
+// MarshalTo appends the framed message to buf and returns the extended
+// slice, growing buf only if it lacks the capacity.  The bytes appended
+// are the same as those returned by Marshal.  On error, MarshalTo
+// returns buf unchanged.
+func (m *Message) MarshalTo(buf []byte) ([]byte, error) {
+	plan, err := m.SegmentsForOutput()
+	if err != nil {
+		return buf, err
 	}
 	// TODO(light): error out if too large
-	total := uint64(hdrSize) + totalSize(sizes)
+	b := buf
+	if need := uint64(len(b)) + plan.TotalSize; need > uint64(cap(b)) {
+		b = make([]byte, len(buf), need)
+		copy(b, buf)
+	}
+	b = plan.AppendHeader(b)
+	err = m.eachSegment(plan, func(data []byte) error {
+		b = append(b, data...)
+		return nil
+	})
+	if err != nil {
+		return buf, err
+	}
+	return b, nil
+}
 
-	// Fill in buffer.
-	buf := make([]byte, hdrSize, total)
-	marshalStreamHeader(buf, sizes)
-	for i := int64(0); i < nsegs; i++ {
+// MarshalInto writes the framed message to the start of buf if it fits.
+// It returns the number of bytes written and ok=true on success.  If
+// buf is too small, MarshalInto returns ok=false and leaves buf
+// untouched.  A non-nil error means the message could not be framed.
+func (m *Message) MarshalInto(buf []byte) (n int, ok bool, err error) {
+	plan, err := m.SegmentsForOutput()
+	if err != nil {
+		return 0, false, err
+	}
+	if plan.TotalSize > uint64(len(buf)) {
+		return 0, false, nil
+	}
+	b := plan.AppendHeader(buf[:0])
+	err = m.eachSegment(plan, func(data []byte) error {
+		b = append(b, data...)
+		return nil
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return len(b), true, nil
+}
+
+// CloneSegments returns a copy of m that keeps m's segment layout: each
+// segment is copied byte for byte, so every object stays in the same
+// segment at the same address and the clone marshals to exactly the
+// same bytes as m.  Copying m's root into a new message instead would
+// rearrange its objects.  The clone's capability table holds the same
+// clients as m's.
+func (m *Message) CloneSegments() (*Message, error) {
+	sizes, err := m.segmentSizes()
+	if err != nil {
+		return nil, err
+	}
+	if len(sizes) == 0 {
+		return nil, errMessageEmpty
+	}
+	buf := make([]byte, 0, totalSize(sizes))
+	for i := range sizes {
 		s, err := m.Segment(SegmentID(i))
 		if err != nil {
 			return nil, err
 		}
 		buf = append(buf, s.data...)
 	}
-	return buf, nil
+	clone := &Message{Arena: demuxArena(sizes, buf)}
+	if len(m.CapTable) > 0 {
+		clone.CapTable = append([]Client(nil), m.CapTable...)
+	}
+	return clone, nil
+}
+
+// Compact returns a copy of m whose root is a deep copy of m's root in
+// a single segment.  Unlike CloneSegments, the copy leaves behind far
+// pointers, orphaned objects, and any space between segments, so it is
+// usually smaller than m.  The copy's capability table holds the same
+// clients as m's, and capability IDs are kept.
+func (m *Message) Compact() (*Message, error) {
+	root, err := m.Root()
+	if err != nil {
+		return nil, err
+	}
+	sz, err := reachableSize(root)
+	if err != nil {
+		return nil, err
+	}
+	// sz is an upper bound: the copy may share repeated objects.
+	if sz > uint64(math.MaxUint32)-uint64(wordSize) {
+		return nil, errOverlarge
+	}
+	c, _, err := NewMessage(SingleSegment(make([]byte, 0, uint64(wordSize)+sz)))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetRoot(root); err != nil {
+		return nil, err
+	}
+	if len(m.CapTable) > 0 {
+		c.CapTable = append([]Client(nil), m.CapTable...)
+	}
+	return c, nil
+}
+
+// Clone returns an independent deep copy of m.  The copy's root is a
+// deep copy of m's root in a fresh, growable arena, so either message
+// can be modified without affecting the other.  The copy's capability
+// table holds the same clients as m's, and capability IDs are kept.
+// Use CloneSegments to keep m's segment layout, or Compact to pack the
+// copy into a single segment.
+func (m *Message) Clone() (*Message, error) {
+	root, err := m.Root()
+	if err != nil {
+		return nil, err
+	}
+	c, _, err := NewMessage(MultiSegment(nil))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.SetRoot(root); err != nil {
+		return nil, err
+	}
+	if len(m.CapTable) > 0 {
+		c.CapTable = append([]Client(nil), m.CapTable...)
+	}
+	return c, nil
+}
+
+// MarshalDeterministic is like Marshal, but writes the message in the
+// canonical form that Canonicalize produces, framed as a single
+// segment.  Messages with the same content marshal to the same bytes,
+// no matter how their objects were allocated, shared, or split into
+// segments, so the output can be hashed or compared.  It costs a
+// traversal and a copy of the message.  The root must be a struct or
+// null.
+func (m *Message) MarshalDeterministic() ([]byte, error) {
+	p, err := m.Root()
+	if err != nil {
+		return nil, err
+	}
+	var root Struct
+	if IsValid(p) {
+		var ok bool
+		if root, ok = p.underlying().(Struct); !ok {
+			return nil, errCanonicalRoot
+		}
+	}
+	data, err := Canonicalize(root)
+	if err != nil {
+		return nil, err
+	}
+	hdrSize := streamHeaderSize(0)
+	b := make([]byte, hdrSize+len(data))
+	marshalStreamHeader(b, []Size{Size(len(data))})
+	copy(b[hdrSize:], data)
+	return b, nil
+}
+
+// MarshalSubtree returns a framed single-segment message whose root is
+// a deep copy of p and the objects reachable from it.  The segment is
+// sized up front, so the copy does not need to grow the arena.
+func MarshalSubtree(p Pointer) ([]byte, error) {
+	sz, err := reachableSize(p)
+	if err != nil {
+		return nil, err
+	}
+	// sz is an upper bound: the copy may share repeated objects.
+	if sz > uint64(math.MaxUint32)-uint64(wordSize) {
+		return nil, errOverlarge
+	}
+	msg, _, err := NewMessage(SingleSegment(make([]byte, 0, uint64(wordSize)+sz)))
+	if err != nil {
+		return nil, err
+	}
+	if err := msg.SetRoot(p); err != nil {
+		return nil, err
+	}
+	return msg.Marshal()
 }
 
 // MarshalPacked marshals the message in packed form.
@@ -491,6 +1632,10 @@ const (
 	segHeaderSize = 4
 )
 
+// maxSegmentWords is the largest segment size, in words, whose size in
+// bytes fits in a Size.
+const maxSegmentWords = math.MaxUint32 / uint32(wordSize)
+
 // streamHeaderSize returns the size of the header, given the
 // first 32-bit number.
 func streamHeaderSize(n uint32) int {
@@ -509,20 +1654,35 @@ func marshalStreamHeader(b []byte, sizes []Size) {
 
 // unmarshalStreamHeader parses the header of the stream framing format.
 func unmarshalStreamHeader(data []byte) (sizes []Size, tail []byte, err error) {
+	return unmarshalStreamHeaderInto(nil, data)
+}
+
+// unmarshalStreamHeaderInto is like unmarshalStreamHeader, but appends
+// the segment sizes to sizes.
+func unmarshalStreamHeaderInto(sizes []Size, data []byte) ([]Size, []byte, error) {
 	if len(data) < streamHeaderSize(0) {
 		return nil, nil, io.ErrUnexpectedEOF
 	}
 	maxSeg := binary.LittleEndian.Uint32(data)
-	// TODO(light): check int
+	// Checking against len(data) first keeps streamHeaderSize from
+	// overflowing int.
+	if uint64(maxSeg) >= uint64(len(data))/segHeaderSize {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
 	hdrSize := streamHeaderSize(maxSeg)
 	if len(data) < hdrSize {
 		return nil, nil, io.ErrUnexpectedEOF
 	}
 	n := int(maxSeg + 1)
-	sizes = make([]Size, n)
+	if sizes == nil {
+		sizes = make([]Size, 0, n)
+	}
 	for i := 0; i < n; i++ {
 		s := binary.LittleEndian.Uint32(data[msgHeaderSize+i*segHeaderSize:])
-		sizes[i] = wordSize.times(int32(s))
+		if s > maxSegmentWords {
+			return nil, nil, errStreamHeader
+		}
+		sizes = append(sizes, wordSize.times(int32(s)))
 	}
 	return sizes, data[hdrSize:], nil
 }
@@ -553,7 +1713,9 @@ var (
 	errSegment32Bit       = errors.New("capnp: segment ID larger than 31 bits")
 	errMessageEmpty       = errors.New("capnp: marshalling an empty message")
 	errHasData            = errors.New("capnp: NewMessage called on arena with data")
-	errTooMuchData        = errors.New("capnp: too much data in stream")
 	errSegmentTooSmall    = errors.New("capnp: segment too small")
 	errStreamHeader       = errors.New("capnp: invalid stream header")
+	errPlanMismatch       = errors.New("capnp: message segments changed since SegmentsForOutput")
+	errCanonicalRoot      = errors.New("capnp: canonical form needs a struct root")
+	errSegmentFull        = errors.New("capnp: single segment arena full")
 )
@@ -5,7 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"sync"
 	"testing"
+
+	"zombiezen.com/go/capnproto2/internal/packed"
 )
 
 func TestNewMessage(t *testing.T) {
@@ -48,6 +54,102 @@ func TestNewMessage(t *testing.T) {
 	}
 }
 
+func TestFarPointerSharesLandingPad(t *testing.T) {
+	// The first segment only has room for the root pointer and the root
+	// struct, so the text goes in another segment.
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 24)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if txt.Segment() == seg {
+		t.Fatal("text allocated in first segment; test needs it elsewhere")
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	n := len(txt.Segment().Data())
+	if err := s.SetPointer(1, txt); err != nil {
+		t.Fatal(err)
+	}
+	if m := len(txt.Segment().Data()); m != n {
+		t.Errorf("second far pointer grew the text's segment from %d to %d bytes; want shared landing pad", n, m)
+	}
+	if a, b := seg.readRawPointer(s.pointerAddress(0)), seg.readRawPointer(s.pointerAddress(1)); a != b {
+		t.Errorf("far pointers = %v, %v; want the same landing pad", a, b)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	root, err := mustUnmarshal(t, data).Root()
+	if err != nil {
+		t.Fatal("Root:", err)
+	}
+	for i := uint16(0); i < 2; i++ {
+		p, err := ToStruct(root).Pointer(i)
+		if err != nil || ToText(p) != "hi" {
+			t.Errorf("Pointer(%d) = %q, %v; want \"hi\", <nil>", i, ToText(p), err)
+		}
+	}
+}
+
+func TestRootFarPointer(t *testing.T) {
+	// The first segment only has room for the root pointer, so the root
+	// struct must be placed in another segment with a far pointer.
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0xdeadbeef)
+	if s.Segment().ID() == 0 {
+		t.Fatal("root struct allocated in first segment; test needs it elsewhere")
+	}
+	if raw := seg.readRawPointer(0); raw.pointerType() != farPointer {
+		t.Fatalf("root pointer = %v; want far pointer", raw)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	for _, m := range []*Message{msg, mustUnmarshal(t, data)} {
+		p, err := m.Root()
+		if err != nil {
+			t.Error("Root:", err)
+			continue
+		}
+		root := ToStruct(p)
+		if root.Segment() == nil || root.Segment().ID() == 0 {
+			t.Errorf("Root() = %#v; want struct in segment 1", p)
+			continue
+		}
+		if v := root.Uint64(0); v != 0xdeadbeef {
+			t.Errorf("Root().Uint64(0) = %#x; want 0xdeadbeef", v)
+		}
+	}
+}
+
+func mustUnmarshal(t *testing.T, data []byte) *Message {
+	msg, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	return msg
+}
+
 func TestAlloc(t *testing.T) {
 	type allocTest struct {
 		name string
@@ -268,6 +370,15 @@ func TestSingleSegmentAllocate(t *testing.T) {
 	}
 }
 
+func TestSingleSegmentFull(t *testing.T) {
+	buf := make([]byte, 4096)
+	arena := SingleSegment(buf)
+	segs := map[SegmentID]*Segment{0: &Segment{id: 0, data: buf}}
+	if _, _, err := arena.Allocate(Size(math.MaxUint32-wordSize), segs); err != errSegmentFull {
+		t.Errorf("Allocate past maximum segment size error = %v; want %v", err, errSegmentFull)
+	}
+}
+
 func TestMultiSegment(t *testing.T) {
 	// fresh arena
 	{
@@ -376,6 +487,35 @@ func TestMultiSegmentAllocate(t *testing.T) {
 	}
 }
 
+func TestNewMultiSegmentArena(t *testing.T) {
+	tests := []struct {
+		opts MultiSegmentOptions
+		size Size
+		caps []int
+	}{
+		{MultiSegmentOptions{}, 8, []int{4096, 4096, 4096}},
+		{MultiSegmentOptions{MinSegmentSize: 100}, 8, []int{104, 104, 104}},
+		{MultiSegmentOptions{MinSegmentSize: 64, GrowthFactor: 2}, 8, []int{64, 128, 256}},
+		{MultiSegmentOptions{MinSegmentSize: 64, GrowthFactor: 1.5}, 8, []int{64, 96, 144}},
+		{MultiSegmentOptions{MinSegmentSize: 64, GrowthFactor: 2}, 200, []int{200, 400, 800}},
+	}
+	for _, test := range tests {
+		arena := NewMultiSegmentArena(test.opts)
+		segs := make(map[SegmentID]*Segment)
+		for i, want := range test.caps {
+			id, data, err := arena.Allocate(test.size, segs)
+			if err != nil {
+				t.Fatalf("%+v: Allocate #%d: %v", test.opts, i, err)
+			}
+			if id != SegmentID(i) || cap(data) != want {
+				t.Errorf("%+v: Allocate #%d = segment %d with cap %d; want segment %d with cap %d", test.opts, i, id, cap(data), i, want)
+			}
+			// Fill the segment so the next allocation needs a new one.
+			segs[id] = &Segment{id: id, data: data[:cap(data)]}
+		}
+	}
+}
+
 type serializeTest struct {
 	name        string
 	segs        [][]byte
@@ -499,6 +639,15 @@ var serializeTests = []serializeTest{
 	},
 }
 
+func findSerializeTest(name string) *serializeTest {
+	for i := range serializeTests {
+		if serializeTests[i].name == name {
+			return &serializeTests[i]
+		}
+	}
+	panic("no serialize test named " + name)
+}
+
 func TestMarshal(t *testing.T) {
 	for i, test := range serializeTests {
 		if test.decodeFails {
@@ -522,162 +671,2127 @@ func TestMarshal(t *testing.T) {
 	}
 }
 
-func TestUnmarshal(t *testing.T) {
+func TestSegmentsForOutput(t *testing.T) {
 	for i, test := range serializeTests {
-		if test.encodeFails {
+		if test.decodeFails {
 			continue
 		}
-		msg, err := Unmarshal(test.copyOut())
+		msg := &Message{Arena: test.arena()}
+		plan, err := msg.SegmentsForOutput()
 		if err != nil {
-			if !test.decodeFails {
-				t.Errorf("serializeTests[%d] - %s: Unmarshal error: %v", i, test.name, err)
-			}
-			if test.decodeError != nil && err != test.decodeError {
-				t.Errorf("serializeTests[%d] - %s: Unmarshal error: %v; want %v", i, test.name, err, test.decodeError)
+			if !test.encodeFails {
+				t.Errorf("serializeTests[%d] - %s: SegmentsForOutput error: %v", i, test.name, err)
 			}
 			continue
 		}
-		if test.decodeFails {
-			t.Errorf("serializeTests[%d] - %s: Unmarshal success; want error", i, test.name)
+		if test.encodeFails {
+			t.Errorf("serializeTests[%d] - %s: SegmentsForOutput success; want error", i, test.name)
 			continue
 		}
-		if msg.NumSegments() != int64(len(test.segs)) {
-			t.Errorf("serializeTests[%d] - %s: Unmarshal NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
+		if plan.TotalSize != uint64(len(test.out)) {
+			t.Errorf("serializeTests[%d] - %s: SegmentsForOutput().TotalSize = %d; want %d", i, test.name, plan.TotalSize, len(test.out))
+		}
+		if len(plan.SegmentSizes) != len(test.segs) {
+			t.Errorf("serializeTests[%d] - %s: len(SegmentsForOutput().SegmentSizes) = %d; want %d", i, test.name, len(plan.SegmentSizes), len(test.segs))
 			continue
 		}
-		for j := range test.segs {
-			seg, err := msg.Segment(SegmentID(j))
-			if err != nil {
-				t.Errorf("serializeTests[%d] - %s: Unmarshal Segment(%d) error: %v", i, test.name, j, err)
-				continue
-			}
-			if !bytes.Equal(seg.Data(), test.segs[j]) {
-				t.Errorf("serializeTests[%d] - %s: Unmarshal Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
+		total := uint64(plan.HeaderSize)
+		for j, sz := range plan.SegmentSizes {
+			if sz != Size(len(test.segs[j])) {
+				t.Errorf("serializeTests[%d] - %s: SegmentsForOutput().SegmentSizes[%d] = %d; want %d", i, test.name, j, sz, len(test.segs[j]))
 			}
+			total += uint64(sz)
+		}
+		if total != plan.TotalSize {
+			t.Errorf("serializeTests[%d] - %s: header + segment sizes = %d; TotalSize = %d", i, test.name, total, plan.TotalSize)
 		}
 	}
 }
 
-func TestEncoder(t *testing.T) {
+func TestMessageTotalSize(t *testing.T) {
 	for i, test := range serializeTests {
 		if test.decodeFails {
 			continue
 		}
 		msg := &Message{Arena: test.arena()}
-		var buf bytes.Buffer
-		enc := NewEncoder(&buf)
-		err := enc.Encode(msg)
-		out := buf.Bytes()
+		n, err := msg.TotalSize()
 		if err != nil {
 			if !test.encodeFails {
-				t.Errorf("serializeTests[%d] - %s: Encode error: %v", i, test.name, err)
+				t.Errorf("serializeTests[%d] - %s: TotalSize error: %v", i, test.name, err)
 			}
 			continue
 		}
 		if test.encodeFails {
-			t.Errorf("serializeTests[%d] - %s: Encode success; want error", i, test.name)
+			t.Errorf("serializeTests[%d] - %s: TotalSize success; want error", i, test.name)
 			continue
 		}
-		if !bytes.Equal(out, test.out) {
-			t.Errorf("serializeTests[%d] - %s: Encode = % 02x; want % 02x", i, test.name, out, test.out)
+		if n != uint64(len(test.out)) {
+			t.Errorf("serializeTests[%d] - %s: TotalSize() = %d; want %d", i, test.name, n, len(test.out))
 		}
 	}
 }
 
-func TestDecoder(t *testing.T) {
+func TestWriteSegments(t *testing.T) {
+	for i, test := range serializeTests {
+		if test.decodeFails || test.encodeFails {
+			continue
+		}
+		msg := &Message{Arena: test.arena()}
+		plan, err := msg.SegmentsForOutput()
+		if err != nil {
+			t.Errorf("serializeTests[%d] - %s: SegmentsForOutput error: %v", i, test.name, err)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := msg.WriteSegments(&buf, plan); err != nil {
+			t.Errorf("serializeTests[%d] - %s: WriteSegments error: %v", i, test.name, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.out) {
+			t.Errorf("serializeTests[%d] - %s: WriteSegments wrote % 02x; want % 02x", i, test.name, buf.Bytes(), test.out)
+		}
+		if uint64(buf.Len()) != plan.TotalSize {
+			t.Errorf("serializeTests[%d] - %s: WriteSegments wrote %d bytes; plan.TotalSize = %d", i, test.name, buf.Len(), plan.TotalSize)
+		}
+		if hdr := plan.AppendHeader(nil); !bytes.Equal(hdr, test.out[:plan.HeaderSize]) {
+			t.Errorf("serializeTests[%d] - %s: AppendHeader(nil) = % 02x; want % 02x", i, test.name, hdr, test.out[:plan.HeaderSize])
+		}
+	}
+}
+
+func TestWriteSegmentsChanged(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := msg.SegmentsForOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRootStruct(seg, ObjectSize{DataSize: 8}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := msg.WriteSegments(&buf, plan); err == nil {
+		t.Error("WriteSegments with stale plan succeeded; want error")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("WriteSegments with stale plan wrote %d bytes; want 0", buf.Len())
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
 	for i, test := range serializeTests {
 		if test.encodeFails {
 			continue
 		}
-		msg, err := NewDecoder(bytes.NewReader(test.out)).Decode()
+		msg, err := Unmarshal(test.copyOut())
 		if err != nil {
 			if !test.decodeFails {
-				t.Errorf("serializeTests[%d] - %s: Decode error: %v", i, test.name, err)
+				t.Errorf("serializeTests[%d] - %s: Unmarshal error: %v", i, test.name, err)
 			}
 			if test.decodeError != nil && err != test.decodeError {
-				t.Errorf("serializeTests[%d] - %s: Decode error: %v; want %v", i, test.name, err, test.decodeError)
+				t.Errorf("serializeTests[%d] - %s: Unmarshal error: %v; want %v", i, test.name, err, test.decodeError)
 			}
 			continue
 		}
 		if test.decodeFails {
-			t.Errorf("serializeTests[%d] - %s: Decode success; want error", i, test.name)
+			t.Errorf("serializeTests[%d] - %s: Unmarshal success; want error", i, test.name)
 			continue
 		}
 		if msg.NumSegments() != int64(len(test.segs)) {
-			t.Errorf("serializeTests[%d] - %s: Decode NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
+			t.Errorf("serializeTests[%d] - %s: Unmarshal NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
 			continue
 		}
 		for j := range test.segs {
 			seg, err := msg.Segment(SegmentID(j))
 			if err != nil {
-				t.Errorf("serializeTests[%d] - %s: Decode Segment(%d) error: %v", i, test.name, j, err)
+				t.Errorf("serializeTests[%d] - %s: Unmarshal Segment(%d) error: %v", i, test.name, j, err)
 				continue
 			}
 			if !bytes.Equal(seg.Data(), test.segs[j]) {
-				t.Errorf("serializeTests[%d] - %s: Decode Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
+				t.Errorf("serializeTests[%d] - %s: Unmarshal Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
 			}
 		}
 	}
 }
 
-type arenaAllocTest struct {
-	name string
-
-	// Arrange
-	init func() (Arena, map[SegmentID]*Segment)
-	size Size
-
-	// Assert
-	id   SegmentID
-	data []byte
-}
-
-func (test *arenaAllocTest) run(t *testing.T, i int) {
-	arena, segs := test.init()
-	id, data, err := arena.Allocate(test.size, segs)
+func TestUnmarshalN(t *testing.T) {
+	first := findSerializeTest("two segments").copyOut()
+	second := findSerializeTest("single segment").copyOut()
+	tests := []struct {
+		name  string
+		extra []byte
+	}{
+		{"no trailing bytes", nil},
+		{"second message", second},
+		{"partial second message", second[:len(second)-1]},
+		{"partial header", second[:3]},
+	}
+	for _, test := range tests {
+		data := append(append([]byte(nil), first...), test.extra...)
+		msg, n, err := UnmarshalN(data)
+		if err != nil {
+			t.Errorf("%s: UnmarshalN error: %v", test.name, err)
+			continue
+		}
+		if n != len(first) {
+			t.Errorf("%s: UnmarshalN consumed %d bytes; want %d", test.name, n, len(first))
+		}
+		if msg.NumSegments() != 2 {
+			t.Errorf("%s: UnmarshalN NumSegments() = %d; want 2", test.name, msg.NumSegments())
+		}
+	}
 
+	data := append(append([]byte(nil), first...), second...)
+	_, n, err := UnmarshalN(data)
 	if err != nil {
-		t.Errorf("tests[%d] - %s: Allocate error: %v", i, test.name, err)
-		return
+		t.Fatal(err)
 	}
-	if id != test.id {
-		t.Errorf("tests[%d] - %s: Allocate id = %d; want %d", i, test.name, id, test.id)
+	msg, m, err := UnmarshalN(data[n:])
+	if err != nil {
+		t.Fatal("UnmarshalN of second message:", err)
 	}
-	if !bytes.Equal(data, test.data) {
-		t.Errorf("tests[%d] - %s: Allocate data = % 02x; want % 02x", i, test.name, data, test.data)
+	if m != len(second) {
+		t.Errorf("UnmarshalN of second message consumed %d bytes; want %d", m, len(second))
 	}
-	if Size(cap(data)-len(data)) < test.size {
-		t.Errorf("tests[%d] - %s: Allocate len(data) = %d, cap(data) = %d; cap(data) should be at least %d", i, test.name, len(data), cap(data), Size(len(data))+test.size)
+	if msg.NumSegments() != 1 {
+		t.Errorf("second message NumSegments() = %d; want 1", msg.NumSegments())
+	}
+	if _, _, err := UnmarshalN(second[:len(second)-1]); err != io.ErrUnexpectedEOF {
+		t.Errorf("UnmarshalN of truncated message error = %v; want %v", err, io.ErrUnexpectedEOF)
 	}
 }
 
-func incrementingData(n int) []byte {
-	b := make([]byte, n)
-	for i := range b {
-		b[i] = byte(i % 256)
+func TestUnmarshalExact(t *testing.T) {
+	// One segment of one word.
+	exact := []byte{
+		0, 0, 0, 0, 1, 0, 0, 0,
+		1, 2, 3, 4, 5, 6, 7, 8,
+	}
+	if _, err := UnmarshalExact(exact); err != nil {
+		t.Errorf("UnmarshalExact(exact) error: %v", err)
+	}
+
+	inflated := append([]byte(nil), exact...)
+	inflated[4] = 2
+	_, err := UnmarshalExact(inflated)
+	if want := "capnp: stream header declares 24 bytes, but have 16"; err == nil || err.Error() != want {
+		t.Errorf("UnmarshalExact(inflated) error = %v; want %q", err, want)
+	}
+	if _, err := Unmarshal(inflated); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unmarshal(inflated) error = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+
+	deflated := append([]byte(nil), exact...)
+	deflated[4] = 0
+	_, err = UnmarshalExact(deflated)
+	if want := "capnp: stream header declares 8 bytes, but have 16"; err == nil || err.Error() != want {
+		t.Errorf("UnmarshalExact(deflated) error = %v; want %q", err, want)
 	}
-	return b
 }
 
-func isZeroFilled(b []byte) bool {
-	for _, bb := range b {
-		if bb != 0 {
-			return false
+func TestUnmarshalHeaderOverflow(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{
+			name: "huge segment count",
+			data: []byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "segment larger than a Size",
+			data: []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff},
+			err:  errStreamHeader,
+		},
+		{
+			name: "segment size wraps to small",
+			data: []byte{
+				0, 0, 0, 0, 0x01, 0, 0, 0x20,
+				1, 2, 3, 4, 5, 6, 7, 8,
+			},
+			err: errStreamHeader,
+		},
+	}
+	for _, test := range tests {
+		if _, err := Unmarshal(test.data); err != test.err {
+			t.Errorf("%s: Unmarshal error = %v; want %v", test.name, err, test.err)
 		}
 	}
-	return true
 }
 
-type readOnlyArena struct {
-	Arena
+func TestUnpackOne(t *testing.T) {
+	pack := func(v uint64, text string) []byte {
+		msg, seg, err := NewMessage(SingleSegment(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetUint64(0, v)
+		txt, err := NewText(seg, text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		data, err := msg.MarshalPacked()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	first, second := pack(42, "hello"), pack(0, "")
+	data := append(append([]byte(nil), first...), second...)
+
+	for i, want := range []uint64{42, 0} {
+		msg, n, err := UnpackOne(data)
+		if err != nil {
+			t.Fatalf("UnpackOne #%d: %v", i+1, err)
+		}
+		if want := len([][]byte{first, second}[i]); n != want {
+			t.Errorf("UnpackOne #%d consumed %d bytes; want %d", i+1, n, want)
+		}
+		p, err := msg.Root()
+		if err != nil {
+			t.Fatalf("UnpackOne #%d Root: %v", i+1, err)
+		}
+		if v := ToStruct(p).Uint64(0); v != want {
+			t.Errorf("UnpackOne #%d root.Uint64(0) = %d; want %d", i+1, v, want)
+		}
+		data = data[n:]
+	}
+	if _, _, err := UnpackOne(data); err != io.EOF {
+		t.Errorf("UnpackOne at end error = %v; want %v", err, io.EOF)
+	}
+	for n := 1; n < len(first); n++ {
+		if _, _, err := UnpackOne(first[:n]); err != io.ErrUnexpectedEOF {
+			t.Errorf("UnpackOne(first %d bytes) error = %v; want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
 }
 
-func (ro readOnlyArena) String() string {
-	return fmt.Sprintf("readOnlyArena{%v}", ro.Arena)
+func TestUnmarshalInPlace(t *testing.T) {
+	for i, test := range serializeTests {
+		if test.encodeFails {
+			continue
+		}
+		data := test.copyOut()
+		msg, err := UnmarshalInPlace(data)
+		if err != nil {
+			if !test.decodeFails {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalInPlace error: %v", i, test.name, err)
+			}
+			continue
+		}
+		if test.decodeFails {
+			t.Errorf("serializeTests[%d] - %s: UnmarshalInPlace success; want error", i, test.name)
+			continue
+		}
+		if msg.NumSegments() != int64(len(test.segs)) {
+			t.Errorf("serializeTests[%d] - %s: UnmarshalInPlace NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
+			continue
+		}
+		for j := range test.segs {
+			seg, err := msg.Segment(SegmentID(j))
+			if err != nil {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalInPlace Segment(%d) error: %v", i, test.name, j, err)
+				continue
+			}
+			if !bytes.Equal(seg.Data(), test.segs[j]) {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalInPlace Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
+			}
+		}
+	}
 }
 
-func (readOnlyArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+func TestUnmarshalInPlaceAliases(t *testing.T) {
+	data := findSerializeTest("two segments").copyOut()
+	msg, err := UnmarshalInPlace(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg, err := msg.Segment(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &seg.Data()[0] != &data[len(data)-8] {
+		t.Error("Segment(1).Data() does not alias the input")
+	}
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8}); err != errReadOnly {
+		t.Errorf("NewStruct in read-only message error = %v; want %v", err, errReadOnly)
+	}
+}
+
+func TestUnmarshalInPlaceReadOnly(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 42)
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := append([]byte(nil), data...)
+
+	msg, err = UnmarshalInPlace(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ToStruct(p)
+	if err := catchPanic(func() { s.SetUint64(0, 7) }); err != errReadOnly {
+		t.Errorf("SetUint64 in read-only message panic = %v; want %v", err, errReadOnly)
+	}
+	if err := s.SetPointer(0, nil); err != errReadOnly {
+		t.Errorf("SetPointer in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if _, err := s.WriteData(0, []byte{1}); err != errReadOnly {
+		t.Errorf("WriteData in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if _, err := s.Disown(0); err != errReadOnly {
+		t.Errorf("Disown in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if err := msg.SetRoot(nil); err != errReadOnly {
+		t.Errorf("SetRoot in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if !bytes.Equal(data, orig) {
+		t.Errorf("data after writes = % 02x; want % 02x", data, orig)
+	}
+	if v := s.Uint64(0); v != 42 {
+		t.Errorf("Uint64(0) = %d; want 42", v)
+	}
+}
+
+func TestUnmarshalReaderAt(t *testing.T) {
+	for i, test := range serializeTests {
+		if test.encodeFails {
+			continue
+		}
+		data := test.copyOut()
+		msg, err := UnmarshalReaderAt(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			if !test.decodeFails {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalReaderAt error: %v", i, test.name, err)
+			}
+			continue
+		}
+		if test.decodeFails {
+			t.Errorf("serializeTests[%d] - %s: UnmarshalReaderAt success; want error", i, test.name)
+			continue
+		}
+		if msg.NumSegments() != int64(len(test.segs)) {
+			t.Errorf("serializeTests[%d] - %s: UnmarshalReaderAt NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
+			continue
+		}
+		for j := range test.segs {
+			seg, err := msg.Segment(SegmentID(j))
+			if err != nil {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalReaderAt Segment(%d) error: %v", i, test.name, j, err)
+				continue
+			}
+			if !bytes.Equal(seg.Data(), test.segs[j]) {
+				t.Errorf("serializeTests[%d] - %s: UnmarshalReaderAt Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
+			}
+		}
+	}
+}
+
+func TestUnmarshalReaderAtTruncated(t *testing.T) {
+	data := findSerializeTest("two segments").copyOut()
+	// The reader is shorter than the size the caller claims, so the
+	// error only shows up when the last segment is read.
+	msg, err := UnmarshalReaderAt(bytes.NewReader(data[:len(data)-8]), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg, err := msg.Segment(0)
+	if err != nil {
+		t.Fatal("Segment(0):", err)
+	}
+	if _, err := msg.Segment(1); err != io.ErrUnexpectedEOF {
+		t.Errorf("Segment(1) error = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8}); err != errReadOnly {
+		t.Errorf("NewStruct in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if _, err := UnmarshalReaderAt(bytes.NewReader(data), int64(len(data)-8)); err != io.ErrUnexpectedEOF {
+		t.Errorf("UnmarshalReaderAt with short size error = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestUnmarshalReaderAtReadOnly(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 42)
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = UnmarshalReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ToStruct(p)
+	if err := catchPanic(func() { s.SetUint64(0, 7) }); err != errReadOnly {
+		t.Errorf("SetUint64 in read-only message panic = %v; want %v", err, errReadOnly)
+	}
+	if err := s.SetPointer(0, s); err != errReadOnly {
+		t.Errorf("SetPointer in read-only message error = %v; want %v", err, errReadOnly)
+	}
+	if v := s.Uint64(0); v != 42 {
+		t.Errorf("Uint64(0) = %d; want 42", v)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	for i, test := range serializeTests {
+		if test.decodeFails {
+			continue
+		}
+		msg := &Message{Arena: test.arena()}
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		err := enc.Encode(msg)
+		out := buf.Bytes()
+		if err != nil {
+			if !test.encodeFails {
+				t.Errorf("serializeTests[%d] - %s: Encode error: %v", i, test.name, err)
+			}
+			continue
+		}
+		if test.encodeFails {
+			t.Errorf("serializeTests[%d] - %s: Encode success; want error", i, test.name)
+			continue
+		}
+		if !bytes.Equal(out, test.out) {
+			t.Errorf("serializeTests[%d] - %s: Encode = % 02x; want % 02x", i, test.name, out, test.out)
+		}
+	}
+}
+
+func TestPackedEncoder(t *testing.T) {
+	// A segment several chunks long, with both zero and literal runs
+	// crossing the chunk boundaries.
+	data := make([]byte, 8*packChunkSize+8*wordSize)
+	for i := packChunkSize - 16; i < len(data); i += 3 {
+		data[i] = byte(i)
+	}
+	msg := &Message{Arena: MultiSegment([][]byte{data})}
+	want, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	w := new(maxWriteBuffer)
+	if err := NewPackedEncoder(w).Encode(msg); err != nil {
+		t.Fatal("Encode:", err)
+	}
+	if limit := 2 * packChunkSize; w.max > limit {
+		t.Errorf("largest write = %d bytes; want <= %d", w.max, limit)
+	}
+	got, err := ioutil.ReadAll(packed.NewReader(&w.buf))
+	if err != nil {
+		t.Fatal("unpacking:", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("unpacked Encode output differs from Marshal")
+	}
+}
+
+// maxWriteBuffer is a bytes.Buffer that records its largest write.
+type maxWriteBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (w *maxWriteBuffer) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		w.max = len(p)
+	}
+	return w.buf.Write(p)
+}
+
+func TestDecoder(t *testing.T) {
+	for i, test := range serializeTests {
+		if test.encodeFails {
+			continue
+		}
+		msg, err := NewDecoder(bytes.NewReader(test.out)).Decode()
+		if err != nil {
+			if !test.decodeFails {
+				t.Errorf("serializeTests[%d] - %s: Decode error: %v", i, test.name, err)
+			}
+			if test.decodeError != nil && err != test.decodeError {
+				t.Errorf("serializeTests[%d] - %s: Decode error: %v; want %v", i, test.name, err, test.decodeError)
+			}
+			continue
+		}
+		if test.decodeFails {
+			t.Errorf("serializeTests[%d] - %s: Decode success; want error", i, test.name)
+			continue
+		}
+		if msg.NumSegments() != int64(len(test.segs)) {
+			t.Errorf("serializeTests[%d] - %s: Decode NumSegments() = %d; want %d", i, test.name, msg.NumSegments(), len(test.segs))
+			continue
+		}
+		for j := range test.segs {
+			seg, err := msg.Segment(SegmentID(j))
+			if err != nil {
+				t.Errorf("serializeTests[%d] - %s: Decode Segment(%d) error: %v", i, test.name, j, err)
+				continue
+			}
+			if !bytes.Equal(seg.Data(), test.segs[j]) {
+				t.Errorf("serializeTests[%d] - %s: Decode Segment(%d) = % 02x; want % 02x", i, test.name, j, seg.Data(), test.segs[j])
+			}
+		}
+	}
+}
+
+func TestDecodeAll(t *testing.T) {
+	var stream []byte
+	var want []serializeTest
+	for _, test := range serializeTests {
+		if test.encodeFails || test.decodeFails {
+			continue
+		}
+		stream = append(stream, test.out...)
+		want = append(want, test)
+	}
+	n := 0
+	err := NewDecoder(bytes.NewReader(stream)).DecodeAll(func(msg *Message) error {
+		if n >= len(want) {
+			t.Errorf("DecodeAll message #%d: more messages than expected", n)
+			n++
+			return nil
+		}
+		test := want[n]
+		n++
+		if msg.NumSegments() != int64(len(test.segs)) {
+			t.Errorf("DecodeAll message #%d (%s): NumSegments() = %d; want %d", n-1, test.name, msg.NumSegments(), len(test.segs))
+			return nil
+		}
+		for j := range test.segs {
+			seg, err := msg.Segment(SegmentID(j))
+			if err != nil {
+				t.Errorf("DecodeAll message #%d (%s): Segment(%d) error: %v", n-1, test.name, j, err)
+				continue
+			}
+			if !bytes.Equal(seg.Data(), test.segs[j]) {
+				t.Errorf("DecodeAll message #%d (%s): Segment(%d) = % 02x; want % 02x", n-1, test.name, j, seg.Data(), test.segs[j])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("DecodeAll error: %v", err)
+	}
+	if n != len(want) {
+		t.Errorf("DecodeAll called f %d times; want %d", n, len(want))
+	}
+}
+
+func TestDecodeAllTruncated(t *testing.T) {
+	out := findSerializeTest("two segments").out
+	stream := out[:len(out)-1]
+	err := NewDecoder(bytes.NewReader(stream)).DecodeAll(func(*Message) error {
+		t.Error("DecodeAll called f on truncated message")
+		return nil
+	})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("DecodeAll on truncated stream = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecodeAllCallbackError(t *testing.T) {
+	out := findSerializeTest("single segment").out
+	stream := append(append([]byte(nil), out...), out...)
+	errStop := errors.New("stop")
+	n := 0
+	err := NewDecoder(bytes.NewReader(stream)).DecodeAll(func(*Message) error {
+		n++
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("DecodeAll error = %v; want %v", err, errStop)
+	}
+	if n != 1 {
+		t.Errorf("DecodeAll called f %d times; want 1", n)
+	}
+}
+
+func TestAutoDecoder(t *testing.T) {
+	for _, name := range []string{"single segment", "two segments"} {
+		out := findSerializeTest(name).out
+		stream := append(append([]byte(nil), out...), out...)
+		for _, isPacked := range []bool{false, true} {
+			in := stream
+			if isPacked {
+				in = packed.Pack(nil, stream)
+			}
+			d, err := NewAutoDecoder(bytes.NewReader(in))
+			if err != nil {
+				t.Errorf("%s (packed=%t): NewAutoDecoder: %v", name, isPacked, err)
+				continue
+			}
+			for i := 0; i < 2; i++ {
+				msg, err := d.Decode()
+				if err != nil {
+					t.Errorf("%s (packed=%t): Decode #%d: %v", name, isPacked, i, err)
+					break
+				}
+				if got, err := msg.Marshal(); err != nil {
+					t.Errorf("%s (packed=%t): Marshal #%d: %v", name, isPacked, i, err)
+				} else if !bytes.Equal(got, out) {
+					t.Errorf("%s (packed=%t): message #%d = %x; want %x", name, isPacked, i, got, out)
+				}
+			}
+			if _, err := d.Decode(); err != io.EOF {
+				t.Errorf("%s (packed=%t): Decode at end = %v; want %v", name, isPacked, err, io.EOF)
+			}
+		}
+	}
+
+	d, err := NewAutoDecoder(bytes.NewReader(nil))
+	if err != nil {
+		t.Fatal("NewAutoDecoder(empty):", err)
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Errorf("empty stream: Decode = %v; want %v", err, io.EOF)
+	}
+}
+
+func TestEncodeAllMore(t *testing.T) {
+	var msgs []*Message
+	for i := 0; i < 3; i++ {
+		msg, seg, err := NewMessage(SingleSegment(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.SetUint64(0, uint64(i))
+		msgs = append(msgs, msg)
+	}
+	for _, isPacked := range []bool{false, true} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		if isPacked {
+			enc = NewPackedEncoder(&buf)
+		}
+		if err := enc.EncodeAll(msgs); err != nil {
+			t.Fatalf("packed=%t: EncodeAll: %v", isPacked, err)
+		}
+		dec := NewDecoder(&buf)
+		if isPacked {
+			dec = NewPackedDecoder(&buf)
+		}
+		n := 0
+		for dec.More() {
+			msg, err := dec.Decode()
+			if err != nil {
+				t.Fatalf("packed=%t: Decode #%d: %v", isPacked, n, err)
+			}
+			if n >= len(msgs) {
+				t.Fatalf("packed=%t: More reported more than %d messages", isPacked, len(msgs))
+			}
+			if eq, err := msg.Equal(msgs[n]); err != nil || !eq {
+				t.Errorf("packed=%t: message #%d differs from encoded message (err=%v)", isPacked, n, err)
+			}
+			n++
+		}
+		if n != len(msgs) {
+			t.Errorf("packed=%t: decoded %d messages; want %d", isPacked, n, len(msgs))
+		}
+		if dec.More() {
+			t.Errorf("packed=%t: More after end = true", isPacked)
+		}
+		if _, err := dec.Decode(); err != io.EOF {
+			t.Errorf("packed=%t: Decode after end = %v; want %v", isPacked, err, io.EOF)
+		}
+	}
+
+	dec := NewDecoder(bytes.NewReader([]byte{0}))
+	if !dec.More() {
+		t.Error("truncated stream: More = false; want true")
+	}
+	if _, err := dec.Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated stream: Decode = %v; want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecoderEOF(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "empty stream", data: []byte{}, err: io.EOF},
+		{name: "partial segment count", data: []byte{0x00, 0x00}, err: io.ErrUnexpectedEOF},
+		{
+			name: "missing segment sizes",
+			data: []byte{0x01, 0x00, 0x00, 0x00},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "missing segment data",
+			data: []byte{
+				0x00, 0x00, 0x00, 0x00,
+				0x01, 0x00, 0x00, 0x00,
+			},
+			err: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, test := range tests {
+		_, err := NewDecoder(bytes.NewReader(test.data)).Decode()
+		if err != test.err {
+			t.Errorf("%s: Decode error = %v; want %v", test.name, err, test.err)
+		}
+		err = NewDecoder(bytes.NewReader(test.data)).DecodeInto(new(Message))
+		if err != test.err {
+			t.Errorf("%s: DecodeInto error = %v; want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestDecoderMaxMessageSize(t *testing.T) {
+	out := findSerializeTest("two segments").out
+	tests := []struct {
+		max uint64
+		ok  bool
+	}{
+		{0, true},
+		{uint64(len(out)), true},
+		{uint64(len(out)) - 1, false},
+		{8, false},
+	}
+	for _, test := range tests {
+		d := NewDecoder(bytes.NewReader(out))
+		d.MaxMessageSize = test.max
+		_, err := d.Decode()
+		if test.ok && err != nil {
+			t.Errorf("MaxMessageSize = %d: Decode error: %v", test.max, err)
+		} else if !test.ok && err != ErrMessageTooLarge {
+			t.Errorf("MaxMessageSize = %d: Decode error = %v; want %v", test.max, err, ErrMessageTooLarge)
+		}
+	}
+}
+
+func TestDecoderDefaultMaxMessageSize(t *testing.T) {
+	// The header claims a single 2 GiB segment, but the stream ends
+	// right after it.
+	hdr := []byte{
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x10,
+	}
+	if _, err := NewDecoder(bytes.NewReader(hdr)).Decode(); err != ErrMessageTooLarge {
+		t.Errorf("Decode error = %v; want %v", err, ErrMessageTooLarge)
+	}
+	if err := NewDecoder(bytes.NewReader(hdr)).DecodeInto(new(Message)); err != ErrMessageTooLarge {
+		t.Errorf("DecodeInto error = %v; want %v", err, ErrMessageTooLarge)
+	}
+}
+
+func TestMessageReset(t *testing.T) {
+	msg := &Message{Arena: findSerializeTest("two segments").arena()}
+	seg0, err := msg.Segment(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := msg.Segment(1); err != nil {
+		t.Fatal(err)
+	}
+	msg.AddCap(nil)
+
+	data := incrementingData(16)
+	msg.Reset(SingleSegment(data))
+	if len(msg.CapTable) != 0 {
+		t.Errorf("len(CapTable) after Reset = %d; want 0", len(msg.CapTable))
+	}
+	if n := msg.NumSegments(); n != 1 {
+		t.Errorf("NumSegments() after Reset = %d; want 1", n)
+	}
+	seg, err := msg.Segment(0)
+	if err != nil {
+		t.Fatal("Segment(0) after Reset:", err)
+	}
+	if seg != seg0 {
+		t.Error("Segment(0) after Reset allocated a new segment")
+	}
+	if !bytes.Equal(seg.Data(), data) {
+		t.Errorf("Segment(0).Data() after Reset = % 02x; want % 02x", seg.Data(), data)
+	}
+	if _, err := msg.Segment(1); err == nil {
+		t.Error("Segment(1) after Reset succeeded; want error")
+	}
+}
+
+func TestMessageResetBuild(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRootStruct(seg, ObjectSize{DataSize: 8}); err != nil {
+		t.Fatal(err)
+	}
+	want, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, arena := range []Arena{SingleSegment(nil), MultiSegment(nil)} {
+		msg.Reset(arena)
+		seg, err := msg.Segment(0)
+		if err != nil {
+			t.Fatalf("Reset(%T): Segment(0): %v", arena, err)
+		}
+		if _, err := NewRootStruct(seg, ObjectSize{DataSize: 8}); err != nil {
+			t.Fatalf("Reset(%T): NewRootStruct: %v", arena, err)
+		}
+		got, err := msg.Marshal()
+		if err != nil {
+			t.Fatalf("Reset(%T): Marshal: %v", arena, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("Reset(%T): Marshal() = % 02x; want % 02x", arena, got, want)
+		}
+	}
+}
+
+func TestDecodeIntoAllocs(t *testing.T) {
+	r := &repeatReader{data: benchmarkDecodeStream(1)}
+	d := NewDecoder(r)
+	msg := new(Message)
+	decode := func() {
+		if err := d.DecodeInto(msg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := msg.Segment(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decode()
+	if n := testing.AllocsPerRun(100, decode); n > 0 {
+		t.Errorf("DecodeInto allocated %.1f times per message; want 0", n)
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	build := func(arena Arena, textFirst bool) *Message {
+		msg, seg, err := NewMessage(arena)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var txt UInt8List
+		if textFirst {
+			if txt, err = NewText(seg, "hello"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.SetUint64(0, 42)
+		if !textFirst {
+			if txt, err = NewText(seg, "hello"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := root.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	a, err := build(SingleSegment(nil), false).MarshalDeterministic()
+	if err != nil {
+		t.Fatal("MarshalDeterministic:", err)
+	}
+	b, err := build(MultiSegment(nil), true).MarshalDeterministic()
+	if err != nil {
+		t.Fatal("MarshalDeterministic:", err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("MarshalDeterministic differs between builds:\n%x\n%x", a, b)
+	}
+	msg, err := Unmarshal(a)
+	if err != nil {
+		t.Fatal("Unmarshal:", err)
+	}
+	if eq, err := msg.Equal(build(SingleSegment(nil), false)); err != nil || !eq {
+		t.Errorf("unmarshaled message Equal = %t, %v; want true, <nil>", eq, err)
+	}
+
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewInt8List(seg, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seg.Message().SetRoot(l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seg.Message().MarshalDeterministic(); err == nil {
+		t.Error("MarshalDeterministic with list root succeeded; want error")
+	}
+}
+
+func TestMarshalSubtree(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 1)
+	child, err := NewStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child.SetUint64(0, 42)
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, child); err != nil {
+		t.Fatal(err)
+	}
+	names, err := NewTextList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := names.Set(0, "foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := names.Set(1, "bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, names); err != nil {
+		t.Fatal(err)
+	}
+
+	// Struct subtree: header + root pointer + 16-byte struct + text.
+	data, err := MarshalSubtree(child)
+	if err != nil {
+		t.Fatal("MarshalSubtree(child):", err)
+	}
+	if len(data) != 40 {
+		t.Errorf("len(MarshalSubtree(child)) = %d; want 40", len(data))
+	}
+	msg := mustUnmarshal(t, data)
+	p, err := msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ToStruct(p)
+	if v := s.Uint64(0); v != 42 {
+		t.Errorf("subtree root Uint64(0) = %d; want 42", v)
+	}
+	tp, err := s.Pointer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ToText(tp); got != "hello" {
+		t.Errorf("subtree root text = %q; want \"hello\"", got)
+	}
+
+	// List subtree.
+	data, err = MarshalSubtree(names)
+	if err != nil {
+		t.Fatal("MarshalSubtree(names):", err)
+	}
+	msg = mustUnmarshal(t, data)
+	p, err = msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := TextList{ToList(p)}
+	if l.Len() != 2 {
+		t.Fatalf("subtree list length = %d; want 2", l.Len())
+	}
+	for i, want := range []string{"foo", "bar"} {
+		if got, err := l.At(i); err != nil || got != want {
+			t.Errorf("subtree list[%d] = %q, %v; want %q, <nil>", i, got, err, want)
+		}
+	}
+}
+
+func TestOnAllocFail(t *testing.T) {
+	arena := &quotaArena{Arena: SingleSegment(nil), quota: 0}
+	msg := &Message{Arena: arena}
+	var calls int
+	var requested Size
+	msg.OnAllocFail = func(sz Size) error {
+		calls++
+		requested = sz
+		arena.quota = 1
+		return nil
+	}
+	seg, err := msg.Segment(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8 * 1024}); err != nil {
+		t.Fatal("NewStruct after OnAllocFail made room:", err)
+	}
+	if calls != 1 {
+		t.Errorf("OnAllocFail called %d times; want 1", calls)
+	}
+	if requested != 8*1024 {
+		t.Errorf("OnAllocFail requested = %d; want %d", requested, 8*1024)
+	}
+
+	// A retry that fails again returns the arena's error.
+	arena.quota = 0
+	calls = 0
+	msg.OnAllocFail = func(Size) error {
+		calls++
+		return nil
+	}
+	seg, err = msg.Segment(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8 * 1024}); err != errQuota {
+		t.Errorf("NewStruct with failed retry error = %v; want %v", err, errQuota)
+	}
+	if calls != 1 {
+		t.Errorf("OnAllocFail called %d times; want 1", calls)
+	}
+
+	// The hook's error replaces the arena's.
+	errHook := errors.New("hook error")
+	msg.OnAllocFail = func(Size) error { return errHook }
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8 * 1024}); err != errHook {
+		t.Errorf("NewStruct with failing hook error = %v; want %v", err, errHook)
+	}
+}
+
+func TestOnAlloc(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const budget = 64
+	var used Size
+	errBudget := errors.New("over budget")
+	msg.OnAlloc = func(sz Size) error {
+		if used+sz > budget {
+			return errBudget
+		}
+		used += sz
+		return nil
+	}
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1}); err != nil {
+		t.Fatal("NewStruct within budget:", err)
+	}
+	if _, err := NewText(seg, "hello"); err != nil {
+		t.Fatal("NewText within budget:", err)
+	}
+	if used != 24 {
+		t.Errorf("OnAlloc saw %d bytes; want 24", used)
+	}
+	before := len(seg.Data())
+	if _, err := NewStruct(seg, ObjectSize{DataSize: 48}); err != errBudget {
+		t.Errorf("NewStruct over budget error = %v; want %v", err, errBudget)
+	}
+	if after := len(seg.Data()); after != before {
+		t.Errorf("segment grew from %d to %d bytes after failed allocation", before, after)
+	}
+}
+
+// quotaArena is an arena that only allows a limited number of
+// allocations.
+type quotaArena struct {
+	Arena
+	quota int
+}
+
+func (qa *quotaArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
+	if qa.quota == 0 {
+		return 0, nil, errQuota
+	}
+	qa.quota--
+	return qa.Arena.Allocate(sz, segs)
+}
+
+var errQuota = errors.New("allocation quota exceeded")
+
+func TestPackedDecodeIntoAllocs(t *testing.T) {
+	r := &repeatReader{data: packed.Pack(nil, benchmarkDecodeStream(1))}
+	d := NewPackedDecoder(r)
+	msg := new(Message)
+	decode := func() {
+		if err := d.DecodeInto(msg); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := msg.Segment(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	decode()
+	if n := testing.AllocsPerRun(100, decode); n > 0 {
+		t.Errorf("packed DecodeInto allocated %.1f times per message; want 0", n)
+	}
+}
+
+// repeatReader reads data over and over again.
+type repeatReader struct {
+	data []byte
+	off  int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.off == len(r.data) {
+		r.off = 0
+	}
+	n := copy(p, r.data[r.off:])
+	r.off += n
+	return n, nil
+}
+
+func benchmarkDecodeStream(n int) []byte {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		panic(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		panic(err)
+	}
+	s.SetUint64(0, 42)
+	txt, err := NewText(seg, "Hello, World!")
+	if err != nil {
+		panic(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		panic(err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		panic(err)
+	}
+	stream := make([]byte, 0, len(data)*n)
+	for i := 0; i < n; i++ {
+		stream = append(stream, data...)
+	}
+	return stream
+}
+
+func BenchmarkDecode(b *testing.B) {
+	const n = 1000
+	stream := benchmarkDecodeStream(n)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := NewDecoder(bytes.NewReader(stream))
+		for j := 0; j < n; j++ {
+			msg, err := dec.Decode()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := msg.Root(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeAll(b *testing.B) {
+	const n = 1000
+	stream := benchmarkDecodeStream(n)
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := NewDecoder(bytes.NewReader(stream)).DecodeAll(func(msg *Message) error {
+			_, err := msg.Root()
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodePacked(b *testing.B) {
+	const n = 1000
+	stream := packed.Pack(nil, benchmarkDecodeStream(n))
+	b.SetBytes(int64(len(stream)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := NewPackedDecoder(bytes.NewReader(stream)).DecodeAll(func(msg *Message) error {
+			_, err := msg.Root()
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type arenaAllocTest struct {
+	name string
+
+	// Arrange
+	init func() (Arena, map[SegmentID]*Segment)
+	size Size
+
+	// Assert
+	id   SegmentID
+	data []byte
+}
+
+func (test *arenaAllocTest) run(t *testing.T, i int) {
+	arena, segs := test.init()
+	id, data, err := arena.Allocate(test.size, segs)
+
+	if err != nil {
+		t.Errorf("tests[%d] - %s: Allocate error: %v", i, test.name, err)
+		return
+	}
+	if id != test.id {
+		t.Errorf("tests[%d] - %s: Allocate id = %d; want %d", i, test.name, id, test.id)
+	}
+	if !bytes.Equal(data, test.data) {
+		t.Errorf("tests[%d] - %s: Allocate data = % 02x; want % 02x", i, test.name, data, test.data)
+	}
+	if Size(cap(data)-len(data)) < test.size {
+		t.Errorf("tests[%d] - %s: Allocate len(data) = %d, cap(data) = %d; cap(data) should be at least %d", i, test.name, len(data), cap(data), Size(len(data))+test.size)
+	}
+}
+
+func incrementingData(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i % 256)
+	}
+	return b
+}
+
+func isZeroFilled(b []byte) bool {
+	for _, bb := range b {
+		if bb != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+type readOnlyArena struct {
+	Arena
+}
+
+func (ro readOnlyArena) String() string {
+	return fmt.Sprintf("readOnlyArena{%v}", ro.Arena)
+}
+
+func (readOnlyArena) Allocate(sz Size, segs map[SegmentID]*Segment) (SegmentID, []byte, error) {
 	return 0, nil, errReadOnlyArena
 }
 
-var errReadOnlyArena = errors.New("Allocate called on read-only arena")
+var errReadOnlyArena = errors.New("Allocate called on read-only arena")
+
+func TestAllocStats(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewText(seg, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	st := msg.AllocStats()
+	want := AllocStats{
+		Segments:   1,
+		Capacity:   uint64(cap(seg.Data())),
+		Used:       32,
+		Allocs:     3,
+		AllocBytes: 32,
+	}
+	if st != want {
+		t.Errorf("AllocStats() = %+v; want %+v", st, want)
+	}
+
+	msg.Reset(SingleSegment(nil))
+	if st := msg.AllocStats(); st.Allocs != 1 || st.AllocBytes != 8 {
+		t.Errorf("after Reset, Allocs, AllocBytes = %d, %d; want 1, 8", st.Allocs, st.AllocBytes)
+	}
+}
+
+func TestMessageCaps(t *testing.T) {
+	msg := &Message{Arena: SingleSegment(nil)}
+	if n := msg.NumCaps(); n != 0 {
+		t.Errorf("NumCaps() on new message = %d; want 0", n)
+	}
+	if caps := msg.Caps(); caps != nil {
+		t.Errorf("Caps() on new message = %v; want nil", caps)
+	}
+	c0, c1 := ErrorClient(errors.New("c0")), ErrorClient(errors.New("c1"))
+	msg.AddCap(c0)
+	msg.AddCap(c1)
+	if n := msg.NumCaps(); n != 2 {
+		t.Errorf("NumCaps() = %d; want 2", n)
+	}
+	caps := msg.Caps()
+	if len(caps) != 2 || caps[0] != c0 || caps[1] != c1 {
+		t.Fatalf("Caps() = %v; want [%v %v]", caps, c0, c1)
+	}
+	caps[0] = nil
+	if msg.CapTable[0] != c0 {
+		t.Error("modifying the slice returned by Caps changed the message's table")
+	}
+}
+
+func TestMessageCapabilities(t *testing.T) {
+	// The first segment only has room for the root pointer, so the walk
+	// has to start from a far pointer.
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg = root.Segment()
+	if err := root.SetPointer(0, NewInterface(seg, 5)); err != nil {
+		t.Fatal(err)
+	}
+	inner, err := NewStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.SetPointer(0, NewInterface(seg, 7)); err != nil {
+		t.Fatal(err)
+	}
+	if err := inner.SetPointer(1, NewInterface(seg, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, inner); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Struct(0).SetPointer(0, NewInterface(seg, 9)); err != nil {
+		t.Fatal(err)
+	}
+	caps, err := NewPointerList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := caps.Set(1, NewInterface(seg, 11)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Struct(1).SetPointer(0, caps); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(2, l); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	want := []CapabilityID{5, 7, 9, 11}
+	for _, m := range []*Message{msg, mustUnmarshal(t, data)} {
+		ids, err := m.Capabilities()
+		if err != nil {
+			t.Error("Capabilities:", err)
+			continue
+		}
+		if !reflect.DeepEqual(ids, want) {
+			t.Errorf("Capabilities() = %v; want %v", ids, want)
+		}
+	}
+}
+
+func TestMessageCapabilitiesShared(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.SetPointer(0, NewInterface(seg, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.SetRoot(shareStruct(t, leaf, 60)); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := msg.Capabilities()
+	if err != nil {
+		t.Fatal("Capabilities:", err)
+	}
+	if want := []CapabilityID{3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("Capabilities() = %v; want %v", ids, want)
+	}
+}
+
+func TestMarshalInto(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xdeadbeef)
+	want, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	buf := make([]byte, len(want)+5)
+	n, ok, err := msg.MarshalInto(buf)
+	if err != nil || !ok || n != len(want) {
+		t.Fatalf("MarshalInto(%d bytes) = %d, %t, %v; want %d, true, <nil>", len(buf), n, ok, err, len(want))
+	}
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("MarshalInto wrote % x; want % x", buf[:n], want)
+	}
+
+	buf = make([]byte, len(want))
+	if n, ok, err := msg.MarshalInto(buf); err != nil || !ok || n != len(want) {
+		t.Errorf("MarshalInto(exact size) = %d, %t, %v; want %d, true, <nil>", n, ok, err, len(want))
+	}
+
+	short := make([]byte, len(want)-1)
+	if n, ok, err := msg.MarshalInto(short); err != nil || ok || n != 0 {
+		t.Errorf("MarshalInto(short) = %d, %t, %v; want 0, false, <nil>", n, ok, err)
+	}
+	if !bytes.Equal(short, make([]byte, len(short))) {
+		t.Error("MarshalInto(short) modified the buffer")
+	}
+
+	empty := &Message{Arena: MultiSegment([][]byte{})}
+	if _, ok, err := empty.MarshalInto(make([]byte, 64)); err == nil || ok {
+		t.Errorf("MarshalInto on empty message = _, %t, %v; want false, error", ok, err)
+	}
+}
+
+func TestMarshalTo(t *testing.T) {
+	msg, seg, err := NewMessage(MultiSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xdeadbeef)
+	want, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	prefix := []byte("abc")
+	buf := make([]byte, len(prefix), len(prefix)+len(want))
+	copy(buf, prefix)
+	out, err := msg.MarshalTo(buf)
+	if err != nil {
+		t.Fatal("MarshalTo:", err)
+	}
+	if !bytes.Equal(out, append(prefix, want...)) {
+		t.Errorf("MarshalTo(%q) = % x; want prefix followed by % x", prefix, out, want)
+	}
+	if &out[0] != &buf[0] {
+		t.Error("MarshalTo allocated even though the buffer had enough capacity")
+	}
+
+	out, err = msg.MarshalTo(prefix[:len(prefix):len(prefix)])
+	if err != nil {
+		t.Fatal("MarshalTo(full buffer):", err)
+	}
+	if !bytes.Equal(out, append([]byte("abc"), want...)) {
+		t.Errorf("MarshalTo(full buffer) = % x; want prefix followed by % x", out, want)
+	}
+
+	empty := &Message{Arena: MultiSegment([][]byte{})}
+	if out, err := empty.MarshalTo(prefix); err == nil || !bytes.Equal(out, prefix) {
+		t.Errorf("MarshalTo on empty message = %q, %v; want %q, error", out, err, prefix)
+	}
+}
+
+func TestTraversalLimit(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := NewUInt64List(seg, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, list); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, NewVoidList(seg, 100)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root costs 2 words and the list 4 words.
+	msg.TraversalLimit = 8
+	p, err := msg.Root()
+	if err != nil {
+		t.Fatal("Root:", err)
+	}
+	root = ToStruct(p)
+	if _, err := root.Pointer(0); err != nil {
+		t.Fatal("first read of list:", err)
+	}
+	if _, err := root.Pointer(0); err != errTraversalLimit {
+		t.Errorf("second read of list error = %v; want %v", err, errTraversalLimit)
+	}
+	if _, err := msg.Root(); err != errTraversalLimit {
+		t.Errorf("Root after limit error = %v; want %v", err, errTraversalLimit)
+	}
+
+	msg.Reset(msg.Arena)
+	msg.TraversalLimit = 99
+	p, err = msg.Root()
+	if err != nil {
+		t.Fatal("Root after Reset:", err)
+	}
+	if _, err := ToStruct(p).Pointer(1); err != errTraversalLimit {
+		t.Errorf("reading 100-element void list with 97 words left: error = %v; want %v", err, errTraversalLimit)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	// The root is in a different segment from its pointer, so the walk
+	// starts at a far pointer.
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := msg.MaxDepth(); err != nil || d != 0 {
+		t.Errorf("MaxDepth() with null root = %d, %v; want 0, <nil>", d, err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err := msg.MaxDepth(); err != nil || d != 1 {
+		t.Errorf("MaxDepth() with empty root = %d, %v; want 1, <nil>", d, err)
+	}
+	seg = root.Segment()
+
+	// root -> list -> element's struct -> text: depth 4
+	l, err := NewCompositeList(seg, ObjectSize{PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "deep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := leaf.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Struct(1).SetPointer(0, leaf); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, l); err != nil {
+		t.Fatal(err)
+	}
+	// A shared object and an interface do not change the depth.
+	if err := root.SetPointer(1, leaf); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(2, NewInterface(seg, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+	for _, m := range []*Message{msg, mustUnmarshal(t, data)} {
+		if d, err := m.MaxDepth(); err != nil || d != 4 {
+			t.Errorf("MaxDepth() = %d, %v; want 4, <nil>", d, err)
+		}
+	}
+
+	// A struct whose only pointer points back at itself.
+	cyclic := &Message{Arena: SingleSegment([]byte{
+		0, 0, 0, 0, 0, 0, 1, 0,
+		0xfc, 0xff, 0xff, 0xff, 0, 0, 1, 0,
+	})}
+	if _, err := cyclic.MaxDepth(); err == nil {
+		t.Error("MaxDepth() on cyclic message succeeded")
+	}
+
+	// A chain of structs is allowed up to maxSizeDepth levels.
+	for _, levels := range []int{maxSizeDepth, maxSizeDepth + 1} {
+		msg, seg, err := NewMessage(SingleSegment(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := NewRootStruct(seg, ObjectSize{PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i < levels; i++ {
+			next, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetPointer(0, next); err != nil {
+				t.Fatal(err)
+			}
+			s = next
+		}
+		d, err := msg.MaxDepth()
+		if levels <= maxSizeDepth && (err != nil || d != levels) {
+			t.Errorf("MaxDepth() of %d-level chain = %d, %v; want %d, <nil>", levels, d, err, levels)
+		} else if levels > maxSizeDepth && err != errMaxDepth {
+			t.Errorf("MaxDepth() of %d-level chain = %d, %v; want error %v", levels, d, err, errMaxDepth)
+		}
+	}
+}
+
+func TestCloneSegments(t *testing.T) {
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xfeed)
+	txt, err := NewText(root.Segment(), "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if n := msg.NumSegments(); n < 2 {
+		t.Fatalf("msg.NumSegments() = %d; want at least 2", n)
+	}
+	want, err := msg.Marshal()
+	if err != nil {
+		t.Fatal("Marshal:", err)
+	}
+
+	clone, err := msg.CloneSegments()
+	if err != nil {
+		t.Fatal("CloneSegments:", err)
+	}
+	if clone.NumSegments() != msg.NumSegments() {
+		t.Errorf("clone.NumSegments() = %d; want %d", clone.NumSegments(), msg.NumSegments())
+	}
+	got, err := clone.Marshal()
+	if err != nil {
+		t.Fatal("clone.Marshal:", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("clone.Marshal() =\n% x\nwant\n% x", got, want)
+	}
+
+	p, err := clone.Root()
+	if err != nil {
+		t.Fatal("clone.Root:", err)
+	}
+	croot := ToStruct(p)
+	croot.SetUint64(0, 0xbeef)
+	if err := croot.SetPointer(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if root.Uint64(0) != 0xfeed || !root.HasPointer(0) {
+		t.Error("modifying the clone changed the original message")
+	}
+	if _, err := NewStruct(croot.Segment(), ObjectSize{DataSize: 64}); err != nil {
+		t.Error("allocating in clone:", err)
+	}
+	if after, _ := msg.Marshal(); !bytes.Equal(after, want) {
+		t.Error("allocating in the clone changed the original message")
+	}
+}
+
+func TestClone(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xfeed)
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	c := ErrorClient(errors.New("c"))
+	if err := root.SetPointer(1, NewInterface(seg, msg.AddCap(c))); err != nil {
+		t.Fatal(err)
+	}
+
+	clone, err := msg.Clone()
+	if err != nil {
+		t.Fatal("Clone:", err)
+	}
+	if len(clone.CapTable) != 1 || clone.CapTable[0] != c {
+		t.Errorf("clone.CapTable = %v; want [%v]", clone.CapTable, c)
+	}
+	p, err := clone.Root()
+	if err != nil {
+		t.Fatal("clone.Root:", err)
+	}
+	croot := ToStruct(p)
+	if croot.Uint64(0) != 0xfeed {
+		t.Errorf("clone root data = %#x; want 0xfeed", croot.Uint64(0))
+	}
+	if p, err := croot.Pointer(0); err != nil || ToText(p) != "hello" {
+		t.Errorf("clone root text = %q, %v; want \"hello\"", ToText(p), err)
+	}
+	if p, err := croot.Pointer(1); err != nil || ToInterface(p).Client() != c {
+		t.Errorf("clone root capability = %v, %v; want %v", ToInterface(p).Client(), err, c)
+	}
+
+	croot.SetUint64(0, 0xbeef)
+	if err := croot.SetPointer(0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if root.Uint64(0) != 0xfeed || !root.HasPointer(0) {
+		t.Error("modifying the clone changed the original message")
+	}
+	if _, err := NewStruct(croot.Segment(), ObjectSize{DataSize: 64}); err != nil {
+		t.Error("allocating in clone:", err)
+	}
+}
+
+func TestUnmarshalSegments(t *testing.T) {
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 0xdeadbeef)
+	plan, err := msg.SegmentsForOutput()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := plan.AppendHeader(nil)
+	segs := make([][]byte, len(plan.SegmentSizes))
+	for i := range segs {
+		s, err := msg.Segment(SegmentID(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		segs[i] = append([]byte(nil), s.data...)
+	}
+
+	got, err := UnmarshalSegments(header, segs)
+	if err != nil {
+		t.Fatal("UnmarshalSegments:", err)
+	}
+	p, err := got.Root()
+	if err != nil {
+		t.Fatal("Root:", err)
+	}
+	if v := ToStruct(p).Uint64(0); v != 0xdeadbeef {
+		t.Errorf("root.Uint64(0) = %#x; want 0xdeadbeef", v)
+	}
+	s1, err := got.Segment(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &s1.data[0] != &segs[1][0] {
+		t.Error("UnmarshalSegments copied segment 1")
+	}
+
+	if _, err := UnmarshalSegments(header, segs[:1]); err == nil {
+		t.Error("UnmarshalSegments with too few segments succeeded")
+	}
+	short := [][]byte{segs[0], segs[1][:len(segs[1])-8]}
+	if _, err := UnmarshalSegments(header, short); err == nil {
+		t.Error("UnmarshalSegments with a short segment succeeded")
+	}
+	if _, err := UnmarshalSegments(append(header, 0, 0, 0, 0, 0, 0, 0, 0), segs); err == nil {
+		t.Error("UnmarshalSegments with trailing header bytes succeeded")
+	}
+}
+
+func TestNewDefaultArena(t *testing.T) {
+	defer func(f func() Arena) { NewDefaultArena = f }(NewDefaultArena)
+	var arenas int
+	NewDefaultArena = func() Arena {
+		arenas++
+		return SingleSegment(make([]byte, 0, 64))
+	}
+	call := &Call{
+		ParamsSize: ObjectSize{DataSize: 8},
+		ParamsFunc: func(s Struct) error {
+			s.SetUint64(0, 42)
+			return nil
+		},
+	}
+	p, err := call.PlaceParams(nil)
+	if err != nil {
+		t.Fatal("PlaceParams:", err)
+	}
+	if arenas != 1 {
+		t.Errorf("PlaceParams(nil) called NewDefaultArena %d times; want 1", arenas)
+	}
+	if p.Uint64(0) != 42 {
+		t.Errorf("params.Uint64(0) = %d; want 42", p.Uint64(0))
+	}
+	if data, _ := p.Segment().Message().Arena.Data(0); cap(data) != 64 {
+		t.Errorf("params segment capacity = %d; want 64 from the injected arena", cap(data))
+	}
+}
+
+func TestMessageEqual(t *testing.T) {
+	// build writes the same content into arena, allocating the text
+	// before or after the struct that points to it.
+	build := func(arena Arena, textFirst bool, val uint64) *Message {
+		msg, seg, err := NewMessage(arena)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		seg = root.Segment()
+		root.SetUint64(0, val)
+		var txt Pointer
+		if textFirst {
+			if txt, err = NewText(seg, "hello"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		child, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !textFirst {
+			if txt, err = NewText(seg, "hello"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := child.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		if err := root.SetPointer(1, child); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	a := build(SingleSegment(nil), false, 42)
+	b := build(MultiSegment([][]byte{make([]byte, 0, 8)}), true, 42)
+	if eq, err := a.Equal(b); err != nil || !eq {
+		t.Errorf("a.Equal(b) = %t, %v; want true, <nil>", eq, err)
+	}
+	if eq, err := b.Equal(a); err != nil || !eq {
+		t.Errorf("b.Equal(a) = %t, %v; want true, <nil>", eq, err)
+	}
+	c := build(SingleSegment(nil), false, 43)
+	if eq, err := a.Equal(c); err != nil || eq {
+		t.Errorf("a.Equal(c) = %t, %v; want false, <nil>", eq, err)
+	}
+
+	cyclic := &Message{Arena: SingleSegment([]byte{
+		0, 0, 0, 0, 0, 0, 1, 0,
+		0xfc, 0xff, 0xff, 0xff, 0, 0, 1, 0,
+	})}
+	if _, err := cyclic.Equal(cyclic); err == nil {
+		t.Error("Equal on cyclic message succeeded")
+	}
+}
+
+func TestMessageCompact(t *testing.T) {
+	msg, seg, err := NewMessage(MultiSegment([][]byte{make([]byte, 0, 8)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 42)
+	seg = root.Segment()
+	if _, err := NewText(seg, "orphaned"); err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(1, NewInterface(seg, msg.AddCap(nil))); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := msg.Compact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := c.NumSegments(); n != 1 {
+		t.Errorf("Compact().NumSegments() = %d; want 1", n)
+	}
+	if eq, err := msg.Equal(c); err != nil || !eq {
+		t.Errorf("msg.Equal(Compact()) = %t, %v; want true, <nil>", eq, err)
+	}
+	if len(c.CapTable) != len(msg.CapTable) {
+		t.Errorf("len(Compact().CapTable) = %d; want %d", len(c.CapTable), len(msg.CapTable))
+	}
+	before, err := msg.TotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := c.TotalSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Errorf("Compact().TotalSize() = %d; want less than %d", after, before)
+	}
+}
+
+func TestPooledArena(t *testing.T) {
+	var made int
+	pool := &sync.Pool{New: func() interface{} {
+		made++
+		return make([]byte, 0, 1024)
+	}}
+	arena := NewPooledArena(pool)
+	msg, seg, err := NewMessage(arena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := NewData(root.Segment(), make([]byte, 2*defaultBufferSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.SetPointer(0, big); err != nil {
+		t.Fatal(err)
+	}
+	if n := msg.NumSegments(); n < 2 {
+		t.Fatalf("NumSegments() = %d; want at least 2", n)
+	}
+	if made == 0 {
+		t.Error("arena did not take buffers from the pool")
+	}
+
+	msg.Release()
+	if n := arena.NumSegments(); n != 0 {
+		t.Errorf("arena.NumSegments() after Release = %d; want 0", n)
+	}
+	var returned [][]byte
+	pool.New = nil
+	for {
+		buf, ok := pool.Get().([]byte)
+		if !ok {
+			break
+		}
+		if cap(buf) >= defaultBufferSize {
+			returned = append(returned, buf)
+		}
+	}
+	if len(returned) < 2 {
+		t.Errorf("pool holds %d segment buffers after Release; want at least 2", len(returned))
+	}
+	for _, buf := range returned {
+		if len(buf) != 0 {
+			t.Errorf("pool buffer has length %d after Release; want 0", len(buf))
+		}
+		pool.Put(buf)
+	}
+
+	msg.Reset(arena)
+	seg, err = msg.Segment(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err = NewRootStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.SetUint64(0, 42)
+	if p, err := msg.Root(); err != nil || ToStruct(p).Uint64(0) != 42 {
+		t.Errorf("root after Release and Reset = %v, %v; want 42", ToStruct(p).Uint64(0), err)
+	}
+}
@@ -1,5 +1,7 @@
 package capnp
 
+import "errors"
+
 // A Pointer is a reference to a Cap'n Proto struct, list, or interface.
 type Pointer interface {
 	// Segment returns the segment this pointer points into.
@@ -57,3 +59,326 @@ func pointerAddress(p Pointer) Address {
 	a := p.underlying().(addresser)
 	return a.Address()
 }
+
+// pointerTypeName returns a human-readable name for the kind of object
+// p references.
+func pointerTypeName(p Pointer) string {
+	switch p.underlying().(type) {
+	case Struct:
+		return "struct"
+	case List:
+		return "list"
+	case Interface:
+		return "interface"
+	default:
+		return "unknown"
+	}
+}
+
+// newPointerTypeError returns an error describing that p is not
+// a pointer of the wanted kind.
+func newPointerTypeError(want string, p Pointer) error {
+	return errors.New("capnp: expected " + want + " pointer, got " + pointerTypeName(p) + " pointer")
+}
+
+// maxSizeDepth is the deepest nesting that reachableSize,
+// Capabilities, and Dump will follow.
+const maxSizeDepth = 64
+
+// sumSize returns a+b, or errOverlarge if the sum is larger than a
+// single segment can hold.  Shared objects are counted once per
+// pointer, so the sizes of a small message can grow exponentially with
+// its depth; checking each sum keeps the total from wrapping.
+func sumSize(a, b uint64) (uint64, error) {
+	if a > uint64(maxSize) || b > uint64(maxSize)-a {
+		return 0, errOverlarge
+	}
+	return a + b, nil
+}
+
+// reachableSize returns the number of bytes that p and every object
+// reachable from it would occupy if p were copied into a new message.
+// The pointer to p itself and far pointer landing pads are not counted.
+// Objects that are referenced more than once are counted each time,
+// just as a copy would duplicate them, but are only walked once.
+func reachableSize(p Pointer) (uint64, error) {
+	w := &sizeWalker{memo: make(map[objectKey]uint64)}
+	return w.size(p, 0)
+}
+
+// A sizeWalker computes the reachable size of objects.
+type sizeWalker struct {
+	// memo holds the size of objects that have been fully walked.
+	memo map[objectKey]uint64
+}
+
+// size returns the reachable size of p, which is depth pointers below
+// the start of the walk.
+func (w *sizeWalker) size(p Pointer, depth int) (uint64, error) {
+	if !IsValid(p) {
+		return 0, nil
+	}
+	if depth >= maxSizeDepth {
+		return 0, errSizeDepth
+	}
+	switch p := p.underlying().(type) {
+	case Struct:
+		if p.size.PointerCount == 0 {
+			return uint64(p.size.totalSize()), nil
+		}
+		return w.object(objectKey{p.seg.id, p.off, false}, func() (uint64, error) {
+			n, err := w.structPointers(p, depth)
+			if err != nil {
+				return 0, err
+			}
+			return sumSize(uint64(p.size.totalSize()), n)
+		})
+	case List:
+		n := int32(p.Len())
+		switch {
+		case p.flags&isBitList != 0:
+			return uint64(n+63) / 64 * uint64(wordSize), nil
+		case p.flags&isCompositeList != 0 || p.size.PointerCount > 0:
+			// Composite and pointer lists: measure each element's
+			// pointer section as if it were a struct.
+			return w.object(objectKey{p.seg.id, p.off, true}, func() (uint64, error) {
+				total := uint64(p.size.totalSize()) * uint64(n)
+				if p.flags&isCompositeList != 0 {
+					total += uint64(wordSize) // tag word
+				}
+				for i := 0; i < int(n); i++ {
+					sub, err := w.structPointers(p.Struct(i), depth)
+					if err != nil {
+						return 0, err
+					}
+					if total, err = sumSize(total, sub); err != nil {
+						return 0, err
+					}
+				}
+				return total, nil
+			})
+		default:
+			return uint64(p.size.DataSize.times(n).padToWord()), nil
+		}
+	default:
+		// Interfaces only occupy their pointer.
+		return 0, nil
+	}
+}
+
+// object returns the size of the object k, computing it with f if k
+// has not been walked yet.
+func (w *sizeWalker) object(k objectKey, f func() (uint64, error)) (uint64, error) {
+	if n, ok := w.memo[k]; ok {
+		return n, nil
+	}
+	n, err := f()
+	if err != nil {
+		return 0, err
+	}
+	w.memo[k] = n
+	return n, nil
+}
+
+// structPointers returns the total reachable size of the objects
+// referenced by s's pointer section.
+func (w *sizeWalker) structPointers(s Struct, depth int) (uint64, error) {
+	var total uint64
+	for i := uint16(0); i < s.size.PointerCount; i++ {
+		p, err := s.Pointer(i)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.size(p, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		if total, err = sumSize(total, n); err != nil {
+			return 0, err
+		}
+	}
+	return total, nil
+}
+
+// A pointerSlot is the location of a pointer in a segment.
+type pointerSlot struct {
+	seg  *Segment
+	addr Address
+}
+
+// A capWalker collects the interface pointers reachable from a pointer.
+type capWalker struct {
+	// visited holds the objects that have been walked, so that objects
+	// referenced more than once are only walked once.
+	visited map[objectKey]struct{}
+	// seen holds the IDs in ids.
+	seen map[CapabilityID]struct{}
+	ids  []CapabilityID
+	// slots holds the location of every interface pointer found in a
+	// struct or list, so that they can be cleared after the walk.
+	slots []pointerSlot
+}
+
+func newCapWalker() *capWalker {
+	return &capWalker{
+		visited: make(map[objectKey]struct{}),
+		seen:    make(map[CapabilityID]struct{}),
+	}
+}
+
+// walk appends the capability ID of every interface pointer reachable
+// from p to w.ids, skipping any ID already found.
+func (w *capWalker) walk(p Pointer, depth int) error {
+	if !IsValid(p) {
+		return nil
+	}
+	if depth >= maxSizeDepth {
+		return errCapsDepth
+	}
+	switch p := p.underlying().(type) {
+	case Interface:
+		if _, dup := w.seen[p.cap]; !dup {
+			w.seen[p.cap] = struct{}{}
+			w.ids = append(w.ids, p.cap)
+		}
+	case Struct:
+		if !w.visit(objectKey{p.seg.id, p.off, false}) {
+			return nil
+		}
+		return w.structPointers(p, depth)
+	case List:
+		if p.flags&isBitList != 0 || p.size.PointerCount == 0 {
+			return nil
+		}
+		if !w.visit(objectKey{p.seg.id, p.off, true}) {
+			return nil
+		}
+		for i := 0; i < p.Len(); i++ {
+			if err := w.structPointers(p.Struct(i), depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// visit marks k as visited, reporting false if it already was.
+func (w *capWalker) visit(k objectKey) bool {
+	if _, ok := w.visited[k]; ok {
+		return false
+	}
+	w.visited[k] = struct{}{}
+	return true
+}
+
+// structPointers walks each of s's pointers.
+func (w *capWalker) structPointers(s Struct, depth int) error {
+	for i := uint16(0); i < s.size.PointerCount; i++ {
+		p, err := s.Pointer(i)
+		if err != nil {
+			return err
+		}
+		if IsValid(ToInterface(p)) {
+			w.slots = append(w.slots, pointerSlot{s.seg, s.pointerAddress(i)})
+		}
+		if err := w.walk(p, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// A depthWalker computes the pointer nesting depth of objects.
+type depthWalker struct {
+	// onPath holds the objects between the root and the current one.
+	onPath map[objectKey]struct{}
+	// memo holds the depth of objects that have been fully walked, so
+	// that objects referenced more than once are only walked once.
+	memo map[objectKey]int
+}
+
+// objectKey identifies an object with pointers in a message.
+type objectKey struct {
+	seg    SegmentID
+	addr   Address
+	isList bool
+}
+
+// depth returns the number of pointers that must be followed from p,
+// including p itself, to reach the deepest object reachable from it.
+// Far pointers are resolved transparently and do not add depth.  An
+// interface pointer does not refer to an object, so it has depth zero.
+func (w *depthWalker) depth(p Pointer) (int, error) {
+	if !IsValid(p) {
+		return 0, nil
+	}
+	switch p := p.underlying().(type) {
+	case Struct:
+		if p.size.PointerCount == 0 {
+			return 1, nil
+		}
+		return w.object(objectKey{p.seg.id, p.off, false}, func() (int, error) {
+			return w.structDepth(p)
+		})
+	case List:
+		if p.flags&isBitList != 0 || p.size.PointerCount == 0 {
+			return 1, nil
+		}
+		return w.object(objectKey{p.seg.id, p.off, true}, func() (int, error) {
+			max := 0
+			for i := 0; i < p.Len(); i++ {
+				d, err := w.structDepth(p.Struct(i))
+				if err != nil {
+					return 0, err
+				}
+				if d > max {
+					max = d
+				}
+			}
+			return max, nil
+		})
+	default:
+		return 0, nil
+	}
+}
+
+// object returns one more than the depth below the object k, computing
+// it with f if k has not been walked yet.
+func (w *depthWalker) object(k objectKey, f func() (int, error)) (int, error) {
+	if d, ok := w.memo[k]; ok {
+		return d, nil
+	}
+	if _, ok := w.onPath[k]; ok {
+		return 0, errPointerCycle
+	}
+	if len(w.onPath) >= maxSizeDepth {
+		return 0, errMaxDepth
+	}
+	w.onPath[k] = struct{}{}
+	d, err := f()
+	delete(w.onPath, k)
+	if err != nil {
+		return 0, err
+	}
+	w.memo[k] = d + 1
+	return d + 1, nil
+}
+
+// structDepth returns the greatest depth of s's pointers.
+func (w *depthWalker) structDepth(s Struct) (int, error) {
+	max := 0
+	for i := uint16(0); i < s.size.PointerCount; i++ {
+		p, err := s.Pointer(i)
+		if err != nil {
+			return 0, err
+		}
+		d, err := w.depth(p)
+		if err != nil {
+			return 0, err
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max, nil
+}
@@ -5,11 +5,8 @@ type pointerOffset int32
 
 // resolve returns the absolute address, given that the pointer is located at paddr.
 func (off pointerOffset) resolve(paddr Address) (addr Address, ok bool) {
-	// TODO(light): verify
-	if off < 0 && Address(-off) > paddr {
-		return 0, false
-	}
-	return paddr + Address(off*8) + 8, true
+	// The offset is from the end of the pointer.
+	return paddr.addWords(int64(off) + 1)
 }
 
 // makePointerOffset computes the offset for a pointer at paddr to point to addr.
@@ -59,6 +56,33 @@ func landingPadNearPointer(far, tag rawPointer) rawPointer {
 	return tag | rawPointer(far.farAddress()-Address(wordSize))<<2
 }
 
+// relocate returns p re-encoded to be stored at to instead of from,
+// still referencing the same object.  Only near struct and list
+// pointers depend on where they are stored; other pointers are
+// returned unchanged.  Zero-sized struct pointers keep their offset of
+// -1 wherever they land, since recomputing it could yield a null
+// pointer.  ok is false if p's target is out of range.
+func (p rawPointer) relocate(from, to Address) (q rawPointer, ok bool) {
+	if p == 0 {
+		return 0, true
+	}
+	switch p.pointerType() {
+	case structPointer:
+		if p.structSize().isZero() {
+			return p, true
+		}
+		fallthrough
+	case listPointer:
+		addr, ok := p.offset().resolve(from)
+		if !ok {
+			return 0, false
+		}
+		return p&^(zerohi32&^3) | orable30BitOffsetPart(makePointerOffset(to, addr)), true
+	default:
+		return p, true
+	}
+}
+
 // Raw pointer types.
 const (
 	structPointer    = 0
@@ -230,3 +230,25 @@ func TestRawPointerTotalListSize(t *testing.T) {
 		}
 	}
 }
+
+func TestPointerOffsetResolve(t *testing.T) {
+	tests := []struct {
+		off   pointerOffset
+		paddr Address
+		addr  Address
+		ok    bool
+	}{
+		{0, 0, 8, true},
+		{-1, 0, 0, true},
+		{-2, 0, 0, false},
+		{2, 16, 40, true},
+		{-(1 << 29), 8, 0, false},
+		{1<<29 - 1, 8, 0, false},
+		{1, Address(maxSize) - 15, 0, false},
+	}
+	for _, test := range tests {
+		if addr, ok := test.off.resolve(test.paddr); addr != test.addr || ok != test.ok {
+			t.Errorf("pointerOffset(%d).resolve(%#v) = %#v, %t; want %#v, %t", test.off, test.paddr, addr, ok, test.addr, test.ok)
+		}
+	}
+}
@@ -63,8 +63,27 @@ var (
 	errShutdown        = errors.New("rpc: shutdown")
 	errCallCanceled    = errors.New("rpc: call canceled")
 	errUnimplemented   = errors.New("rpc: remote used unimplemented protocol feature")
+	errDraining        = errors.New("rpc: connection is shutting down")
 )
 
+// A ShutdownError is returned by Conn.Shutdown when its context is
+// done before the calls in flight return.
+type ShutdownError struct {
+	// Questions holds the methods of the outgoing calls that had not
+	// been answered.  Bootstrap questions are listed as nil.
+	Questions []*capnp.Method
+
+	// Answers is the number of incoming calls that had not returned.
+	Answers int
+
+	// Err is the context's error.
+	Err error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("rpc: shutdown abandoned %d outgoing and %d incoming calls: %v", len(e.Questions), e.Answers, e.Err)
+}
+
 type bootstrapError struct {
 	err error
 }
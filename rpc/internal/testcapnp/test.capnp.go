@@ -133,6 +133,11 @@ func (s HandleFactory_newHandle_Params_List) Set(i int, v HandleFactory_newHandl
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HandleFactory_newHandle_Params) CopyFrom(src HandleFactory_newHandle_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HandleFactory_newHandle_Params_Promise is a wrapper for a HandleFactory_newHandle_Params promised by a client call.
 type HandleFactory_newHandle_Params_Promise struct{ *capnp.Pipeline }
 
@@ -178,6 +183,10 @@ func (s HandleFactory_newHandle_Results) Handle() Handle {
 	return Handle{Client: c}
 }
 
+func (s HandleFactory_newHandle_Results) HasHandle() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s HandleFactory_newHandle_Results) SetHandle(v Handle) error {
 
 	seg := s.Segment()
@@ -208,6 +217,11 @@ func (s HandleFactory_newHandle_Results_List) Set(i int, v HandleFactory_newHand
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s HandleFactory_newHandle_Results) CopyFrom(src HandleFactory_newHandle_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // HandleFactory_newHandle_Results_Promise is a wrapper for a HandleFactory_newHandle_Results promised by a client call.
 type HandleFactory_newHandle_Results_Promise struct{ *capnp.Pipeline }
 
@@ -327,6 +341,11 @@ func (s Hanger_hang_Params_List) Set(i int, v Hanger_hang_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hanger_hang_Params) CopyFrom(src Hanger_hang_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Hanger_hang_Params_Promise is a wrapper for a Hanger_hang_Params promised by a client call.
 type Hanger_hang_Params_Promise struct{ *capnp.Pipeline }
 
@@ -381,6 +400,11 @@ func (s Hanger_hang_Results_List) Set(i int, v Hanger_hang_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Hanger_hang_Results) CopyFrom(src Hanger_hang_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Hanger_hang_Results_Promise is a wrapper for a Hanger_hang_Results promised by a client call.
 type Hanger_hang_Results_Promise struct{ *capnp.Pipeline }
 
@@ -505,6 +529,11 @@ func (s CallOrder_getCallSequence_Params_List) Set(i int, v CallOrder_getCallSeq
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CallOrder_getCallSequence_Params) CopyFrom(src CallOrder_getCallSequence_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // CallOrder_getCallSequence_Params_Promise is a wrapper for a CallOrder_getCallSequence_Params promised by a client call.
 type CallOrder_getCallSequence_Params_Promise struct{ *capnp.Pipeline }
 
@@ -568,6 +597,11 @@ func (s CallOrder_getCallSequence_Results_List) Set(i int, v CallOrder_getCallSe
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CallOrder_getCallSequence_Results) CopyFrom(src CallOrder_getCallSequence_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // CallOrder_getCallSequence_Results_Promise is a wrapper for a CallOrder_getCallSequence_Results promised by a client call.
 type CallOrder_getCallSequence_Results_Promise struct{ *capnp.Pipeline }
 
@@ -710,6 +744,10 @@ func (s Echoer_echo_Params) Cap() CallOrder {
 	return CallOrder{Client: c}
 }
 
+func (s Echoer_echo_Params) HasCap() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Echoer_echo_Params) SetCap(v CallOrder) error {
 
 	seg := s.Segment()
@@ -740,6 +778,11 @@ func (s Echoer_echo_Params_List) Set(i int, v Echoer_echo_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Echoer_echo_Params) CopyFrom(src Echoer_echo_Params) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Echoer_echo_Params_Promise is a wrapper for a Echoer_echo_Params promised by a client call.
 type Echoer_echo_Params_Promise struct{ *capnp.Pipeline }
 
@@ -789,6 +832,10 @@ func (s Echoer_echo_Results) Cap() CallOrder {
 	return CallOrder{Client: c}
 }
 
+func (s Echoer_echo_Results) HasCap() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Echoer_echo_Results) SetCap(v CallOrder) error {
 
 	seg := s.Segment()
@@ -819,6 +866,11 @@ func (s Echoer_echo_Results_List) Set(i int, v Echoer_echo_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Echoer_echo_Results) CopyFrom(src Echoer_echo_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Echoer_echo_Results_Promise is a wrapper for a Echoer_echo_Results promised by a client call.
 type Echoer_echo_Results_Promise struct{ *capnp.Pipeline }
 
@@ -954,6 +1006,9 @@ func (s Adder_add_Params_List) Set(i int, v Adder_add_Params) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Adder_add_Params) CopyFrom(src Adder_add_Params) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Adder_add_Params_Promise is a wrapper for a Adder_add_Params promised by a client call.
 type Adder_add_Params_Promise struct{ *capnp.Pipeline }
 
@@ -1017,6 +1072,11 @@ func (s Adder_add_Results_List) Set(i int, v Adder_add_Results) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Adder_add_Results) CopyFrom(src Adder_add_Results) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // Adder_add_Results_Promise is a wrapper for a Adder_add_Results promised by a client call.
 type Adder_add_Results_Promise struct{ *capnp.Pipeline }
 
@@ -193,9 +193,24 @@ func transformsEqual(t, u []capnp.PipelineOp) bool {
 	return true
 }
 
-func (q *question) Struct() (capnp.Struct, error) {
-	<-q.resolved
+// wait blocks until the question is resolved or the connection shuts
+// down, then returns the question's result.
+func (q *question) wait() (capnp.Pointer, error) {
+	select {
+	case <-q.resolved:
+	case <-q.manager.finish:
+		// The connection shut down before the question was answered.
+		if _, obj, err, ok := q.peek(); ok {
+			return obj, err
+		}
+		return nil, q.manager.err()
+	}
 	_, obj, err, _ := q.peek()
+	return obj, err
+}
+
+func (q *question) Struct() (capnp.Struct, error) {
+	obj, err := q.wait()
 	return capnp.ToStruct(obj), err
 }
 
@@ -219,8 +234,7 @@ func (q *question) PipelineCall(transform []capnp.PipelineOp, ccall *capnp.Call)
 }
 
 func (q *question) PipelineClose(transform []capnp.PipelineOp) error {
-	<-q.resolved
-	_, obj, err, _ := q.peek()
+	obj, err := q.wait()
 	if err != nil {
 		return err
 	}
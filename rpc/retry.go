@@ -0,0 +1,224 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2"
+	"zombiezen.com/go/capnproto2/internal/fulfiller"
+)
+
+// A Dialer opens a new connection to a remote vat.
+type Dialer func(ctx context.Context) (*Conn, error)
+
+// idempotentKey is the call option key set by Idempotent.
+type idempotentKey struct{}
+
+// Idempotent returns a call option that marks a call as safe to issue
+// more than once.  Only idempotent calls are retried by a
+// RetryingClient.
+func Idempotent() capnp.CallOption {
+	return capnp.SetOptionValue(idempotentKey{}, true)
+}
+
+func isIdempotent(opts capnp.CallOptions) bool {
+	v, _ := opts.Value(idempotentKey{}).(bool)
+	return v
+}
+
+type retryParams struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// A RetryOption is an option for a RetryingClient.
+type RetryOption struct {
+	f func(*retryParams)
+}
+
+// RetryAttempts sets the maximum number of times an idempotent call is
+// tried, including the first attempt.  The default is 3.
+func RetryAttempts(n int) RetryOption {
+	return RetryOption{func(p *retryParams) {
+		p.attempts = n
+	}}
+}
+
+// RetryBackoff sets the wait before the first retry and the maximum
+// wait between retries.  The wait doubles after each failed retry until
+// it reaches max.  The defaults are 100ms and 5s.
+func RetryBackoff(initial, max time.Duration) RetryOption {
+	return RetryOption{func(p *retryParams) {
+		p.backoff = initial
+		p.maxBackoff = max
+	}}
+}
+
+// A RetryingClient is a client for a remote vat's bootstrap interface
+// that reconnects when the connection fails.  Calls marked with the
+// Idempotent option that fail because the connection was lost are
+// re-issued on a new connection.  Other calls, and calls that fail
+// with an application error, are not retried.
+type RetryingClient struct {
+	dial Dialer
+	p    retryParams
+
+	mu     sync.Mutex
+	conn   *Conn
+	client capnp.Client
+	closed bool
+}
+
+// NewRetryingClient returns a client that opens connections with dial.
+// No connection is made until the first call.
+func NewRetryingClient(dial Dialer, options ...RetryOption) *RetryingClient {
+	rc := &RetryingClient{
+		dial: dial,
+		p: retryParams{
+			attempts:   3,
+			backoff:    100 * time.Millisecond,
+			maxBackoff: 5 * time.Second,
+		},
+	}
+	for _, o := range options {
+		o.f(&rc.p)
+	}
+	if rc.p.attempts < 1 {
+		rc.p.attempts = 1
+	}
+	return rc
+}
+
+// Call starts a call on the current connection's bootstrap interface,
+// dialing a new connection if necessary.
+func (rc *RetryingClient) Call(call *capnp.Call) capnp.Answer {
+	if !isIdempotent(call.Options) {
+		_, client, err := rc.connect(call.Ctx)
+		if err != nil {
+			return capnp.ErrorAnswer(err)
+		}
+		return client.Call(call)
+	}
+	f := new(fulfiller.Fulfiller)
+	go rc.retry(call, f)
+	return f
+}
+
+// retry makes call until it succeeds, fails with an error other than a
+// connection failure, or runs out of attempts, then resolves f with the
+// result.
+func (rc *RetryingClient) retry(call *capnp.Call, f *fulfiller.Fulfiller) {
+	wait := rc.p.backoff
+	for i := 1; ; i++ {
+		conn, client, err := rc.connect(call.Ctx)
+		if err == nil {
+			var s capnp.Struct
+			s, err = client.Call(call).Struct()
+			if err == nil {
+				f.Fulfill(s)
+				return
+			}
+			if !conn.isConnError(err) {
+				f.Reject(err)
+				return
+			}
+			rc.drop(conn)
+		}
+		if i >= rc.p.attempts {
+			f.Reject(err)
+			return
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-call.Ctx.Done():
+			t.Stop()
+			f.Reject(call.Ctx.Err())
+			return
+		}
+		if wait *= 2; wait > rc.p.maxBackoff {
+			wait = rc.p.maxBackoff
+		}
+	}
+}
+
+// connect returns the current connection and its bootstrap client,
+// dialing a new connection if there is none or the previous one has
+// failed.
+func (rc *RetryingClient) connect(ctx context.Context) (*Conn, capnp.Client, error) {
+	rc.mu.Lock()
+	if rc.closed {
+		rc.mu.Unlock()
+		return nil, nil, ErrConnClosed
+	}
+	if rc.conn != nil && !rc.conn.isClosed() {
+		conn, client := rc.conn, rc.client
+		rc.mu.Unlock()
+		return conn, client, nil
+	}
+	rc.mu.Unlock()
+
+	conn, err := rc.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The bootstrap client outlives this call, so it must not be bound
+	// to the call's context.
+	client := conn.Bootstrap(context.Background())
+	if conn.isClosed() {
+		client.Close()
+		return nil, nil, conn.manager.err()
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		client.Close()
+		conn.Close()
+		return nil, nil, ErrConnClosed
+	}
+	if rc.conn != nil && !rc.conn.isClosed() {
+		// Another call connected first.
+		client.Close()
+		conn.Close()
+		return rc.conn, rc.client, nil
+	}
+	rc.clear()
+	rc.conn, rc.client = conn, client
+	return conn, client, nil
+}
+
+// drop discards conn if it is the current connection.
+func (rc *RetryingClient) drop(conn *Conn) {
+	rc.mu.Lock()
+	if rc.conn == conn {
+		rc.clear()
+	}
+	rc.mu.Unlock()
+}
+
+// clear closes the current connection.  The caller must be holding
+// rc.mu.
+func (rc *RetryingClient) clear() error {
+	if rc.conn == nil {
+		return nil
+	}
+	rc.client.Close()
+	err := rc.conn.Close()
+	rc.conn, rc.client = nil, nil
+	return err
+}
+
+// Close closes the current connection.  No further calls should be made
+// on the client after calling Close.
+func (rc *RetryingClient) Close() error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.closed {
+		return ErrConnClosed
+	}
+	rc.closed = true
+	return rc.clear()
+}
@@ -0,0 +1,192 @@
+package rpc_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+	"zombiezen.com/go/capnproto2/rpc"
+	"zombiezen.com/go/capnproto2/rpc/internal/logtransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/pipetransport"
+	"zombiezen.com/go/capnproto2/rpc/internal/testcapnp"
+	"zombiezen.com/go/capnproto2/server"
+)
+
+func TestRetryingClient(t *testing.T) {
+	ctx := context.Background()
+	hangup := make(chan struct{})
+	adder := &FlakyAdder{hangup: hangup}
+	d := &testDialer{adder: adder, hangup: hangup}
+	defer d.close()
+	rc := rpc.NewRetryingClient(d.dial, rpc.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+	client := testcapnp.Adder{Client: rc}
+
+	result, err := client.Add(ctx, func(p testcapnp.Adder_add_Params) error {
+		p.SetA(5)
+		p.SetB(2)
+		return nil
+	}, rpc.Idempotent()).Struct()
+	if err != nil {
+		t.Fatal("Add:", err)
+	}
+	if result.Result() != 7 {
+		t.Errorf("Add(5, 2) = %d; want 7", result.Result())
+	}
+	if n := d.numDials(); n != 2 {
+		t.Errorf("dialed %d times; want 2", n)
+	}
+}
+
+func TestRetryingClientNotIdempotent(t *testing.T) {
+	ctx := context.Background()
+	hangup := make(chan struct{})
+	adder := &FlakyAdder{hangup: hangup}
+	d := &testDialer{adder: adder, hangup: hangup}
+	defer d.close()
+	rc := rpc.NewRetryingClient(d.dial, rpc.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+	client := testcapnp.Adder{Client: rc}
+
+	_, err := client.Add(ctx, func(p testcapnp.Adder_add_Params) error {
+		p.SetA(5)
+		p.SetB(2)
+		return nil
+	}).Struct()
+	if err == nil {
+		t.Error("Add on disconnected connection succeeded")
+	}
+	if n := d.numDials(); n != 1 {
+		t.Errorf("dialed %d times; want 1", n)
+	}
+}
+
+func TestRetryingClientApplicationError(t *testing.T) {
+	ctx := context.Background()
+	d := &testDialer{adder: &FlakyAdder{fail: true}}
+	defer d.close()
+	rc := rpc.NewRetryingClient(d.dial, rpc.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+	client := testcapnp.Adder{Client: rc}
+
+	_, err := client.Add(ctx, func(p testcapnp.Adder_add_Params) error {
+		return nil
+	}, rpc.Idempotent()).Struct()
+	if err == nil {
+		t.Error("Add succeeded; want application error")
+	}
+	if n := d.numDials(); n != 1 {
+		t.Errorf("dialed %d times; want 1", n)
+	}
+}
+
+func TestRetryingClientCanceledCall(t *testing.T) {
+	ctx := context.Background()
+	d := &testDialer{adder: &FlakyAdder{}}
+	defer d.close()
+	rc := rpc.NewRetryingClient(d.dial, rpc.RetryBackoff(time.Millisecond, 10*time.Millisecond))
+	defer rc.Close()
+	client := testcapnp.Adder{Client: rc}
+
+	// The first call dials with a canceled context.  This must not
+	// affect later calls on the same connection.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	client.Add(canceled, func(p testcapnp.Adder_add_Params) error {
+		return nil
+	}).Struct()
+
+	result, err := client.Add(ctx, func(p testcapnp.Adder_add_Params) error {
+		p.SetA(5)
+		p.SetB(2)
+		return nil
+	}).Struct()
+	if err != nil {
+		t.Fatal("Add:", err)
+	}
+	if result.Result() != 7 {
+		t.Errorf("Add(5, 2) = %d; want 7", result.Result())
+	}
+	if n := d.numDials(); n != 1 {
+		t.Errorf("dialed %d times; want 1", n)
+	}
+}
+
+// testDialer creates connections to an in-process server.  If hangup
+// is not nil, the first connection's server transport is closed once
+// hangup is closed.
+type testDialer struct {
+	adder  *FlakyAdder
+	hangup chan struct{}
+
+	mu      sync.Mutex
+	n       int
+	servers []*rpc.Conn
+}
+
+func (d *testDialer) dial(ctx context.Context) (*rpc.Conn, error) {
+	p, q := pipetransport.New()
+	if *logMessages {
+		p = logtransport.New(nil, p)
+	}
+	srv := testcapnp.Adder_ServerToClient(d.adder)
+	serverConn := rpc.NewConn(q, rpc.MainInterface(srv.Client))
+
+	d.mu.Lock()
+	d.n++
+	first := d.n == 1
+	d.servers = append(d.servers, serverConn)
+	d.mu.Unlock()
+	if first && d.hangup != nil {
+		go func() {
+			<-d.hangup
+			q.Close()
+		}()
+	}
+	return rpc.NewConn(p), nil
+}
+
+func (d *testDialer) numDials() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.n
+}
+
+func (d *testDialer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, c := range d.servers {
+		c.Close()
+	}
+}
+
+// FlakyAdder is an Adder whose first call hangs up the connection.
+// If fail is true, every call returns an error instead.
+type FlakyAdder struct {
+	hangup chan struct{}
+	fail   bool
+
+	once sync.Once
+}
+
+func (fa *FlakyAdder) Add(call testcapnp.Adder_add) error {
+	server.Ack(call.Options)
+	if fa.fail {
+		return errors.New("flaky adder: failure")
+	}
+	hungUp := false
+	if fa.hangup != nil {
+		fa.once.Do(func() {
+			close(fa.hangup)
+			hungUp = true
+		})
+	}
+	if hungUp {
+		<-call.Ctx.Done()
+		return call.Ctx.Err()
+	}
+	call.Results.SetResult(call.Params.A() + call.Params.B())
+	return nil
+}
@@ -15,6 +15,7 @@ import (
 type Conn struct {
 	transport Transport
 	main      capnp.Client
+	hooks     Hooks
 
 	manager     manager
 	in          <-chan rpccapnp.Message
@@ -24,6 +25,7 @@ type Conn struct {
 	releases    chan *outgoingRelease
 	returns     <-chan *outgoingReturn
 	queueCloses <-chan queueClientClose
+	drains      chan drainRequest
 
 	// Mutable state. Only accessed from coordinate goroutine.
 	questions questionTable
@@ -31,11 +33,14 @@ type Conn struct {
 	imports   importTable
 	exports   exportTable
 	embargoes embargoTable
+	draining  bool
+	idle      []chan<- struct{} // closed once no calls are in flight
 }
 
 type connParams struct {
 	main           capnp.Client
 	sendBufferSize int
+	hooks          Hooks
 }
 
 // A ConnOption is an option for opening a connection.
@@ -61,6 +66,52 @@ func SendBufferSize(numMsgs int) ConnOption {
 	}}
 }
 
+// ConnHooks specifies callbacks that the connection calls as messages
+// cross its transport.
+func ConnHooks(h Hooks) ConnOption {
+	return ConnOption{func(c *connParams) {
+		c.hooks = h
+	}}
+}
+
+// Hooks are callbacks for observing a connection's traffic, for example
+// to count messages or trace calls.  Nil fields are skipped.  Hooks are
+// called synchronously from the goroutines that send and receive the
+// connection's messages, so a slow hook holds up all traffic on the
+// connection: hooks must return promptly and must not call methods on
+// the connection.
+type Hooks struct {
+	// OnSend is called with each message that the transport sent.
+	OnSend func(msg rpccapnp.Message)
+
+	// OnRecv is called with each message that the transport received,
+	// before the connection handles it.  msg is only valid until OnRecv
+	// returns.
+	OnRecv func(msg rpccapnp.Message)
+
+	// OnError is called with each error that the transport returned
+	// from sending or receiving a message.
+	OnError func(err error)
+}
+
+func (h *Hooks) send(msg rpccapnp.Message) {
+	if h.OnSend != nil {
+		h.OnSend(msg)
+	}
+}
+
+func (h *Hooks) recv(msg rpccapnp.Message) {
+	if h.OnRecv != nil {
+		h.OnRecv(msg)
+	}
+}
+
+func (h *Hooks) error(err error) {
+	if h.OnError != nil {
+		h.OnError(err)
+	}
+}
+
 // NewConn creates a new connection that communicates on c.
 // Closing the connection will cause c to be closed.
 func NewConn(t Transport, options ...ConnOption) *Conn {
@@ -73,6 +124,7 @@ func NewConn(t Transport, options ...ConnOption) *Conn {
 		o.f(p)
 	}
 	conn.main = p.main
+	conn.hooks = p.hooks
 	i := make(chan rpccapnp.Message)
 	o := make(chan rpccapnp.Message, p.sendBufferSize)
 	calls := make(chan *appCall)
@@ -86,6 +138,7 @@ func NewConn(t Transport, options ...ConnOption) *Conn {
 	conn.releases = releases
 	conn.returns = rets
 	conn.queueCloses = queueCloses
+	conn.drains = make(chan drainRequest)
 	conn.questions.manager = &conn.manager
 	conn.questions.calls = calls
 	conn.questions.cancels = cancels
@@ -99,10 +152,10 @@ func NewConn(t Transport, options ...ConnOption) *Conn {
 
 	conn.manager.do(conn.coordinate)
 	conn.manager.do(func() {
-		dispatchRecv(&conn.manager, t, i)
+		dispatchRecv(&conn.manager, t, &conn.hooks, i)
 	})
 	conn.manager.do(func() {
-		dispatchSend(&conn.manager, t, o)
+		dispatchSend(&conn.manager, t, &conn.hooks, o)
 	})
 	return conn
 }
@@ -125,6 +178,11 @@ func (c *Conn) Close() error {
 	ctx := context.Background()
 	n := newAbortMessage(nil, errShutdown)
 	werr := c.transport.SendMessage(ctx, n)
+	if werr == nil {
+		c.hooks.send(n)
+	} else {
+		c.hooks.error(werr)
+	}
 	cerr := c.transport.Close()
 	if werr != nil {
 		return werr
@@ -135,6 +193,124 @@ func (c *Conn) Close() error {
 	return nil
 }
 
+// Shutdown closes the connection gracefully.  It stops the connection
+// from starting new calls, in either direction, and waits for the calls
+// already in flight to return before closing the connection as Close
+// does.  If ctx is done first, Shutdown closes the connection anyway
+// and returns a *ShutdownError describing the calls it abandoned.
+func (c *Conn) Shutdown(ctx context.Context) error {
+	idle := make(chan struct{})
+	select {
+	case c.drains <- drainRequest{idle: idle}:
+	case <-c.manager.finish:
+		return ErrConnClosed
+	}
+	select {
+	case <-idle:
+		return c.Close()
+	case <-ctx.Done():
+	case <-c.manager.finish:
+		return ErrConnClosed
+	}
+	report := make(chan *ShutdownError, 1)
+	select {
+	case c.drains <- drainRequest{report: report}:
+	case <-c.manager.finish:
+		return ErrConnClosed
+	}
+	serr := <-report
+	serr.Err = ctx.Err()
+	c.Close()
+	return serr
+}
+
+// A drainRequest asks the coordinate goroutine to stop starting new
+// calls.  If report is not nil, the goroutine sends the calls in flight
+// on it; otherwise it closes idle once no calls are in flight.
+type drainRequest struct {
+	idle   chan<- struct{}
+	report chan<- *ShutdownError
+}
+
+// handleDrain is run in the coordinate goroutine to handle a request
+// from Shutdown.
+func (c *Conn) handleDrain(d drainRequest) {
+	c.draining = true
+	if d.report != nil {
+		d.report <- c.callsInFlight()
+		return
+	}
+	c.idle = append(c.idle, d.idle)
+	c.checkIdle()
+}
+
+// checkIdle is run in the coordinate goroutine to notify Shutdown once
+// the connection is draining and no calls are in flight.
+func (c *Conn) checkIdle() {
+	if len(c.idle) == 0 {
+		return
+	}
+	if e := c.callsInFlight(); len(e.Questions) > 0 || e.Answers > 0 {
+		return
+	}
+	for _, idle := range c.idle {
+		close(idle)
+	}
+	c.idle = nil
+}
+
+// callsInFlight is run in the coordinate goroutine to list the
+// questions that have not been answered and the answers that have not
+// been returned.
+func (c *Conn) callsInFlight() *ShutdownError {
+	e := new(ShutdownError)
+	for _, q := range c.questions.tab {
+		if q == nil {
+			continue
+		}
+		if _, _, _, ok := q.peek(); ok {
+			continue
+		}
+		m := q.method
+		if m != nil && *m == (capnp.Method{}) {
+			// Bootstrap questions are created with the zero method.
+			m = nil
+		}
+		e.Questions = append(e.Questions, m)
+	}
+	for _, a := range c.answers.tab {
+		a.mu.RLock()
+		done := a.done
+		a.mu.RUnlock()
+		if !done {
+			e.Answers++
+		}
+	}
+	return e
+}
+
+// isClosed reports whether the connection has shut down.
+func (c *Conn) isClosed() bool {
+	select {
+	case <-c.manager.finish:
+		return true
+	default:
+		return false
+	}
+}
+
+// isConnError reports whether err is the error that the connection
+// shut down with, as opposed to an error returned by the remote vat.
+func (c *Conn) isConnError(err error) bool {
+	if err == ErrConnClosed {
+		return true
+	}
+	if _, ok := err.(Abort); ok {
+		return true
+	}
+	return c.isClosed() && err == c.manager.err()
+}
+
 // coordinate runs in its own goroutine.
 // It manages dispatching received messages and calls.
 func (c *Conn) coordinate() {
@@ -158,30 +334,65 @@ func (c *Conn) coordinate() {
 			c.handleReturn(r)
 		case qcc := <-c.queueCloses:
 			c.handleQueueClose(qcc)
+		case d := <-c.drains:
+			c.handleDrain(d)
 		case <-c.manager.finish:
 			return
 		}
+		c.checkIdle()
 	}
 }
 
 // Bootstrap returns the receiver's main interface.
 func (c *Conn) Bootstrap(ctx context.Context) capnp.Client {
+	a, err := c.bootstrap(ctx)
+	if err != nil {
+		return capnp.ErrorClient(err)
+	}
+	return capnp.NewPipeline(a).Client()
+}
+
+// BootstrapWait is like Bootstrap, but waits for the receiver to
+// answer the bootstrap question before returning.  If ctx is done
+// first, the question is canceled and BootstrapWait returns ctx's
+// error, so that a caller can give up on an unresponsive peer.
+func (c *Conn) BootstrapWait(ctx context.Context) (capnp.Client, error) {
+	a, err := c.bootstrap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The question is canceled when ctx is done, which resolves it with
+	// ctx's error.
+	if q, ok := a.(*question); ok {
+		_, err = q.wait()
+	} else {
+		_, err = a.Struct()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return capnp.NewPipeline(a).Client(), nil
+}
+
+// bootstrap sends a bootstrap question to the receiver and returns its
+// answer.
+func (c *Conn) bootstrap(ctx context.Context) (capnp.Answer, error) {
 	// TODO(light): Create a client that returns immediately.
 	ac, achan := newAppBootstrapCall(ctx)
 	select {
 	case c.calls <- ac:
 		select {
 		case a := <-achan:
-			return capnp.NewPipeline(a).Client()
+			return a, nil
 		case <-ctx.Done():
-			return capnp.ErrorClient(ctx.Err())
+			return nil, ctx.Err()
 		case <-c.manager.finish:
-			return capnp.ErrorClient(c.manager.err())
+			return nil, c.manager.err()
 		}
 	case <-ctx.Done():
-		return capnp.ErrorClient(ctx.Err())
+		return nil, ctx.Err()
 	case <-c.manager.finish:
-		return capnp.ErrorClient(c.manager.err())
+		return nil, c.manager.err()
 	}
 }
 
@@ -260,6 +471,9 @@ func newUnimplementedMessage(buf []byte, m rpccapnp.Message) rpccapnp.Message {
 
 // handleCall is run from the coordinate goroutine to send a question to a remote vat.
 func (c *Conn) handleCall(ac *appCall) (capnp.Answer, error) {
+	if c.draining {
+		return nil, errDraining
+	}
 	if ac.kind == appPipelineCall && c.questions.get(ac.question.id) != ac.question {
 		// Question has been finished.  The call should happen as if it is
 		// back in application code.
@@ -547,8 +761,12 @@ func (c *Conn) handleBootstrapMessage(id answerID) error {
 		return c.sendMessage(retmsg)
 	}
 	msgs := make([]rpccapnp.Message, 0, 1)
-	if c.main == nil {
-		msgs = a.reject(msgs, errNoMainInterface)
+	if c.draining || c.main == nil {
+		err := errNoMainInterface
+		if c.draining {
+			err = errDraining
+		}
+		msgs = a.reject(msgs, err)
 		for _, m := range msgs {
 			if err := c.sendMessage(m); err != nil {
 				return err
@@ -619,7 +837,12 @@ func (c *Conn) handleCallMessage(m rpccapnp.Message) error {
 		Method: meth,
 		Params: capnp.ToStruct(paramContent),
 	}
-	if err := c.routeCallMessage(a, mt, cl); err != nil {
+	if c.draining {
+		err = errDraining
+	} else {
+		err = c.routeCallMessage(a, mt, cl)
+	}
+	if err != nil {
 		msgs := a.reject(nil, err)
 		for _, m := range msgs {
 			if err := c.sendMessage(m); err != nil {
@@ -3,7 +3,10 @@ package rpc_test
 import (
 	"errors"
 	"flag"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"golang.org/x/net/context"
 	"zombiezen.com/go/capnproto2"
@@ -41,6 +44,52 @@ func TestBootstrap(t *testing.T) {
 	readBootstrap(t, clientCtx, conn, p)
 }
 
+func TestBootstrapWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	conn, p := newTestConn(t)
+	defer conn.Close()
+	defer p.Close()
+
+	clientCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := conn.BootstrapWait(clientCtx)
+		errCh <- err
+	}()
+
+	// Never answer the question.
+	msg, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("Read Bootstrap failed:", err)
+	}
+	if msg.Which() != rpccapnp.Message_Which_bootstrap {
+		t.Fatalf("Received %v message from bootstrap, want Message_Which_bootstrap", msg.Which())
+	}
+	boot, err := msg.Bootstrap()
+	if err != nil {
+		t.Fatal("Read Bootstrap failed:", err)
+	}
+	if err := <-errCh; err != context.DeadlineExceeded {
+		t.Errorf("BootstrapWait error = %v; want %v", err, context.DeadlineExceeded)
+	}
+
+	finish, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("error reading Finish:", err)
+	}
+	if finish.Which() != rpccapnp.Message_Which_finish {
+		t.Fatalf("message sent is %v; want Message_Which_finish", finish.Which())
+	}
+	f, err := finish.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := f.QuestionId(); id != boot.QuestionId() {
+		t.Errorf("finish question ID is %d; want %d", id, boot.QuestionId())
+	}
+}
+
 func readBootstrap(t *testing.T, ctx context.Context, conn *rpc.Conn, p rpc.Transport) (client capnp.Client, questionID uint32) {
 	clientCh := make(chan capnp.Client, 1)
 	go func() {
@@ -123,6 +172,51 @@ func bootstrapAndFulfill(t *testing.T, ctx context.Context, conn *rpc.Conn, p rp
 	return client
 }
 
+func TestConnHooks(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu         sync.Mutex
+		sent, recv []rpccapnp.Message_Which
+	)
+	conn, p := newTestConn(t, rpc.ConnHooks(rpc.Hooks{
+		OnSend: func(msg rpccapnp.Message) {
+			mu.Lock()
+			sent = append(sent, msg.Which())
+			mu.Unlock()
+		},
+		OnRecv: func(msg rpccapnp.Message) {
+			mu.Lock()
+			recv = append(recv, msg.Which())
+			mu.Unlock()
+		},
+	}))
+	defer conn.Close()
+	defer p.Close()
+	bootstrapAndFulfill(t, ctx, conn, p)
+
+	// OnSend runs after the transport hands off the Finish message, so
+	// wait for it to catch up.
+	for i := 0; i < 100; i++ {
+		mu.Lock()
+		n := len(sent)
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	wantSent := []rpccapnp.Message_Which{rpccapnp.Message_Which_bootstrap, rpccapnp.Message_Which_finish}
+	if !reflect.DeepEqual(sent, wantSent) {
+		t.Errorf("OnSend saw %v; want %v", sent, wantSent)
+	}
+	wantRecv := []rpccapnp.Message_Which{rpccapnp.Message_Which_return}
+	if !reflect.DeepEqual(recv, wantRecv) {
+		t.Errorf("OnRecv saw %v; want %v", recv, wantRecv)
+	}
+}
+
 func TestCallOnPromisedAnswer(t *testing.T) {
 	ctx := context.Background()
 	conn, p := newTestConn(t)
@@ -256,6 +350,149 @@ func TestCallOnExportId(t *testing.T) {
 	}
 }
 
+func TestCallOnClosedConn(t *testing.T) {
+	ctx := context.Background()
+	conn, p := newTestConn(t)
+	defer conn.Close()
+	client := bootstrapAndFulfill(t, ctx, conn, p)
+
+	readDone := startRecvMessage(p)
+	ans := client.Call(&capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID: interfaceID,
+			MethodID:    methodID,
+		},
+		ParamsSize: capnp.ObjectSize{DataSize: 8},
+		ParamsFunc: func(s capnp.Struct) error {
+			s.SetUint64(0, 42)
+			return nil
+		},
+	})
+	if read := <-readDone; read.err != nil {
+		t.Fatal("Reading failed:", read.err)
+	}
+	// Hang up before returning.
+	p.Close()
+
+	errch := make(chan error, 1)
+	go func() {
+		_, err := ans.Struct()
+		errch <- err
+	}()
+	select {
+	case err := <-errch:
+		if err == nil {
+			t.Error("answer.Struct() on closed connection succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("answer.Struct() did not return after connection closed")
+	}
+
+	go func() {
+		errch <- ans.PipelineClose(nil)
+	}()
+	select {
+	case err := <-errch:
+		if err == nil {
+			t.Error("answer.PipelineClose() on closed connection succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("answer.PipelineClose() did not return after connection closed")
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	ctx := context.Background()
+	conn, p := newTestConn(t)
+	defer p.Close()
+	_, bootstrapID := readBootstrap(t, ctx, conn, p)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- conn.Shutdown(ctx)
+	}()
+	// Give Shutdown time to start draining.
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case err := <-errCh:
+		t.Fatalf("Shutdown returned %v with a question in flight", err)
+	default:
+	}
+	_, err := conn.Bootstrap(ctx).Call(&capnp.Call{
+		Ctx: ctx,
+		Method: capnp.Method{
+			InterfaceID: interfaceID,
+			MethodID:    methodID,
+		},
+	}).Struct()
+	if err == nil {
+		t.Error("call started during Shutdown succeeded; want error")
+	}
+
+	err = sendMessage(ctx, p, func(msg rpccapnp.Message) error {
+		ret, err := msg.NewReturn()
+		if err != nil {
+			return err
+		}
+		ret.SetAnswerId(bootstrapID)
+		exc, err := rpccapnp.NewException(msg.Segment())
+		if err != nil {
+			return err
+		}
+		exc.SetReason("no bootstrap here")
+		return ret.SetException(exc)
+	})
+	if err != nil {
+		t.Fatal("error writing Return:", err)
+	}
+	for {
+		msg, err := p.RecvMessage(ctx)
+		if err != nil {
+			t.Fatal("RecvMessage:", err)
+		}
+		if msg.Which() == rpccapnp.Message_Which_abort {
+			break
+		}
+	}
+	if err := <-errCh; err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ctx := context.Background()
+	conn, p := newTestConn(t)
+	defer p.Close()
+	readBootstrap(t, ctx, conn, p)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- conn.Shutdown(shutdownCtx)
+	}()
+	// Never answer the question, just read the Abort from the hard close.
+	msg, err := p.RecvMessage(ctx)
+	if err != nil {
+		t.Fatal("RecvMessage:", err)
+	}
+	if msg.Which() != rpccapnp.Message_Which_abort {
+		t.Errorf("Conn sent %v message; want Message_Which_abort", msg.Which())
+	}
+	err = <-errCh
+	serr, ok := err.(*rpc.ShutdownError)
+	if !ok {
+		t.Fatalf("Shutdown error = %v; want *rpc.ShutdownError", err)
+	}
+	if len(serr.Questions) != 1 || serr.Questions[0] != nil || serr.Answers != 0 {
+		t.Errorf("Shutdown abandoned questions %v and %d answers; want [<nil>] and 0", serr.Questions, serr.Answers)
+	}
+	if serr.Err != context.DeadlineExceeded {
+		t.Errorf("ShutdownError.Err = %v; want %v", serr.Err, context.DeadlineExceeded)
+	}
+}
+
 func TestMainInterface(t *testing.T) {
 	main := mockClient()
 	conn, p := newTestConn(t, rpc.MainInterface(main))
@@ -25,6 +25,13 @@ const (
 	Message_Which_provide        Message_Which = 10
 	Message_Which_accept         Message_Which = 11
 	Message_Which_join           Message_Which = 12
+
+	// Message_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Message_Which_Unknown Message_Which = 0xffff
 )
 
 func (w Message_Which) String() string {
@@ -89,7 +96,17 @@ func ReadRootMessage(msg *capnp.Message) (Message, error) {
 }
 
 func (s Message) Which() Message_Which {
-	return Message_Which(s.Struct.Uint16(0))
+	w := Message_Which(s.Struct.Uint16(0))
+	if w >= 14 {
+		return Message_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Message_Which_Unknown.
+func (s Message) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s Message) Unimplemented() (Message, error) {
@@ -103,6 +120,13 @@ func (s Message) Unimplemented() (Message, error) {
 	return Message{Struct: ss}, nil
 }
 
+func (s Message) HasUnimplemented() bool {
+	if s.Which() != Message_Which_unimplemented {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetUnimplemented(v Message) error {
 	s.Struct.SetUint16(0, 0)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -131,6 +155,13 @@ func (s Message) Abort() (Exception, error) {
 	return Exception{Struct: ss}, nil
 }
 
+func (s Message) HasAbort() bool {
+	if s.Which() != Message_Which_abort {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetAbort(v Exception) error {
 	s.Struct.SetUint16(0, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -159,6 +190,13 @@ func (s Message) Bootstrap() (Bootstrap, error) {
 	return Bootstrap{Struct: ss}, nil
 }
 
+func (s Message) HasBootstrap() bool {
+	if s.Which() != Message_Which_bootstrap {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetBootstrap(v Bootstrap) error {
 	s.Struct.SetUint16(0, 8)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -187,6 +225,13 @@ func (s Message) Call() (Call, error) {
 	return Call{Struct: ss}, nil
 }
 
+func (s Message) HasCall() bool {
+	if s.Which() != Message_Which_call {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetCall(v Call) error {
 	s.Struct.SetUint16(0, 2)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -215,6 +260,13 @@ func (s Message) Return() (Return, error) {
 	return Return{Struct: ss}, nil
 }
 
+func (s Message) HasReturn() bool {
+	if s.Which() != Message_Which_return {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetReturn(v Return) error {
 	s.Struct.SetUint16(0, 3)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -243,6 +295,13 @@ func (s Message) Finish() (Finish, error) {
 	return Finish{Struct: ss}, nil
 }
 
+func (s Message) HasFinish() bool {
+	if s.Which() != Message_Which_finish {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetFinish(v Finish) error {
 	s.Struct.SetUint16(0, 4)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -271,6 +330,13 @@ func (s Message) Resolve() (Resolve, error) {
 	return Resolve{Struct: ss}, nil
 }
 
+func (s Message) HasResolve() bool {
+	if s.Which() != Message_Which_resolve {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetResolve(v Resolve) error {
 	s.Struct.SetUint16(0, 5)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -299,6 +365,13 @@ func (s Message) Release() (Release, error) {
 	return Release{Struct: ss}, nil
 }
 
+func (s Message) HasRelease() bool {
+	if s.Which() != Message_Which_release {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetRelease(v Release) error {
 	s.Struct.SetUint16(0, 6)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -327,6 +400,13 @@ func (s Message) Disembargo() (Disembargo, error) {
 	return Disembargo{Struct: ss}, nil
 }
 
+func (s Message) HasDisembargo() bool {
+	if s.Which() != Message_Which_disembargo {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetDisembargo(v Disembargo) error {
 	s.Struct.SetUint16(0, 13)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -350,6 +430,13 @@ func (s Message) ObsoleteSave() (capnp.Pointer, error) {
 
 }
 
+func (s Message) HasObsoleteSave() bool {
+	if s.Which() != Message_Which_obsoleteSave {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetObsoleteSave(v capnp.Pointer) error {
 	s.Struct.SetUint16(0, 7)
 	return s.Struct.SetPointer(0, v)
@@ -361,6 +448,13 @@ func (s Message) ObsoleteDelete() (capnp.Pointer, error) {
 
 }
 
+func (s Message) HasObsoleteDelete() bool {
+	if s.Which() != Message_Which_obsoleteDelete {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetObsoleteDelete(v capnp.Pointer) error {
 	s.Struct.SetUint16(0, 9)
 	return s.Struct.SetPointer(0, v)
@@ -377,6 +471,13 @@ func (s Message) Provide() (Provide, error) {
 	return Provide{Struct: ss}, nil
 }
 
+func (s Message) HasProvide() bool {
+	if s.Which() != Message_Which_provide {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetProvide(v Provide) error {
 	s.Struct.SetUint16(0, 10)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -405,6 +506,13 @@ func (s Message) Accept() (Accept, error) {
 	return Accept{Struct: ss}, nil
 }
 
+func (s Message) HasAccept() bool {
+	if s.Which() != Message_Which_accept {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetAccept(v Accept) error {
 	s.Struct.SetUint16(0, 11)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -433,6 +541,13 @@ func (s Message) Join() (Join, error) {
 	return Join{Struct: ss}, nil
 }
 
+func (s Message) HasJoin() bool {
+	if s.Which() != Message_Which_join {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Message) SetJoin(v Join) error {
 	s.Struct.SetUint16(0, 12)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -465,6 +580,9 @@ func NewMessage_List(s *capnp.Segment, sz int32) (Message_List, error) {
 func (s Message_List) At(i int) Message           { return Message{s.List.Struct(i)} }
 func (s Message_List) Set(i int, v Message) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Message) CopyFrom(src Message) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Message_Promise is a wrapper for a Message promised by a client call.
 type Message_Promise struct{ *capnp.Pipeline }
 
@@ -571,6 +689,10 @@ func (s Bootstrap) DeprecatedObjectId() (capnp.Pointer, error) {
 
 }
 
+func (s Bootstrap) HasDeprecatedObjectId() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Bootstrap) SetDeprecatedObjectId(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(0, v)
@@ -591,6 +713,9 @@ func NewBootstrap_List(s *capnp.Segment, sz int32) (Bootstrap_List, error) {
 func (s Bootstrap_List) At(i int) Bootstrap           { return Bootstrap{s.List.Struct(i)} }
 func (s Bootstrap_List) Set(i int, v Bootstrap) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Bootstrap) CopyFrom(src Bootstrap) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Bootstrap_Promise is a wrapper for a Bootstrap promised by a client call.
 type Bootstrap_Promise struct{ *capnp.Pipeline }
 
@@ -611,6 +736,13 @@ const (
 	Call_sendResultsTo_Which_caller     Call_sendResultsTo_Which = 0
 	Call_sendResultsTo_Which_yourself   Call_sendResultsTo_Which = 1
 	Call_sendResultsTo_Which_thirdParty Call_sendResultsTo_Which = 2
+
+	// Call_sendResultsTo_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Call_sendResultsTo_Which_Unknown Call_sendResultsTo_Which = 0xffff
 )
 
 func (w Call_sendResultsTo_Which) String() string {
@@ -672,6 +804,10 @@ func (s Call) Target() (MessageTarget, error) {
 	return MessageTarget{Struct: ss}, nil
 }
 
+func (s Call) HasTarget() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Call) SetTarget(v MessageTarget) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -716,6 +852,9 @@ func (s Call) SetAllowThirdPartyTailCall(v bool) {
 	s.Struct.SetBit(128, v)
 }
 
+// Call_allowThirdPartyTailCall_Default is the default value of Call.allowThirdPartyTailCall.
+const Call_allowThirdPartyTailCall_Default = false
+
 func (s Call) Params() (Payload, error) {
 	p, err := s.Struct.Pointer(1)
 	if err != nil {
@@ -727,6 +866,10 @@ func (s Call) Params() (Payload, error) {
 	return Payload{Struct: ss}, nil
 }
 
+func (s Call) HasParams() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Call) SetParams(v Payload) error {
 
 	return s.Struct.SetPointer(1, v.Struct)
@@ -746,15 +889,27 @@ func (s Call) NewParams() (Payload, error) {
 func (s Call) SendResultsTo() Call_sendResultsTo { return Call_sendResultsTo(s) }
 
 func (s Call_sendResultsTo) Which() Call_sendResultsTo_Which {
-	return Call_sendResultsTo_Which(s.Struct.Uint16(6))
+	w := Call_sendResultsTo_Which(s.Struct.Uint16(6))
+	if w >= 3 {
+		return Call_sendResultsTo_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Call_sendResultsTo_Which_Unknown.
+func (s Call_sendResultsTo) WhichRaw() uint16 {
+	return s.Struct.Uint16(6)
 }
 
 func (s Call_sendResultsTo) SetCaller() {
 	s.Struct.SetUint16(6, 0)
+	s.Struct.SetPointer(2, nil)
 }
 
 func (s Call_sendResultsTo) SetYourself() {
 	s.Struct.SetUint16(6, 1)
+	s.Struct.SetPointer(2, nil)
 }
 
 func (s Call_sendResultsTo) ThirdParty() (capnp.Pointer, error) {
@@ -763,6 +918,13 @@ func (s Call_sendResultsTo) ThirdParty() (capnp.Pointer, error) {
 
 }
 
+func (s Call_sendResultsTo) HasThirdParty() bool {
+	if s.Which() != Call_sendResultsTo_Which_thirdParty {
+		return false
+	}
+	return s.Struct.HasPointer(2)
+}
+
 func (s Call_sendResultsTo) SetThirdParty(v capnp.Pointer) error {
 	s.Struct.SetUint16(6, 2)
 	return s.Struct.SetPointer(2, v)
@@ -783,6 +945,9 @@ func NewCall_List(s *capnp.Segment, sz int32) (Call_List, error) {
 func (s Call_List) At(i int) Call           { return Call{s.List.Struct(i)} }
 func (s Call_List) Set(i int, v Call) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Call) CopyFrom(src Call) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Call_Promise is a wrapper for a Call promised by a client call.
 type Call_Promise struct{ *capnp.Pipeline }
 
@@ -824,6 +989,13 @@ const (
 	Return_Which_resultsSentElsewhere  Return_Which = 3
 	Return_Which_takeFromOtherQuestion Return_Which = 4
 	Return_Which_acceptFromThirdParty  Return_Which = 5
+
+	// Return_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Return_Which_Unknown Return_Which = 0xffff
 )
 
 func (w Return_Which) String() string {
@@ -872,7 +1044,17 @@ func ReadRootReturn(msg *capnp.Message) (Return, error) {
 }
 
 func (s Return) Which() Return_Which {
-	return Return_Which(s.Struct.Uint16(6))
+	w := Return_Which(s.Struct.Uint16(6))
+	if w >= 6 {
+		return Return_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Return_Which_Unknown.
+func (s Return) WhichRaw() uint16 {
+	return s.Struct.Uint16(6)
 }
 
 func (s Return) AnswerId() uint32 {
@@ -893,6 +1075,9 @@ func (s Return) SetReleaseParamCaps(v bool) {
 	s.Struct.SetBit(32, !v)
 }
 
+// Return_releaseParamCaps_Default is the default value of Return.releaseParamCaps.
+const Return_releaseParamCaps_Default = true
+
 func (s Return) Results() (Payload, error) {
 	p, err := s.Struct.Pointer(0)
 	if err != nil {
@@ -904,6 +1089,13 @@ func (s Return) Results() (Payload, error) {
 	return Payload{Struct: ss}, nil
 }
 
+func (s Return) HasResults() bool {
+	if s.Which() != Return_Which_results {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Return) SetResults(v Payload) error {
 	s.Struct.SetUint16(6, 0)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -932,6 +1124,13 @@ func (s Return) Exception() (Exception, error) {
 	return Exception{Struct: ss}, nil
 }
 
+func (s Return) HasException() bool {
+	if s.Which() != Return_Which_exception {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Return) SetException(v Exception) error {
 	s.Struct.SetUint16(6, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -951,10 +1150,14 @@ func (s Return) NewException() (Exception, error) {
 
 func (s Return) SetCanceled() {
 	s.Struct.SetUint16(6, 2)
+	s.Struct.SetUint32(8, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Return) SetResultsSentElsewhere() {
 	s.Struct.SetUint16(6, 3)
+	s.Struct.SetUint32(8, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s Return) TakeFromOtherQuestion() uint32 {
@@ -972,6 +1175,13 @@ func (s Return) AcceptFromThirdParty() (capnp.Pointer, error) {
 
 }
 
+func (s Return) HasAcceptFromThirdParty() bool {
+	if s.Which() != Return_Which_acceptFromThirdParty {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Return) SetAcceptFromThirdParty(v capnp.Pointer) error {
 	s.Struct.SetUint16(6, 5)
 	return s.Struct.SetPointer(0, v)
@@ -992,6 +1202,9 @@ func NewReturn_List(s *capnp.Segment, sz int32) (Return_List, error) {
 func (s Return_List) At(i int) Return           { return Return{s.List.Struct(i)} }
 func (s Return_List) Set(i int, v Return) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Return) CopyFrom(src Return) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Return_Promise is a wrapper for a Return promised by a client call.
 type Return_Promise struct{ *capnp.Pipeline }
 
@@ -1057,6 +1270,9 @@ func (s Finish) SetReleaseResultCaps(v bool) {
 	s.Struct.SetBit(32, !v)
 }
 
+// Finish_releaseResultCaps_Default is the default value of Finish.releaseResultCaps.
+const Finish_releaseResultCaps_Default = true
+
 // Finish_List is a list of Finish.
 type Finish_List struct{ capnp.List }
 
@@ -1072,6 +1288,9 @@ func NewFinish_List(s *capnp.Segment, sz int32) (Finish_List, error) {
 func (s Finish_List) At(i int) Finish           { return Finish{s.List.Struct(i)} }
 func (s Finish_List) Set(i int, v Finish) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Finish) CopyFrom(src Finish) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Finish_Promise is a wrapper for a Finish promised by a client call.
 type Finish_Promise struct{ *capnp.Pipeline }
 
@@ -1086,6 +1305,13 @@ type Resolve_Which uint16
 const (
 	Resolve_Which_cap       Resolve_Which = 0
 	Resolve_Which_exception Resolve_Which = 1
+
+	// Resolve_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Resolve_Which_Unknown Resolve_Which = 0xffff
 )
 
 func (w Resolve_Which) String() string {
@@ -1126,7 +1352,17 @@ func ReadRootResolve(msg *capnp.Message) (Resolve, error) {
 }
 
 func (s Resolve) Which() Resolve_Which {
-	return Resolve_Which(s.Struct.Uint16(4))
+	w := Resolve_Which(s.Struct.Uint16(4))
+	if w >= 2 {
+		return Resolve_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Resolve_Which_Unknown.
+func (s Resolve) WhichRaw() uint16 {
+	return s.Struct.Uint16(4)
 }
 
 func (s Resolve) PromiseId() uint32 {
@@ -1149,6 +1385,13 @@ func (s Resolve) Cap() (CapDescriptor, error) {
 	return CapDescriptor{Struct: ss}, nil
 }
 
+func (s Resolve) HasCap() bool {
+	if s.Which() != Resolve_Which_cap {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Resolve) SetCap(v CapDescriptor) error {
 	s.Struct.SetUint16(4, 0)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1177,6 +1420,13 @@ func (s Resolve) Exception() (Exception, error) {
 	return Exception{Struct: ss}, nil
 }
 
+func (s Resolve) HasException() bool {
+	if s.Which() != Resolve_Which_exception {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s Resolve) SetException(v Exception) error {
 	s.Struct.SetUint16(4, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1209,6 +1459,9 @@ func NewResolve_List(s *capnp.Segment, sz int32) (Resolve_List, error) {
 func (s Resolve_List) At(i int) Resolve           { return Resolve{s.List.Struct(i)} }
 func (s Resolve_List) Set(i int, v Resolve) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Resolve) CopyFrom(src Resolve) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Resolve_Promise is a wrapper for a Resolve promised by a client call.
 type Resolve_Promise struct{ *capnp.Pipeline }
 
@@ -1285,6 +1538,9 @@ func NewRelease_List(s *capnp.Segment, sz int32) (Release_List, error) {
 func (s Release_List) At(i int) Release           { return Release{s.List.Struct(i)} }
 func (s Release_List) Set(i int, v Release) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Release) CopyFrom(src Release) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Release_Promise is a wrapper for a Release promised by a client call.
 type Release_Promise struct{ *capnp.Pipeline }
 
@@ -1302,6 +1558,13 @@ const (
 	Disembargo_context_Which_receiverLoopback Disembargo_context_Which = 1
 	Disembargo_context_Which_accept           Disembargo_context_Which = 2
 	Disembargo_context_Which_provide          Disembargo_context_Which = 3
+
+	// Disembargo_context_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	Disembargo_context_Which_Unknown Disembargo_context_Which = 0xffff
 )
 
 func (w Disembargo_context_Which) String() string {
@@ -1356,6 +1619,10 @@ func (s Disembargo) Target() (MessageTarget, error) {
 	return MessageTarget{Struct: ss}, nil
 }
 
+func (s Disembargo) HasTarget() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Disembargo) SetTarget(v MessageTarget) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1375,7 +1642,17 @@ func (s Disembargo) NewTarget() (MessageTarget, error) {
 func (s Disembargo) Context() Disembargo_context { return Disembargo_context(s) }
 
 func (s Disembargo_context) Which() Disembargo_context_Which {
-	return Disembargo_context_Which(s.Struct.Uint16(4))
+	w := Disembargo_context_Which(s.Struct.Uint16(4))
+	if w >= 4 {
+		return Disembargo_context_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as Disembargo_context_Which_Unknown.
+func (s Disembargo_context) WhichRaw() uint16 {
+	return s.Struct.Uint16(4)
 }
 
 func (s Disembargo_context) SenderLoopback() uint32 {
@@ -1398,6 +1675,7 @@ func (s Disembargo_context) SetReceiverLoopback(v uint32) {
 
 func (s Disembargo_context) SetAccept() {
 	s.Struct.SetUint16(4, 2)
+	s.Struct.SetUint32(0, 0)
 }
 
 func (s Disembargo_context) Provide() uint32 {
@@ -1424,6 +1702,9 @@ func NewDisembargo_List(s *capnp.Segment, sz int32) (Disembargo_List, error) {
 func (s Disembargo_List) At(i int) Disembargo           { return Disembargo{s.List.Struct(i)} }
 func (s Disembargo_List) Set(i int, v Disembargo) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Disembargo) CopyFrom(src Disembargo) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Disembargo_Promise is a wrapper for a Disembargo promised by a client call.
 type Disembargo_Promise struct{ *capnp.Pipeline }
 
@@ -1494,6 +1775,10 @@ func (s Provide) Target() (MessageTarget, error) {
 	return MessageTarget{Struct: ss}, nil
 }
 
+func (s Provide) HasTarget() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Provide) SetTarget(v MessageTarget) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1517,6 +1802,10 @@ func (s Provide) Recipient() (capnp.Pointer, error) {
 
 }
 
+func (s Provide) HasRecipient() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Provide) SetRecipient(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(1, v)
@@ -1537,6 +1826,9 @@ func NewProvide_List(s *capnp.Segment, sz int32) (Provide_List, error) {
 func (s Provide_List) At(i int) Provide           { return Provide{s.List.Struct(i)} }
 func (s Provide_List) Set(i int, v Provide) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Provide) CopyFrom(src Provide) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Provide_Promise is a wrapper for a Provide promised by a client call.
 type Provide_Promise struct{ *capnp.Pipeline }
 
@@ -1595,6 +1887,10 @@ func (s Accept) Provision() (capnp.Pointer, error) {
 
 }
 
+func (s Accept) HasProvision() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Accept) SetProvision(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(0, v)
@@ -1624,6 +1920,9 @@ func NewAccept_List(s *capnp.Segment, sz int32) (Accept_List, error) {
 func (s Accept_List) At(i int) Accept           { return Accept{s.List.Struct(i)} }
 func (s Accept_List) Set(i int, v Accept) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Accept) CopyFrom(src Accept) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Accept_Promise is a wrapper for a Accept promised by a client call.
 type Accept_Promise struct{ *capnp.Pipeline }
 
@@ -1683,6 +1982,10 @@ func (s Join) Target() (MessageTarget, error) {
 	return MessageTarget{Struct: ss}, nil
 }
 
+func (s Join) HasTarget() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Join) SetTarget(v MessageTarget) error {
 
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1706,6 +2009,10 @@ func (s Join) KeyPart() (capnp.Pointer, error) {
 
 }
 
+func (s Join) HasKeyPart() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Join) SetKeyPart(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(1, v)
@@ -1726,6 +2033,9 @@ func NewJoin_List(s *capnp.Segment, sz int32) (Join_List, error) {
 func (s Join_List) At(i int) Join           { return Join{s.List.Struct(i)} }
 func (s Join_List) Set(i int, v Join) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Join) CopyFrom(src Join) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Join_Promise is a wrapper for a Join promised by a client call.
 type Join_Promise struct{ *capnp.Pipeline }
 
@@ -1748,6 +2058,13 @@ type MessageTarget_Which uint16
 const (
 	MessageTarget_Which_importedCap    MessageTarget_Which = 0
 	MessageTarget_Which_promisedAnswer MessageTarget_Which = 1
+
+	// MessageTarget_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	MessageTarget_Which_Unknown MessageTarget_Which = 0xffff
 )
 
 func (w MessageTarget_Which) String() string {
@@ -1788,7 +2105,17 @@ func ReadRootMessageTarget(msg *capnp.Message) (MessageTarget, error) {
 }
 
 func (s MessageTarget) Which() MessageTarget_Which {
-	return MessageTarget_Which(s.Struct.Uint16(4))
+	w := MessageTarget_Which(s.Struct.Uint16(4))
+	if w >= 2 {
+		return MessageTarget_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as MessageTarget_Which_Unknown.
+func (s MessageTarget) WhichRaw() uint16 {
+	return s.Struct.Uint16(4)
 }
 
 func (s MessageTarget) ImportedCap() uint32 {
@@ -1811,6 +2138,13 @@ func (s MessageTarget) PromisedAnswer() (PromisedAnswer, error) {
 	return PromisedAnswer{Struct: ss}, nil
 }
 
+func (s MessageTarget) HasPromisedAnswer() bool {
+	if s.Which() != MessageTarget_Which_promisedAnswer {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s MessageTarget) SetPromisedAnswer(v PromisedAnswer) error {
 	s.Struct.SetUint16(4, 1)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -1843,6 +2177,9 @@ func NewMessageTarget_List(s *capnp.Segment, sz int32) (MessageTarget_List, erro
 func (s MessageTarget_List) At(i int) MessageTarget           { return MessageTarget{s.List.Struct(i)} }
 func (s MessageTarget_List) Set(i int, v MessageTarget) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s MessageTarget) CopyFrom(src MessageTarget) error { return s.Struct.CopyFrom(src.Struct) }
+
 // MessageTarget_Promise is a wrapper for a MessageTarget promised by a client call.
 type MessageTarget_Promise struct{ *capnp.Pipeline }
 
@@ -1888,6 +2225,10 @@ func (s Payload) Content() (capnp.Pointer, error) {
 
 }
 
+func (s Payload) HasContent() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Payload) SetContent(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(0, v)
@@ -1904,6 +2245,10 @@ func (s Payload) CapTable() (CapDescriptor_List, error) {
 	return CapDescriptor_List{List: l}, nil
 }
 
+func (s Payload) HasCapTable() bool {
+	return s.Struct.HasPointer(1)
+}
+
 func (s Payload) SetCapTable(v CapDescriptor_List) error {
 
 	return s.Struct.SetPointer(1, v.List)
@@ -1924,6 +2269,9 @@ func NewPayload_List(s *capnp.Segment, sz int32) (Payload_List, error) {
 func (s Payload_List) At(i int) Payload           { return Payload{s.List.Struct(i)} }
 func (s Payload_List) Set(i int, v Payload) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Payload) CopyFrom(src Payload) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Payload_Promise is a wrapper for a Payload promised by a client call.
 type Payload_Promise struct{ *capnp.Pipeline }
 
@@ -1946,6 +2294,13 @@ const (
 	CapDescriptor_Which_receiverHosted   CapDescriptor_Which = 3
 	CapDescriptor_Which_receiverAnswer   CapDescriptor_Which = 4
 	CapDescriptor_Which_thirdPartyHosted CapDescriptor_Which = 5
+
+	// CapDescriptor_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	CapDescriptor_Which_Unknown CapDescriptor_Which = 0xffff
 )
 
 func (w CapDescriptor_Which) String() string {
@@ -1994,11 +2349,23 @@ func ReadRootCapDescriptor(msg *capnp.Message) (CapDescriptor, error) {
 }
 
 func (s CapDescriptor) Which() CapDescriptor_Which {
-	return CapDescriptor_Which(s.Struct.Uint16(0))
+	w := CapDescriptor_Which(s.Struct.Uint16(0))
+	if w >= 6 {
+		return CapDescriptor_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as CapDescriptor_Which_Unknown.
+func (s CapDescriptor) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s CapDescriptor) SetNone() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetUint32(4, 0)
+	s.Struct.SetPointer(0, nil)
 }
 
 func (s CapDescriptor) SenderHosted() uint32 {
@@ -2039,6 +2406,13 @@ func (s CapDescriptor) ReceiverAnswer() (PromisedAnswer, error) {
 	return PromisedAnswer{Struct: ss}, nil
 }
 
+func (s CapDescriptor) HasReceiverAnswer() bool {
+	if s.Which() != CapDescriptor_Which_receiverAnswer {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s CapDescriptor) SetReceiverAnswer(v PromisedAnswer) error {
 	s.Struct.SetUint16(0, 4)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2067,6 +2441,13 @@ func (s CapDescriptor) ThirdPartyHosted() (ThirdPartyCapDescriptor, error) {
 	return ThirdPartyCapDescriptor{Struct: ss}, nil
 }
 
+func (s CapDescriptor) HasThirdPartyHosted() bool {
+	if s.Which() != CapDescriptor_Which_thirdPartyHosted {
+		return false
+	}
+	return s.Struct.HasPointer(0)
+}
+
 func (s CapDescriptor) SetThirdPartyHosted(v ThirdPartyCapDescriptor) error {
 	s.Struct.SetUint16(0, 5)
 	return s.Struct.SetPointer(0, v.Struct)
@@ -2099,6 +2480,9 @@ func NewCapDescriptor_List(s *capnp.Segment, sz int32) (CapDescriptor_List, erro
 func (s CapDescriptor_List) At(i int) CapDescriptor           { return CapDescriptor{s.List.Struct(i)} }
 func (s CapDescriptor_List) Set(i int, v CapDescriptor) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s CapDescriptor) CopyFrom(src CapDescriptor) error { return s.Struct.CopyFrom(src.Struct) }
+
 // CapDescriptor_Promise is a wrapper for a CapDescriptor promised by a client call.
 type CapDescriptor_Promise struct{ *capnp.Pipeline }
 
@@ -2162,6 +2546,10 @@ func (s PromisedAnswer) Transform() (PromisedAnswer_Op_List, error) {
 	return PromisedAnswer_Op_List{List: l}, nil
 }
 
+func (s PromisedAnswer) HasTransform() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s PromisedAnswer) SetTransform(v PromisedAnswer_Op_List) error {
 
 	return s.Struct.SetPointer(0, v.List)
@@ -2182,6 +2570,9 @@ func NewPromisedAnswer_List(s *capnp.Segment, sz int32) (PromisedAnswer_List, er
 func (s PromisedAnswer_List) At(i int) PromisedAnswer           { return PromisedAnswer{s.List.Struct(i)} }
 func (s PromisedAnswer_List) Set(i int, v PromisedAnswer) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s PromisedAnswer) CopyFrom(src PromisedAnswer) error { return s.Struct.CopyFrom(src.Struct) }
+
 // PromisedAnswer_Promise is a wrapper for a PromisedAnswer promised by a client call.
 type PromisedAnswer_Promise struct{ *capnp.Pipeline }
 
@@ -2196,6 +2587,13 @@ type PromisedAnswer_Op_Which uint16
 const (
 	PromisedAnswer_Op_Which_noop            PromisedAnswer_Op_Which = 0
 	PromisedAnswer_Op_Which_getPointerField PromisedAnswer_Op_Which = 1
+
+	// PromisedAnswer_Op_Which_Unknown is returned by Which for a discriminant
+	// that is not in the schema, such as one set by a newer version.
+	// Which assumes that the schema's discriminants are contiguous from
+	// zero, as the schema compiler assigns them.  Use WhichRaw to get
+	// the discriminant itself.
+	PromisedAnswer_Op_Which_Unknown PromisedAnswer_Op_Which = 0xffff
 )
 
 func (w PromisedAnswer_Op_Which) String() string {
@@ -2236,11 +2634,22 @@ func ReadRootPromisedAnswer_Op(msg *capnp.Message) (PromisedAnswer_Op, error) {
 }
 
 func (s PromisedAnswer_Op) Which() PromisedAnswer_Op_Which {
-	return PromisedAnswer_Op_Which(s.Struct.Uint16(0))
+	w := PromisedAnswer_Op_Which(s.Struct.Uint16(0))
+	if w >= 2 {
+		return PromisedAnswer_Op_Which_Unknown
+	}
+	return w
+}
+
+// WhichRaw returns the union's discriminant as stored in the message,
+// including one that Which reports as PromisedAnswer_Op_Which_Unknown.
+func (s PromisedAnswer_Op) WhichRaw() uint16 {
+	return s.Struct.Uint16(0)
 }
 
 func (s PromisedAnswer_Op) SetNoop() {
 	s.Struct.SetUint16(0, 0)
+	s.Struct.SetUint16(2, 0)
 }
 
 func (s PromisedAnswer_Op) GetPointerField() uint16 {
@@ -2271,6 +2680,11 @@ func (s PromisedAnswer_Op_List) Set(i int, v PromisedAnswer_Op) error {
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s PromisedAnswer_Op) CopyFrom(src PromisedAnswer_Op) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // PromisedAnswer_Op_Promise is a wrapper for a PromisedAnswer_Op promised by a client call.
 type PromisedAnswer_Op_Promise struct{ *capnp.Pipeline }
 
@@ -2312,6 +2726,10 @@ func (s ThirdPartyCapDescriptor) Id() (capnp.Pointer, error) {
 
 }
 
+func (s ThirdPartyCapDescriptor) HasId() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s ThirdPartyCapDescriptor) SetId(v capnp.Pointer) error {
 
 	return s.Struct.SetPointer(0, v)
@@ -2345,6 +2763,11 @@ func (s ThirdPartyCapDescriptor_List) Set(i int, v ThirdPartyCapDescriptor) erro
 	return s.List.SetStruct(i, v.Struct)
 }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s ThirdPartyCapDescriptor) CopyFrom(src ThirdPartyCapDescriptor) error {
+	return s.Struct.CopyFrom(src.Struct)
+}
+
 // ThirdPartyCapDescriptor_Promise is a wrapper for a ThirdPartyCapDescriptor promised by a client call.
 type ThirdPartyCapDescriptor_Promise struct{ *capnp.Pipeline }
 
@@ -2394,6 +2817,22 @@ func (s Exception) Reason() (string, error) {
 
 }
 
+// ReasonBytes returns the reason field without its NUL terminator.
+// The slice aliases the message and is only valid as long as the message is.
+func (s Exception) ReasonBytes() ([]byte, error) {
+	p, err := s.Struct.Pointer(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return capnp.ToTextBytes(p), nil
+
+}
+
+func (s Exception) HasReason() bool {
+	return s.Struct.HasPointer(0)
+}
+
 func (s Exception) SetReason(v string) error {
 
 	t, err := capnp.NewText(s.Struct.Segment(), v)
@@ -2445,6 +2884,9 @@ func NewException_List(s *capnp.Segment, sz int32) (Exception_List, error) {
 func (s Exception_List) At(i int) Exception           { return Exception{s.List.Struct(i)} }
 func (s Exception_List) Set(i int, v Exception) error { return s.List.SetStruct(i, v.Struct) }
 
+// CopyFrom makes s a deep copy of src.  See capnp.Struct.CopyFrom.
+func (s Exception) CopyFrom(src Exception) error { return s.Struct.CopyFrom(src.Struct) }
+
 // Exception_Promise is a wrapper for a Exception promised by a client call.
 type Exception_Promise struct{ *capnp.Pipeline }
 
@@ -97,14 +97,17 @@ type writeDeadlineSetter interface {
 }
 
 // dispatchSend runs in its own goroutine and sends messages on a transport.
-func dispatchSend(m *manager, transport Transport, msgs <-chan rpccapnp.Message) {
+func dispatchSend(m *manager, transport Transport, hooks *Hooks, msgs <-chan rpccapnp.Message) {
 	for {
 		select {
 		case msg := <-msgs:
 			err := transport.SendMessage(m.context(), msg)
 			if err != nil {
 				log.Printf("rpc: writing %v: %v", msg.Which(), err)
+				hooks.error(err)
+				continue
 			}
+			hooks.send(msg)
 		case <-m.finish:
 			return
 		}
@@ -123,10 +126,11 @@ func sendMessage(m *manager, out chan<- rpccapnp.Message, msg rpccapnp.Message)
 }
 
 // dispatchRecv runs in its own goroutine and receives messages from a transport.
-func dispatchRecv(m *manager, transport Transport, msgs chan<- rpccapnp.Message) {
+func dispatchRecv(m *manager, transport Transport, hooks *Hooks, msgs chan<- rpccapnp.Message) {
 	for {
 		msg, err := transport.RecvMessage(m.context())
 		if err != nil {
+			hooks.error(err)
 			if isTemporaryError(err) {
 				log.Println("rpc: read temporary error:", err)
 				continue
@@ -134,6 +138,7 @@ func dispatchRecv(m *manager, transport Transport, msgs chan<- rpccapnp.Message)
 			m.shutdown(err)
 			return
 		}
+		hooks.recv(msg)
 		select {
 		case msgs <- copyRPCMessage(msg):
 		case <-m.finish:
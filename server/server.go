@@ -84,7 +84,7 @@ dispatch:
 
 // startCall runs in the dispatch goroutine to start a call.
 func (s *server) startCall(cl *call) error {
-	_, out, err := capnp.NewMessage(capnp.SingleSegment(nil))
+	_, out, err := capnp.NewDefaultMessage()
 	if err != nil {
 		return err
 	}
@@ -1,5 +1,11 @@
 package capnp
 
+import (
+	"bytes"
+	"io"
+	"math"
+)
+
 // Struct is a pointer to a struct.
 type Struct struct {
 	seg   *Segment
@@ -51,6 +57,20 @@ func ToStruct(p Pointer) Struct {
 	return s
 }
 
+// ToStructErr converts p into a struct, returning an error if p is
+// a valid pointer to an object other than a struct.  A null pointer
+// results in an invalid Struct and no error.
+func ToStructErr(p Pointer) (Struct, error) {
+	if !IsValid(p) {
+		return Struct{}, nil
+	}
+	s, ok := p.underlying().(Struct)
+	if !ok {
+		return Struct{}, newPointerTypeError("struct", p)
+	}
+	return s, nil
+}
+
 // ToStructDefault attempts to convert p into a struct, reading the
 // default value from def if p is not a struct.
 func ToStructDefault(p Pointer, def []byte) (Struct, error) {
@@ -74,16 +94,127 @@ func ToStructDefault(p Pointer, def []byte) (Struct, error) {
 	return s, nil
 }
 
+// ReinterpretStruct returns a view of s's memory as a struct of size
+// sz, without copying.  The view's data section starts where s's does
+// and its pointer section immediately follows its data section, so
+// the view's pointers only line up with s's if the data sizes match.
+// ReinterpretStruct only checks that sz fits within s's allocation;
+// whether the view's fields mean anything is up to the caller.  An
+// invalid s results in an invalid Struct.
+func ReinterpretStruct(s Struct, sz ObjectSize) (Struct, error) {
+	if s.seg == nil {
+		return Struct{}, nil
+	}
+	if !sz.isValid() {
+		return Struct{}, errObjectSize
+	}
+	sz.DataSize = sz.DataSize.padToWord()
+	if sz.totalSize() > s.size.totalSize() {
+		return Struct{}, errReinterpretSize
+	}
+	return Struct{
+		seg:   s.seg,
+		off:   s.off,
+		size:  sz,
+		flags: s.flags,
+	}, nil
+}
+
+// Upgrade makes p at least as large as sz, so that fields added in a
+// newer version of the schema can be set.  If p is smaller in either
+// section, Upgrade allocates a struct of the larger size in each
+// section, preferring placement in p's segment, copies p's data and
+// pointers into it as by CopyFrom, changes the pointer that refers to
+// p to refer to the new struct, and changes p to match.  That pointer
+// is parent's i'th pointer, or the message's root pointer if parent is
+// the zero Struct; Upgrade returns an error if it doesn't point to p.
+// The old struct is left orphaned, so other pointers to it still see
+// the old copy.  An element of a composite list can't be upgraded,
+// since its size is set by the list.
+//
+// A Struct does not record where the pointer to it is stored: it may
+// have been reached through any number of pointers, or through a far
+// pointer whose landing pad is in another segment.  That is why the
+// caller names the pointer to rewrite with parent and i, instead of
+// Upgrade taking only the new size.
+func (p *Struct) Upgrade(parent Struct, i uint16, sz ObjectSize) error {
+	if p.seg == nil {
+		return errCopyInvalid
+	}
+	if !sz.isValid() {
+		return errObjectSize
+	}
+	sz.DataSize = sz.DataSize.padToWord()
+	if sz.DataSize <= p.size.DataSize && sz.PointerCount <= p.size.PointerCount {
+		return nil
+	}
+	if p.flags&isListMember != 0 {
+		return errUpgradeListMember
+	}
+	var ref Pointer
+	var err error
+	if parent.seg == nil {
+		ref, err = p.seg.msg.Root()
+	} else if i < parent.size.PointerCount {
+		ref, err = parent.Pointer(i)
+	}
+	if err != nil {
+		return err
+	}
+	if r := ToStruct(ref); r.seg != p.seg || r.off != p.off {
+		return errUpgradeParent
+	}
+	if sz.DataSize < p.size.DataSize {
+		sz.DataSize = p.size.DataSize
+	}
+	if sz.PointerCount < p.size.PointerCount {
+		sz.PointerCount = p.size.PointerCount
+	}
+	up, err := NewStruct(p.seg, sz)
+	if err != nil {
+		return err
+	}
+	if err := up.CopyFrom(*p); err != nil {
+		return err
+	}
+	if parent.seg == nil {
+		err = p.seg.msg.SetRoot(up)
+	} else {
+		err = parent.SetPointer(i, up)
+	}
+	if err != nil {
+		return err
+	}
+	*p = up
+	return nil
+}
+
 // Segment returns the segment this pointer came from.
 func (p Struct) Segment() *Segment {
 	return p.seg
 }
 
+// Message returns the message that contains the struct, or nil if the
+// struct is invalid.  Generated struct types get this method too, so
+// they can be marshaled or made the root without going through Segment.
+func (p Struct) Message() *Message {
+	if p.seg == nil {
+		return nil
+	}
+	return p.seg.msg
+}
+
 // Address returns the address the pointer references.
 func (p Struct) Address() Address {
 	return p.off
 }
 
+// Size returns the size of the struct's data and pointer sections.
+// The size of an invalid struct is zero.
+func (p Struct) Size() ObjectSize {
+	return p.size
+}
+
 // HasData reports whether the struct has a non-zero size.
 func (p Struct) HasData() bool {
 	return !p.size.isZero()
@@ -91,6 +222,11 @@ func (p Struct) HasData() bool {
 
 // value returns a raw struct pointer.
 func (p Struct) value(paddr Address) rawPointer {
+	if p.size.isZero() {
+		// A zero-sized struct placed directly after its pointer would
+		// encode as a null pointer, so use the canonical offset of -1.
+		return rawStructPointer(-1, p.size)
+	}
 	off := makePointerOffset(paddr, p.off)
 	return rawStructPointer(off, p.size)
 }
@@ -115,6 +251,120 @@ func (p Struct) SetPointer(i uint16, src Pointer) error {
 	return p.seg.writePtr(copyContext{}, p.pointerAddress(i), src)
 }
 
+// Disown sets the i'th pointer in the struct to null and returns the
+// object it referenced, which may be a struct, a list, or an interface.
+// The object stays where it is in the message, so it can be moved to
+// another field with Adopt without copying.
+func (p Struct) Disown(i uint16) (Pointer, error) {
+	ptr, err := p.Pointer(i)
+	if err != nil {
+		return nil, err
+	}
+	if p.seg != nil && i < p.size.PointerCount {
+		if p.seg.readOnly {
+			return nil, errReadOnly
+		}
+		p.seg.writeRawPointer(p.pointerAddress(i), 0)
+	}
+	return ptr, nil
+}
+
+// Adopt sets the i'th pointer in the struct to the object orph,
+// typically one returned by Disown.  If orph is in the same message,
+// then the pointer references it in place; otherwise orph is copied
+// into the struct's message, as with SetPointer.
+func (p Struct) Adopt(i uint16, orph Pointer) error {
+	return p.SetPointer(i, orph)
+}
+
+// SetDataFromReader sets the i'th pointer in the struct to a new Data
+// of size bytes read from r.  The bytes are read directly into the
+// message, so size must fit in a single segment and in a list pointer,
+// which holds at most 1<<29 - 1 elements.  It is an error for r to
+// produce fewer or more than size bytes; in that case the pointer is
+// left unchanged, but the space allocated for the data is not reclaimed.
+// To detect extra data, SetDataFromReader reads one byte past size, so
+// r should hold just the data: if it is followed by anything else, the
+// first byte after the data is consumed.  Wrap r with io.LimitReader
+// to read a length-prefixed field out of a larger stream.
+func (p Struct) SetDataFromReader(i uint16, r io.Reader, size int64) error {
+	if p.seg == nil || i >= p.size.PointerCount {
+		panic(errOutOfBounds)
+	}
+	if size < 0 || size > maxListLen {
+		return errOverlarge
+	}
+	l, err := NewUInt8List(p.seg, int32(size))
+	if err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(r, l.seg.slice(l.off, Size(size))); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	var extra [1]byte
+	switch _, err := io.ReadFull(r, extra[:]); err {
+	case io.EOF:
+	case nil:
+		return errDataTooLong
+	default:
+		return err
+	}
+	return p.SetPointer(i, l)
+}
+
+// DataReader returns a reader over the Data in the i'th pointer.  It
+// reads straight from the message without copying, so the message must
+// not be modified while the reader is in use.
+func (p Struct) DataReader(i uint16) (io.Reader, error) {
+	ptr, err := p.Pointer(i)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(ToData(ptr)), nil
+}
+
+// maxListLen is the largest element count a list pointer can encode.
+const maxListLen = 1<<29 - 1
+
+// HasPointer reports whether the i'th pointer in the struct is non-null.
+func (p Struct) HasPointer(i uint16) bool {
+	if p.seg == nil || i >= p.size.PointerCount {
+		return false
+	}
+	return p.seg.readRawPointer(p.pointerAddress(i)) != 0
+}
+
+// PresenceBits returns a bitmask of the struct's non-null pointers: bit
+// i is set if and only if pointer i is non-null.  Pointers past the
+// 64th are not represented; use PresenceBitmap for larger structs.
+func (p Struct) PresenceBits() uint64 {
+	var bits uint64
+	for i := uint16(0); i < p.size.PointerCount && i < 64; i++ {
+		if p.HasPointer(i) {
+			bits |= 1 << i
+		}
+	}
+	return bits
+}
+
+// PresenceBitmap returns a bitmap of the struct's non-null pointers:
+// bit i%8 of byte i/8 is set if and only if pointer i is non-null.
+func (p Struct) PresenceBitmap() []byte {
+	if p.seg == nil {
+		return nil
+	}
+	b := make([]byte, (int(p.size.PointerCount)+7)/8)
+	for i := uint16(0); i < p.size.PointerCount; i++ {
+		if p.HasPointer(i) {
+			b[i/8] |= 1 << (i % 8)
+		}
+	}
+	return b
+}
+
 func (p Struct) pointerAddress(i uint16) Address {
 	ptrStart := p.off.addSize(p.size.DataSize)
 	return ptrStart.element(int32(i), wordSize)
@@ -156,6 +406,45 @@ func (p Struct) dataAddress(off DataOffset, sz Size) (addr Address, ok bool) {
 	return p.off.addOffset(off), true
 }
 
+// ReadData copies bytes from the struct's data section, starting off
+// bytes in, into buf.  It returns the number of bytes copied, which is
+// less than len(buf) if the data section ends first.  It is an error
+// for off to be past the end of the data section, unless buf is empty.
+func (p Struct) ReadData(off DataOffset, buf []byte) (int, error) {
+	data, err := p.dataRange(off, len(buf))
+	if err != nil {
+		return 0, err
+	}
+	return copy(buf, data), nil
+}
+
+// WriteData copies data into the struct's data section, starting off
+// bytes in.  It returns the number of bytes copied, which is less than
+// len(data) if the data section ends first.  It is an error for off to
+// be past the end of the data section, unless data is empty.
+func (p Struct) WriteData(off DataOffset, data []byte) (int, error) {
+	dst, err := p.dataRange(off, len(data))
+	if err != nil {
+		return 0, err
+	}
+	if len(dst) > 0 && p.seg.readOnly {
+		return 0, errReadOnly
+	}
+	return copy(dst, data), nil
+}
+
+// dataRange returns the data section from off to its end for a
+// transfer of n bytes.
+func (p Struct) dataRange(off DataOffset, n int) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	if _, ok := p.dataAddress(off, 1); !ok {
+		return nil, errOutOfBounds
+	}
+	return p.seg.slice(p.off.addOffset(off), p.size.DataSize-Size(off)), nil
+}
+
 // Uint8 returns an 8-bit integer from the struct's data section.
 func (p Struct) Uint8(off DataOffset) uint8 {
 	addr, ok := p.dataAddress(off, 1)
@@ -228,6 +517,281 @@ func (p Struct) SetUint64(off DataOffset, v uint64) {
 	p.seg.writeUint64(addr, v)
 }
 
+// Float32 returns a 32-bit floating point number from the struct's
+// data section.  The bits are returned as is, so NaN payloads survive.
+func (p Struct) Float32(off DataOffset) float32 {
+	return math.Float32frombits(p.Uint32(off))
+}
+
+// Float64 returns a 64-bit floating point number from the struct's
+// data section.  The bits are returned as is, so NaN payloads survive.
+func (p Struct) Float64(off DataOffset) float64 {
+	return math.Float64frombits(p.Uint64(off))
+}
+
+// SetFloat32 sets the 32-bit floating point number that is off bytes
+// from the start of the struct to v, storing its bits unchanged.
+func (p Struct) SetFloat32(off DataOffset, v float32) {
+	p.SetUint32(off, math.Float32bits(v))
+}
+
+// SetFloat64 sets the 64-bit floating point number that is off bytes
+// from the start of the struct to v, storing its bits unchanged.
+func (p Struct) SetFloat64(off DataOffset, v float64) {
+	p.SetUint64(off, math.Float64bits(v))
+}
+
+// Clear sets all of the struct's fields to their default values by
+// zeroing the data section and setting every pointer to null.  Any
+// objects that the struct's pointers referenced are orphaned: they
+// remain in the message as dead space.  Clear does nothing on an
+// invalid struct.
+func (p Struct) Clear() {
+	if p.seg == nil {
+		return
+	}
+	b := p.seg.writeSlice(p.off, p.size.totalSize())
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// CopyFrom makes p a deep copy of src, which may have a different size,
+// such as a struct written with another version of the schema.  Data
+// past the end of p's data section is discarded, and p's data and
+// pointers past the end of src's are zeroed.  CopyFrom returns an
+// error without copying if src has a non-null pointer that p has no
+// room for.  Objects in another message are copied into p's message;
+// objects already in p's message are referenced in place.
+func (p Struct) CopyFrom(src Struct) error {
+	return p.copyFrom(src, false)
+}
+
+// CopyFromPreservingSharing is like CopyFrom, but copies each object
+// reachable from src into p's message only once.  Pointers that reach
+// the same object in src reach the same copy in p, and pointers back
+// to src reach p itself, so cyclic and shared sub-trees are linked up
+// instead of being copied again for every pointer that reaches them.
+func (p Struct) CopyFromPreservingSharing(src Struct) error {
+	return p.copyFrom(src, true)
+}
+
+// copyFrom implements CopyFrom and CopyFromPreservingSharing.
+func (p Struct) copyFrom(src Struct, share bool) error {
+	if p.seg == nil {
+		return errCopyInvalid
+	}
+	if p.seg.readOnly {
+		return errReadOnly
+	}
+	for i := p.size.PointerCount; i < src.size.PointerCount; i++ {
+		if src.HasPointer(i) {
+			return errCopyPointers
+		}
+	}
+	if src.seg == nil {
+		p.Clear()
+		return nil
+	}
+	cc := copyContext{}
+	if share {
+		// One set of copies for all of src's pointers, seeded with src
+		// itself.  Pointers can't reach list members on their own, so
+		// only seed when neither struct is one.
+		cc = cc.init()
+		if (src.flags|p.flags)&isListMember == 0 {
+			key := makeOffsetKey(src)
+			key.newval = p
+			cc.copies.Insert(key)
+		}
+	}
+	return copyStruct(cc, p, src)
+}
+
+// Equal reports whether p and o hold the same values, comparing their
+// data sections and the objects their pointers reach rather than their
+// layout.  The structs may have different sizes: as when reading a
+// struct written with a different version of its schema, missing data
+// is treated as zero and missing pointers as null.  Lists are compared
+// element by element in the same way, and interface pointers are equal
+// if they have the same capability ID.  Equal returns an error if the
+// pointers nest too deeply, which includes any pointer cycle.
+func (p Struct) Equal(o Struct) (bool, error) {
+	if (p.seg == nil) != (o.seg == nil) {
+		return false, nil
+	}
+	return newEqualWalker().structEqual(p, o, 0)
+}
+
+// An equalWalker compares objects for Struct.Equal.
+type equalWalker struct {
+	// equal holds the pairs of objects that have been found equal, so
+	// that objects referenced more than once are only compared once.
+	equal map[[2]objectKey]struct{}
+}
+
+func newEqualWalker() *equalWalker {
+	return &equalWalker{equal: make(map[[2]objectKey]struct{})}
+}
+
+// structEqual compares the sections of a and b.
+func (w *equalWalker) structEqual(a, b Struct, depth int) (bool, error) {
+	if depth >= maxSizeDepth {
+		return false, errEqualDepth
+	}
+	var ad, bd []byte
+	if a.seg != nil {
+		ad = a.seg.slice(a.off, a.size.DataSize)
+	}
+	if b.seg != nil {
+		bd = b.seg.slice(b.off, b.size.DataSize)
+	}
+	if len(ad) < len(bd) {
+		ad, bd = bd, ad
+	}
+	if !bytes.Equal(ad[:len(bd)], bd) {
+		return false, nil
+	}
+	for _, c := range ad[len(bd):] {
+		if c != 0 {
+			return false, nil
+		}
+	}
+	n := a.size.PointerCount
+	if b.size.PointerCount > n {
+		n = b.size.PointerCount
+	}
+	for i := uint16(0); i < n; i++ {
+		pa, err := a.Pointer(i)
+		if err != nil {
+			return false, err
+		}
+		pb, err := b.Pointer(i)
+		if err != nil {
+			return false, err
+		}
+		if eq, err := w.pointerEqual(pa, pb, depth+1); !eq || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// pointerEqual compares the objects a and b point to.
+func (w *equalWalker) pointerEqual(a, b Pointer, depth int) (bool, error) {
+	if !IsValid(a) || !IsValid(b) {
+		return !IsValid(a) && !IsValid(b), nil
+	}
+	switch a := a.underlying().(type) {
+	case Struct:
+		b, ok := b.underlying().(Struct)
+		if !ok {
+			return false, nil
+		}
+		k := [2]objectKey{{a.seg.id, a.off, false}, {b.seg.id, b.off, false}}
+		return w.object(k, func() (bool, error) {
+			return w.structEqual(a, b, depth)
+		})
+	case List:
+		b, ok := b.underlying().(List)
+		if !ok || a.Len() != b.Len() {
+			return false, nil
+		}
+		if aBits, bBits := a.flags&isBitList != 0, b.flags&isBitList != 0; aBits || bBits {
+			if !aBits || !bBits {
+				return false, nil
+			}
+			for i := 0; i < a.Len(); i++ {
+				if (BitList{a}).At(i) != (BitList{b}).At(i) {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+		k := [2]objectKey{{a.seg.id, a.off, true}, {b.seg.id, b.off, true}}
+		return w.object(k, func() (bool, error) {
+			for i := 0; i < a.Len(); i++ {
+				if eq, err := w.structEqual(a.Struct(i), b.Struct(i), depth+1); !eq || err != nil {
+					return false, err
+				}
+			}
+			return true, nil
+		})
+	case Interface:
+		b, ok := b.underlying().(Interface)
+		return ok && a.cap == b.cap, nil
+	}
+	return false, nil
+}
+
+// object reports whether the pair of objects k is equal, comparing
+// them with f if k has not been found equal yet.  Only equal pairs are
+// remembered: finding any pair unequal ends the whole comparison.
+func (w *equalWalker) object(k [2]objectKey, f func() (bool, error)) (bool, error) {
+	if _, ok := w.equal[k]; ok {
+		return true, nil
+	}
+	eq, err := f()
+	if !eq || err != nil {
+		return false, err
+	}
+	w.equal[k] = struct{}{}
+	return true, nil
+}
+
+// SizeDiff reports how many more bytes b and the objects reachable
+// from it would occupy than a and the objects reachable from it, when
+// each is copied into a new message.  The result is negative if b is
+// smaller.  a and b may have different sizes: a pointer that only one
+// side has counts toward that side alone.  An invalid struct has size
+// zero.  An object referenced more than once is counted each time, and
+// SizeDiff returns an error if either side adds up to more than a
+// segment can hold.
+func SizeDiff(a, b Struct) (int64, error) {
+	na, err := reachableSize(a)
+	if err != nil {
+		return 0, err
+	}
+	nb, err := reachableSize(b)
+	if err != nil {
+		return 0, err
+	}
+	return int64(nb) - int64(na), nil
+}
+
+// ComputeStructChecksum returns h applied to s's data section with the
+// excludeLen bytes at excludeOff left out, typically the field the
+// checksum is stored in.  The pointer section is not covered.  The
+// excluded range is clipped to the data section.  Storing the result
+// is left to the caller.  h must not retain or modify its argument,
+// which may alias the message.
+func ComputeStructChecksum(s Struct, excludeOff DataOffset, excludeLen Size, h func([]byte) uint64) uint64 {
+	if s.seg == nil {
+		return h(nil)
+	}
+	data := s.seg.slice(s.off, s.size.DataSize)
+	start := uint64(excludeOff)
+	end := start + uint64(excludeLen)
+	if start > uint64(len(data)) {
+		start = uint64(len(data))
+	}
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+	switch {
+	case start == end:
+		return h(data)
+	case start == 0:
+		return h(data[end:])
+	case end == uint64(len(data)):
+		return h(data[:start])
+	}
+	buf := make([]byte, 0, uint64(len(data))-(end-start))
+	buf = append(buf, data[:start]...)
+	buf = append(buf, data[end:]...)
+	return h(buf)
+}
+
 // structFlags is a bitmask of flags for a pointer.
 type structFlags uint8
 
@@ -241,6 +805,9 @@ func copyStruct(cc copyContext, dst, src Struct) error {
 	if dst.seg == nil {
 		return nil
 	}
+	if dst.seg.readOnly {
+		return errReadOnly
+	}
 
 	// Q: how does version handling happen here, when the
 	//    destination toData[] slice can be bigger or smaller
@@ -0,0 +1,1103 @@
+package capnp
+
+import (
+	"bytes"
+	"hash/fnv"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestStructClear(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0xdeadbeefcafebabe)
+	s.SetUint16(8, 42)
+	s.SetBit(100, true)
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	child, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child.SetUint64(0, 7)
+	if err := s.SetPointer(1, child); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Clear()
+
+	if v := s.Uint64(0); v != 0 {
+		t.Errorf("after Clear, s.Uint64(0) = %#x; want 0", v)
+	}
+	if v := s.Uint16(8); v != 0 {
+		t.Errorf("after Clear, s.Uint16(8) = %d; want 0", v)
+	}
+	if s.Bit(100) {
+		t.Error("after Clear, s.Bit(100) = true; want false")
+	}
+	for i := uint16(0); i < 2; i++ {
+		p, err := s.Pointer(i)
+		if err != nil {
+			t.Errorf("after Clear, s.Pointer(%d) error: %v", i, err)
+		}
+		if p != nil {
+			t.Errorf("after Clear, s.Pointer(%d) = %#v; want nil", i, p)
+		}
+	}
+	// The cleared struct is still usable.
+	s.SetUint64(0, 1)
+	if v := s.Uint64(0); v != 1 {
+		t.Errorf("s.Uint64(0) after Clear and SetUint64 = %d; want 1", v)
+	}
+}
+
+func TestStructClearInvalid(t *testing.T) {
+	err := catchPanic(func() {
+		Struct{}.Clear()
+	})
+	if err != nil {
+		t.Errorf("Struct{}.Clear() panicked: %v", err)
+	}
+}
+
+func TestToStructErr(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ptr    Pointer
+		s      Struct
+		errMsg string
+	}{
+		{ptr: nil, s: Struct{}},
+		{ptr: Struct{}, s: Struct{}},
+		{ptr: Struct{seg: seg, off: 8, size: ObjectSize{DataSize: 8}}, s: Struct{seg: seg, off: 8, size: ObjectSize{DataSize: 8}}},
+		{ptr: List{seg: seg, off: 8, length: 1, size: ObjectSize{DataSize: 8}}, errMsg: "capnp: expected struct pointer, got list pointer"},
+		{ptr: Interface{seg: seg, cap: 0}, errMsg: "capnp: expected struct pointer, got interface pointer"},
+	}
+	for _, test := range tests {
+		s, err := ToStructErr(test.ptr)
+		if test.errMsg != "" {
+			if err == nil {
+				t.Errorf("ToStructErr(%#v) = %#v, <nil>; want error %q", test.ptr, s, test.errMsg)
+			} else if err.Error() != test.errMsg {
+				t.Errorf("ToStructErr(%#v) error = %q; want %q", test.ptr, err.Error(), test.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ToStructErr(%#v) error: %v", test.ptr, err)
+			continue
+		}
+		if s != test.s {
+			t.Errorf("ToStructErr(%#v) = %#v; want %#v", test.ptr, s, test.s)
+		}
+	}
+}
+
+func TestStructPresence(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 70})
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt, err := NewText(seg, "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := []uint16{0, 2, 3, 63, 64, 69}
+	for _, i := range set {
+		if err := s.SetPointer(i, txt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const wantBits = 1<<0 | 1<<2 | 1<<3 | 1<<63
+	if bits := s.PresenceBits(); bits != wantBits {
+		t.Errorf("PresenceBits() = %#x; want %#x", bits, uint64(wantBits))
+	}
+	wantMap := []byte{0x0d, 0, 0, 0, 0, 0, 0, 0x80, 0x21}
+	if bm := s.PresenceBitmap(); !bytes.Equal(bm, wantMap) {
+		t.Errorf("PresenceBitmap() = % 02x; want % 02x", bm, wantMap)
+	}
+	for _, test := range []struct {
+		i   uint16
+		has bool
+	}{{0, true}, {1, false}, {63, true}, {65, false}, {69, true}, {70, false}} {
+		if has := s.HasPointer(test.i); has != test.has {
+			t.Errorf("HasPointer(%d) = %t; want %t", test.i, has, test.has)
+		}
+	}
+
+	if bits := (Struct{}).PresenceBits(); bits != 0 {
+		t.Errorf("Struct{}.PresenceBits() = %#x; want 0", bits)
+	}
+	if bm := (Struct{}).PresenceBitmap(); bm != nil {
+		t.Errorf("Struct{}.PresenceBitmap() = % 02x; want nil", bm)
+	}
+}
+
+func TestSizeDiff(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a: 16 bytes of struct + 8 bytes of text.
+	a, err := NewStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.SetPointer(0, hello); err != nil {
+		t.Fatal(err)
+	}
+	// b: 32 bytes of struct + 8 bytes of text + a composite list with
+	// a tag word, two 16-byte elements, and 8 bytes of text for each.
+	b, err := NewStruct(seg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetPointer(0, hello); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8, PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < l.Len(); i++ {
+		txt, err := NewText(seg, "abc")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Struct(i).SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.SetPointer(1, l); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b Struct
+		want int64
+	}{
+		{a, b, 72},
+		{b, a, -72},
+		{a, a, 0},
+		{Struct{}, a, 24},
+		{b, Struct{}, -96},
+	}
+	for _, test := range tests {
+		d, err := SizeDiff(test.a, test.b)
+		if err != nil {
+			t.Errorf("SizeDiff(%v, %v) error: %v", test.a, test.b, err)
+			continue
+		}
+		if d != test.want {
+			t.Errorf("SizeDiff(%v, %v) = %d; want %d", test.a, test.b, d, test.want)
+		}
+	}
+
+	// The walk agrees with the size of a copy.
+	msg, seg2, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.SetRoot(b); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(seg2.Data()) - int(wordSize); n != 96 {
+		t.Errorf("copy of b occupies %d bytes; want 96", n)
+	}
+}
+
+func TestSizeDiffCycle(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SizeDiff(Struct{}, s); err == nil {
+		t.Error("SizeDiff on a cyclic struct returned nil error")
+	}
+}
+
+func TestSizeDiffShared(t *testing.T) {
+	// Each level is a 16-byte struct with two pointers to the level
+	// below, which ends in an 8-byte struct.
+	d, err := SizeDiff(Struct{}, sharedTree(t, 10))
+	if want := int64(16*(1<<10-1) + 8<<10); err != nil || d != want {
+		t.Errorf("SizeDiff(Struct{}, sharedTree(10)) = %d, %v; want %d, <nil>", d, err, want)
+	}
+	if _, err := SizeDiff(Struct{}, sharedTree(t, 60)); err != errOverlarge {
+		t.Errorf("SizeDiff(Struct{}, sharedTree(60)) error = %v; want %v", err, errOverlarge)
+	}
+}
+
+func TestZeroSizeStruct(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := NewStruct(seg, ObjectSize{})
+	if err != nil {
+		t.Fatalf("NewStruct(ObjectSize{}) error: %v", err)
+	}
+	if !IsValid(empty) {
+		t.Fatal("NewStruct(ObjectSize{}) returned an invalid struct")
+	}
+	if empty.HasData() {
+		t.Error("empty.HasData() = true; want false")
+	}
+	// The empty struct is allocated directly after root's last pointer,
+	// so a naive encoding would have a zero offset and read back as null.
+	if err := root.SetPointer(1, empty); err != nil {
+		t.Fatalf("SetPointer(1, empty) error: %v", err)
+	}
+	if !root.HasPointer(1) {
+		t.Error("root.HasPointer(1) = false after setting an empty struct")
+	}
+	p, err := root.Pointer(1)
+	if err != nil {
+		t.Fatalf("root.Pointer(1) error: %v", err)
+	}
+	if s := ToStruct(p); !IsValid(s) || s.HasData() {
+		t.Errorf("root.Pointer(1) = %#v; want valid empty struct", p)
+	}
+
+	// Round trip through serialization.
+	data, err := msg.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg2, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp, err := msg2.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = ToStruct(rp).Pointer(1)
+	if err != nil {
+		t.Fatalf("after round trip, root.Pointer(1) error: %v", err)
+	}
+	if !IsValid(p) {
+		t.Error("after round trip, root.Pointer(1) is null; want empty struct")
+	}
+
+	// Copy into another message.
+	msg3, seg3, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg3.SetRoot(root); err != nil {
+		t.Fatalf("copying struct with empty child: %v", err)
+	}
+	rp, err = msg3.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = ToStruct(rp).Pointer(1)
+	if err != nil {
+		t.Fatalf("after copy, root.Pointer(1) error: %v", err)
+	}
+	if !IsValid(p) {
+		t.Error("after copy, root.Pointer(1) is null; want empty struct")
+	}
+	if n := len(seg3.Data()); n != 24 {
+		t.Errorf("copied message is %d bytes; want 24", n)
+	}
+
+	// An empty struct can be the root.
+	msg4, seg4, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRootStruct(seg4, ObjectSize{}); err != nil {
+		t.Fatalf("NewRootStruct(ObjectSize{}) error: %v", err)
+	}
+	rp, err = msg4.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsValid(rp) {
+		t.Error("empty root struct reads back as null")
+	}
+}
+
+func TestStructDisownAdoptList(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	big, err := NewUInt8List(seg, 1<<16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big.Set(1000, 0xaa)
+	if err := s.SetPointer(0, big); err != nil {
+		t.Fatal(err)
+	}
+	before := len(seg.Data())
+
+	orph, err := s.Disown(0)
+	if err != nil {
+		t.Fatal("Disown(0):", err)
+	}
+	if s.HasPointer(0) {
+		t.Error("after Disown(0), HasPointer(0) = true")
+	}
+	if err := s.Adopt(1, orph); err != nil {
+		t.Fatal("Adopt(1):", err)
+	}
+	if n := len(seg.Data()); n != before {
+		t.Errorf("segment grew from %d to %d bytes; want no allocation", before, n)
+	}
+	p, err := s.Pointer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := UInt8List{ToList(p)}
+	if l.Len() != 1<<16 || l.At(1000) != 0xaa {
+		t.Errorf("Pointer(1) = list of %d with [1000] = %#x; want %d with %#x", l.Len(), l.At(1000), 1<<16, 0xaa)
+	}
+	if l.Address() != big.Address() {
+		t.Errorf("adopted list address = %v; want %v", l.Address(), big.Address())
+	}
+
+	if p, err := (Struct{}).Disown(0); p != nil || err != nil {
+		t.Errorf("Struct{}.Disown(0) = %v, %v; want <nil>, <nil>", p, err)
+	}
+}
+
+func TestReinterpretStruct(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStruct(seg, ObjectSize{DataSize: 16, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0x1234)
+	s.SetUint64(8, 0x5678)
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+
+	// A prefix view shares memory with s.
+	v, err := ReinterpretStruct(s, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal("ReinterpretStruct to smaller size:", err)
+	}
+	if x := v.Uint64(0); x != 0x1234 {
+		t.Errorf("view.Uint64(0) = %#x; want 0x1234", x)
+	}
+	if x := v.Uint64(8); x != 0 {
+		t.Errorf("view.Uint64(8) = %#x; want 0 (outside view)", x)
+	}
+	v.SetUint64(0, 0xabcd)
+	if x := s.Uint64(0); x != 0xabcd {
+		t.Errorf("after writing through view, s.Uint64(0) = %#x; want 0xabcd", x)
+	}
+
+	// Same data size keeps pointers in place.
+	v, err = ReinterpretStruct(s, ObjectSize{DataSize: 16, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := v.Pointer(0); err != nil || ToText(p) != "hi" {
+		t.Errorf("view.Pointer(0) = %v, %v; want \"hi\"", p, err)
+	}
+
+	for _, sz := range []ObjectSize{
+		{DataSize: 24, PointerCount: 1},
+		{DataSize: 16, PointerCount: 2},
+		{DataSize: 32, PointerCount: 0},
+	} {
+		if _, err := ReinterpretStruct(s, sz); err != errReinterpretSize {
+			t.Errorf("ReinterpretStruct(s, %v) error = %v; want %v", sz, err, errReinterpretSize)
+		}
+	}
+	if v, err := ReinterpretStruct(Struct{}, ObjectSize{DataSize: 8}); err != nil || IsValid(v) {
+		t.Errorf("ReinterpretStruct(Struct{}, ...) = %#v, %v; want invalid, <nil>", v, err)
+	}
+}
+
+func TestStructUpgrade(t *testing.T) {
+	msg, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 42)
+	txt, err := NewText(seg, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+
+	old := s
+	if err := s.Upgrade(Struct{}, 0, ObjectSize{DataSize: 4, PointerCount: 1}); err != nil {
+		t.Fatal("Upgrade to smaller size:", err)
+	}
+	if s != old {
+		t.Error("Upgrade to smaller size moved the struct")
+	}
+	if err := s.Upgrade(Struct{}, 0, ObjectSize{DataSize: 16, PointerCount: 2}); err != nil {
+		t.Fatal("Upgrade:", err)
+	}
+	if sz := s.Size(); sz != (ObjectSize{DataSize: 16, PointerCount: 2}) {
+		t.Errorf("after Upgrade, Size() = %v; want {16 2}", sz)
+	}
+	s.SetUint64(8, 7)
+	// The root pointer now refers to the upgraded struct.
+	root, err := msg.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := ToStruct(root)
+	if v := rs.Uint64(0); v != 42 {
+		t.Errorf("upgraded Uint64(0) = %d; want 42", v)
+	}
+	if v := rs.Uint64(8); v != 7 {
+		t.Errorf("upgraded Uint64(8) = %d; want 7", v)
+	}
+	if p, err := rs.Pointer(0); err != nil || ToText(p) != "old" {
+		t.Errorf("upgraded Pointer(0) = %q, %v; want \"old\", <nil>", ToText(p), err)
+	}
+
+	child, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child.SetUint64(0, 3)
+	if err := s.SetPointer(1, child); err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Upgrade(s, 0, ObjectSize{DataSize: 16}); err != errUpgradeParent {
+		t.Errorf("Upgrade with wrong parent pointer error = %v; want %v", err, errUpgradeParent)
+	}
+	if err := child.Upgrade(s, 1, ObjectSize{DataSize: 16}); err != nil {
+		t.Fatal("Upgrade of child:", err)
+	}
+	child.SetUint64(8, 4)
+	if p, err := s.Pointer(1); err != nil {
+		t.Fatal(err)
+	} else if c := ToStruct(p); c.Uint64(0) != 3 || c.Uint64(8) != 4 {
+		t.Errorf("child re-read through parent = %d, %d; want 3, 4", c.Uint64(0), c.Uint64(8))
+	}
+
+	l, err := NewCompositeList(seg, ObjectSize{DataSize: 8}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elem := l.Struct(0)
+	if err := elem.Upgrade(Struct{}, 0, ObjectSize{DataSize: 16}); err != errUpgradeListMember {
+		t.Errorf("Upgrade of list element error = %v; want %v", err, errUpgradeListMember)
+	}
+}
+
+func TestComputeStructChecksum(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Layout: a @0 :UInt64, checksum @1 :UInt64, b @2 :UInt64, one pointer.
+	s, err := NewStruct(seg, ObjectSize{DataSize: 24, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0x1111)
+	s.SetUint64(16, 0x2222)
+	fnv64 := func(b []byte) uint64 {
+		h := fnv.New64a()
+		h.Write(b)
+		return h.Sum64()
+	}
+
+	sum := ComputeStructChecksum(s, 8, 8, fnv64)
+	want := fnv64([]byte{
+		0x11, 0x11, 0, 0, 0, 0, 0, 0,
+		0x22, 0x22, 0, 0, 0, 0, 0, 0,
+	})
+	if sum != want {
+		t.Fatalf("ComputeStructChecksum = %#x; want %#x", sum, want)
+	}
+	s.SetUint64(8, sum)
+	if got := ComputeStructChecksum(s, 8, 8, fnv64); got != sum {
+		t.Errorf("after storing checksum, ComputeStructChecksum = %#x; want %#x", got, sum)
+	}
+	txt, err := NewText(seg, "not covered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	if got := ComputeStructChecksum(s, 8, 8, fnv64); got != sum {
+		t.Errorf("after setting pointer, ComputeStructChecksum = %#x; want %#x", got, sum)
+	}
+	s.SetUint64(16, 0x2223)
+	if got := ComputeStructChecksum(s, 8, 8, fnv64); got == sum {
+		t.Error("ComputeStructChecksum did not change after modifying covered data")
+	}
+
+	if got, want := ComputeStructChecksum(s, 16, 100, fnv64), fnv64(s.seg.slice(s.off, 16)); got != want {
+		t.Errorf("ComputeStructChecksum with trailing exclusion = %#x; want %#x", got, want)
+	}
+	if got, want := ComputeStructChecksum(Struct{}, 0, 8, fnv64), fnv64(nil); got != want {
+		t.Errorf("ComputeStructChecksum(Struct{}) = %#x; want %#x", got, want)
+	}
+}
+
+func TestSetDataFromReader(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStruct(seg, ObjectSize{PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := incrementingData(1000)
+	if err := s.SetDataFromReader(0, bytes.NewReader(want), int64(len(want))); err != nil {
+		t.Fatal("SetDataFromReader:", err)
+	}
+	r, err := s.DataReader(0)
+	if err != nil {
+		t.Fatal("DataReader:", err)
+	}
+	var got bytes.Buffer
+	if _, err := got.ReadFrom(r); err != nil {
+		t.Fatal("reading DataReader:", err)
+	}
+	if !bytes.Equal(got.Bytes(), want) {
+		t.Errorf("DataReader read %d bytes that differ from what was set", got.Len())
+	}
+
+	if err := s.SetDataFromReader(0, bytes.NewReader(want[:10]), 11); err == nil {
+		t.Error("SetDataFromReader with short reader succeeded")
+	}
+	long := bytes.NewReader(want[:13])
+	if err := s.SetDataFromReader(0, long, 11); err == nil {
+		t.Error("SetDataFromReader with long reader succeeded")
+	}
+	if long.Len() != 1 {
+		t.Errorf("SetDataFromReader with long reader left %d bytes unread; want 1", long.Len())
+	}
+	if p, _ := s.Pointer(0); len(ToData(p)) != len(want) {
+		t.Errorf("after failed SetDataFromReader, data length = %d; want %d", len(ToData(p)), len(want))
+	}
+	if err := s.SetDataFromReader(0, bytes.NewReader(nil), -1); err == nil {
+		t.Error("SetDataFromReader with negative size succeeded")
+	}
+	if err := s.SetDataFromReader(0, io.LimitReader(bytes.NewReader(want), 11), 11); err != nil {
+		t.Error("SetDataFromReader with limited reader:", err)
+	}
+}
+
+func TestStructEqual(t *testing.T) {
+	build := func(arena Arena, extra uint16) Struct {
+		_, seg, err := NewMessage(arena)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// extra adds trailing zero data and null pointers, as a newer
+		// version of the schema would.
+		root, err := NewRootStruct(seg, ObjectSize{DataSize: 8 + 8*Size(extra), PointerCount: 3 + extra})
+		if err != nil {
+			t.Fatal(err)
+		}
+		root.SetUint64(0, 0xdead)
+		txt, err := NewText(seg, "hello")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := root.SetPointer(0, txt); err != nil {
+			t.Fatal(err)
+		}
+		l, err := NewCompositeList(seg, ObjectSize{DataSize: 8 * (1 + Size(extra))}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Struct(0).SetUint64(0, 1)
+		l.Struct(1).SetUint64(0, 2)
+		if err := root.SetPointer(1, l); err != nil {
+			t.Fatal(err)
+		}
+		bits, err := NewBitList(seg, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bits.Set(1, true)
+		if err := root.SetPointer(2, bits); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+	a := build(SingleSegment(nil), 0)
+	b := build(MultiSegment([][]byte{make([]byte, 0, 8)}), 2)
+	if eq, err := a.Equal(b); err != nil || !eq {
+		t.Errorf("a.Equal(b) = %t, %v; want true, <nil>", eq, err)
+	}
+	if eq, err := b.Equal(a); err != nil || !eq {
+		t.Errorf("b.Equal(a) = %t, %v; want true, <nil>", eq, err)
+	}
+
+	p, _ := b.Pointer(1)
+	ToList(p).Struct(1).SetUint64(0, 3)
+	if eq, err := a.Equal(b); err != nil || eq {
+		t.Errorf("after changing list element, a.Equal(b) = %t, %v; want false, <nil>", eq, err)
+	}
+	ToList(p).Struct(1).SetUint64(0, 2)
+	b.SetUint64(8, 1)
+	if eq, err := a.Equal(b); err != nil || eq {
+		t.Errorf("after setting extra data, a.Equal(b) = %t, %v; want false, <nil>", eq, err)
+	}
+	b.SetUint64(8, 0)
+	if err := b.SetPointer(4, NewInterface(b.Segment(), 0)); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := a.Equal(b); err != nil || eq {
+		t.Errorf("after setting extra pointer, a.Equal(b) = %t, %v; want false, <nil>", eq, err)
+	}
+	if eq, err := (Struct{}).Equal(Struct{}); err != nil || !eq {
+		t.Errorf("Struct{}.Equal(Struct{}) = %t, %v; want true, <nil>", eq, err)
+	}
+
+	// A struct whose only pointer points back at itself.
+	cyclic := &Message{Arena: SingleSegment([]byte{
+		0, 0, 0, 0, 0, 0, 1, 0,
+		0xfc, 0xff, 0xff, 0xff, 0, 0, 1, 0,
+	})}
+	rp, err := cyclic.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ToStruct(rp).Equal(ToStruct(rp)); err == nil {
+		t.Error("Equal on cyclic struct succeeded")
+	}
+}
+
+func TestStructEqualShared(t *testing.T) {
+	a, b := sharedTree(t, 60), sharedTree(t, 60)
+	if eq, err := a.Equal(b); !eq || err != nil {
+		t.Errorf("Equal of two shared trees = %t, %v; want true, <nil>", eq, err)
+	}
+
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := NewStruct(seg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf.SetUint64(0, 2)
+	c := shareStruct(t, leaf, 60)
+	if eq, err := a.Equal(c); eq || err != nil {
+		t.Errorf("Equal of shared trees with different leaves = %t, %v; want false, <nil>", eq, err)
+	}
+}
+
+func TestStructCopyFrom(t *testing.T) {
+	_, srcSeg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewStruct(srcSeg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.SetUint64(0, 1)
+	src.SetUint64(8, 2)
+	txt, err := NewText(srcSeg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+
+	_, dstSeg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Smaller in data, larger in pointers.
+	dst, err := NewStruct(dstSeg, ObjectSize{DataSize: 8, PointerCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst.SetUint64(0, 99)
+	if err := dst.SetPointer(2, txt); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.CopyFrom(src); err != nil {
+		t.Fatal("CopyFrom:", err)
+	}
+	if v := dst.Uint64(0); v != 1 {
+		t.Errorf("dst.Uint64(0) = %d; want 1", v)
+	}
+	p, err := dst.Pointer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ToText(p) != "hi" || p.Segment().Message() != dstSeg.Message() {
+		t.Errorf("dst pointer 0 = %q in %p; want \"hi\" in %p", ToText(p), p.Segment().Message(), dstSeg.Message())
+	}
+	if dst.HasPointer(2) {
+		t.Error("dst pointer 2 not cleared")
+	}
+
+	small, err := NewStruct(dstSeg, ObjectSize{DataSize: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := small.CopyFrom(src); err == nil {
+		t.Error("CopyFrom with non-null pointer that doesn't fit succeeded")
+	}
+	if err := (Struct{}).CopyFrom(src); err == nil {
+		t.Error("CopyFrom into invalid struct succeeded")
+	}
+}
+
+func TestStructCopyFromPreservingSharing(t *testing.T) {
+	_, srcSeg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := NewRootStruct(srcSeg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := NewStruct(srcSeg, ObjectSize{DataSize: 8, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child.SetUint64(0, 42)
+	// Both of src's pointers reach child, and child points back to src.
+	for i := uint16(0); i < 2; i++ {
+		if err := src.SetPointer(i, child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := child.SetPointer(0, src); err != nil {
+		t.Fatal(err)
+	}
+
+	_, dstSeg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := NewRootStruct(dstSeg, ObjectSize{PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.CopyFromPreservingSharing(src); err != nil {
+		t.Fatal("CopyFromPreservingSharing:", err)
+	}
+	p0, err := dst.Pointer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, err := dst.Pointer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c0, c1 := ToStruct(p0), ToStruct(p1)
+	if c0.Segment() != c1.Segment() || c0.Address() != c1.Address() {
+		t.Errorf("dst pointers reach %v and %v; want the same struct", c0.Address(), c1.Address())
+	}
+	if v := c0.Uint64(0); v != 42 {
+		t.Errorf("copied child Uint64(0) = %d; want 42", v)
+	}
+	back, err := c0.Pointer(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := ToStruct(back); b.Segment() != dst.Segment() || b.Address() != dst.Address() {
+		t.Errorf("copied child points to %v; want dst at %v", b.Address(), dst.Address())
+	}
+	if n := len(dstSeg.Data()); n != 5*int(wordSize) {
+		t.Errorf("dst message is %d bytes; want %d (root pointer, dst, and one child)", n, 5*int(wordSize))
+	}
+}
+
+func TestStructReadWriteData(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewStruct(seg, ObjectSize{DataSize: 16, PointerCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.WriteData(4, []byte{1, 2, 3, 4}); n != 4 || err != nil {
+		t.Errorf("WriteData(4, 4 bytes) = %d, %v; want 4, <nil>", n, err)
+	}
+	if v := s.Uint32(4); v != 0x04030201 {
+		t.Errorf("after WriteData, Uint32(4) = %#x; want 0x04030201", v)
+	}
+	// Writes stop at the end of the data section, before the pointers.
+	if n, err := s.WriteData(14, []byte{0xff, 0xff, 0xff, 0xff}); n != 2 || err != nil {
+		t.Errorf("WriteData(14, 4 bytes) = %d, %v; want 2, <nil>", n, err)
+	}
+	if s.HasPointer(0) {
+		t.Error("WriteData past the data section wrote into the pointer section")
+	}
+
+	buf := make([]byte, 32)
+	n, err := s.ReadData(0, buf)
+	if n != 16 || err != nil {
+		t.Fatalf("ReadData(0, 32 bytes) = %d, %v; want 16, <nil>", n, err)
+	}
+	want := []byte{0, 0, 0, 0, 1, 2, 3, 4, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("ReadData = % x; want % x", buf[:n], want)
+	}
+
+	if _, err := s.ReadData(16, buf); err == nil {
+		t.Error("ReadData(16, ...) succeeded; want out of bounds error")
+	}
+	if _, err := s.WriteData(16, []byte{1}); err == nil {
+		t.Error("WriteData(16, ...) succeeded; want out of bounds error")
+	}
+	if n, err := s.ReadData(16, nil); n != 0 || err != nil {
+		t.Errorf("ReadData(16, nil) = %d, %v; want 0, <nil>", n, err)
+	}
+	if _, err := (Struct{}).ReadData(0, buf); err == nil {
+		t.Error("Struct{}.ReadData succeeded; want error")
+	}
+}
+
+func TestStructFloat(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetFloat64(0, -2.5)
+	s.SetFloat32(8, 3.25)
+	if got := s.Float64(0); got != -2.5 {
+		t.Errorf("Float64(0) = %v; want -2.5", got)
+	}
+	if got := s.Float32(8); got != 3.25 {
+		t.Errorf("Float32(8) = %v; want 3.25", got)
+	}
+	if got, want := s.Uint64(0), math.Float64bits(-2.5); got != want {
+		t.Errorf("Uint64(0) = %#x; want %#x", got, want)
+	}
+
+	const nan64, nan32 = 0x7ff8000000000123, 0x7fc00123
+	s.SetFloat64(0, math.Float64frombits(nan64))
+	s.SetFloat32(8, math.Float32frombits(nan32))
+	if got := math.Float64bits(s.Float64(0)); got != nan64 {
+		t.Errorf("Float64 NaN bits = %#x; want %#x", got, uint64(nan64))
+	}
+	if got := math.Float32bits(s.Float32(8)); got != nan32 {
+		t.Errorf("Float32 NaN bits = %#x; want %#x", got, uint32(nan32))
+	}
+	if got := s.Uint64(0); got != nan64 {
+		t.Errorf("Uint64(0) = %#x; want %#x", got, uint64(nan64))
+	}
+}
+
+func TestStructSize(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ObjectSize{DataSize: 16, PointerCount: 3}
+	s, err := NewRootStruct(seg, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Size(); got != want {
+		t.Errorf("Size() = %v; want %v", got, want)
+	}
+	if got := (Struct{}).Size(); got != (ObjectSize{}) {
+		t.Errorf("Struct{}.Size() = %v; want zero", got)
+	}
+}
+
+func TestDump(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 8, PointerCount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint64(0, 0x2a)
+	txt, err := NewText(seg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(0, txt); err != nil {
+		t.Fatal(err)
+	}
+	nums, err := NewInt16List(seg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nums.Set(0, 1)
+	nums.Set(1, 2)
+	nums.Set(2, 3)
+	if err := s.SetPointer(2, nums); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewCompositeList(seg, ObjectSize{PointerCount: 1}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Struct(1).SetPointer(0, NewInterface(seg, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(3, l); err != nil {
+		t.Fatal(err)
+	}
+	bits, err := NewBitList(seg, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits.Set(1, true)
+	if err := s.SetPointer(4, bits); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Dump(s)
+	if err != nil {
+		t.Fatal("Dump:", err)
+	}
+	want := `(d0 = 0x2a, p0 = "hi", p1 = null, p2 = [1, 2, 3], p3 = [(p0 = null), (p0 = <capability 3>)], p4 = [false, true])`
+	if got != want {
+		t.Errorf("Dump =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDynamicStruct(t *testing.T) {
+	_, seg, err := NewMessage(SingleSegment(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewRootStruct(seg, ObjectSize{DataSize: 16, PointerCount: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetUint16(2, 0xfffe^7) // stored XOR default
+	s.SetBit(32, true)
+	s.SetUint64(8, math.Float64bits(1.5))
+	s.SetUint16(0, 1) // union discriminant
+	txt, err := NewText(seg, "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPointer(1, txt); err != nil {
+		t.Fatal(err)
+	}
+	typ := &StructSchema{
+		Fields: []FieldSchema{
+			{Name: "count", Kind: Int16Field, Offset: 1, Default: 7, Discriminant: NoDiscriminant},
+			{Name: "flag", Kind: BoolField, Offset: 32, Discriminant: NoDiscriminant},
+			{Name: "ratio", Kind: Float64Field, Offset: 1, Discriminant: NoDiscriminant},
+			{Name: "other", Kind: PointerField, Offset: 0, Discriminant: 0},
+			{Name: "name", Kind: TextField, Offset: 1, Discriminant: 1},
+			{Name: "far", Kind: Uint64Field, Offset: 1 << 29, Default: 9, Discriminant: NoDiscriminant},
+			{Name: "farptr", Kind: PointerField, Offset: 1 << 20, Discriminant: NoDiscriminant},
+		},
+		DiscriminantOffset: 0,
+	}
+	d := NewDynamicStruct(s, typ)
+	if v, err := d.Get("count"); err != nil || v.Kind() != Int16Field {
+		t.Errorf("Get(\"count\") = %v, %v; want Int16Field value", v, err)
+	} else if n, err := v.Int(); err != nil || n != -2 {
+		t.Errorf("Get(\"count\").Int() = %d, %v; want -2, <nil>", n, err)
+	}
+	if v, err := d.Get("flag"); err != nil {
+		t.Errorf("Get(\"flag\"): %v", err)
+	} else if b, err := v.Bool(); err != nil || !b {
+		t.Errorf("Get(\"flag\").Bool() = %t, %v; want true, <nil>", b, err)
+	}
+	if v, err := d.Get("ratio"); err != nil {
+		t.Errorf("Get(\"ratio\"): %v", err)
+	} else if f, err := v.Float(); err != nil || f != 1.5 {
+		t.Errorf("Get(\"ratio\").Float() = %g, %v; want 1.5, <nil>", f, err)
+	}
+	if v, err := d.Get("name"); err != nil {
+		t.Errorf("Get(\"name\"): %v", err)
+	} else {
+		if txt, err := v.Text(); err != nil || txt != "hello" {
+			t.Errorf("Get(\"name\").Text() = %q, %v; want \"hello\", <nil>", txt, err)
+		}
+		if v.IsNull() {
+			t.Error("Get(\"name\").IsNull() = true; want false")
+		}
+		if _, err := v.Int(); err != errValueKind {
+			t.Errorf("Get(\"name\").Int() error = %v; want %v", err, errValueKind)
+		}
+	}
+	// Slots past the end of the struct read as their defaults, even
+	// when the offset would overflow a 32-bit byte offset.
+	if v, err := d.Get("far"); err != nil {
+		t.Errorf("Get(\"far\"): %v", err)
+	} else if n, err := v.Uint(); err != nil || n != 9 {
+		t.Errorf("Get(\"far\").Uint() = %d, %v; want 9, <nil>", n, err)
+	}
+	if v, err := d.Get("farptr"); err != nil || !v.IsNull() {
+		t.Errorf("Get(\"farptr\") = %v, %v; want null value", v, err)
+	}
+	if _, err := d.Get("other"); err == nil {
+		t.Error("Get of unselected union member succeeded; want error")
+	}
+	if _, err := d.Get("missing"); err == nil {
+		t.Error("Get of missing field succeeded; want error")
+	}
+}